@@ -0,0 +1,296 @@
+// chatadmin 是通过管理接口管理 GoChat 服务器的命令行工具
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `用法: chatadmin [全局参数] <命令> [参数]
+
+全局参数:
+  -server URL   管理接口地址 (GOCHAT_ADMIN_URL，默认 http://127.0.0.1:9091)，
+                Unix 套接字写作 unix:/path/to/admin.sock
+  -token TOKEN  访问令牌 (GOCHAT_ADMIN_TOKEN)
+  -json         以 JSON 格式输出查询结果
+
+命令:
+  users                        列出在线用户
+  kick <用户名> [原因]          踢出用户
+  ban user <用户名>             封禁用户名并断开其连接
+  ban ip <IP 或 CIDR>           封禁 IP 或网段
+  unban user <用户名>           解除用户名封禁
+  unban ip <IP 或 CIDR>         解除 IP 封禁
+  bans                         列出通过管理接口添加的封禁
+  accounts                     列出账号
+  accounts add <用户名>         创建账号，密码从标准输入读取
+  accounts passwd <用户名>      修改密码，密码从标准输入读取
+  accounts remove <用户名>      删除账号
+  groups                       列出群组
+  groups create <群组名>        创建持久群组
+  groups delete <群组名>        解散群组
+  announce <内容>               以系统身份发送公告
+  stats                        查看运行统计
+  logs [条数]                   查看最近的服务器日志
+  reload                       重新加载服务器配置
+`
+
+func main() {
+	fs := flag.NewFlagSet("chatadmin", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := fs.String("server", envOr("GOCHAT_ADMIN_URL", "http://127.0.0.1:9091"), "管理接口地址")
+	token := fs.String("token", os.Getenv("GOCHAT_ADMIN_TOKEN"), "访问令牌")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c := newAPIClient(*server, *token)
+	cli := &cli{api: c, json: *asJSON}
+	if err := cli.run(fs.Arg(0), fs.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+}
+
+type cli struct {
+	api  *apiClient
+	json bool
+}
+
+func (c *cli) run(cmd string, args []string) error {
+	switch cmd {
+	case "users", "who":
+		return c.users()
+	case "kick":
+		if len(args) < 1 {
+			return fmt.Errorf("用法: kick <用户名> [原因]")
+		}
+		return c.api.do("POST", "/api/clients/"+url.PathEscape(args[0])+"/kick",
+			map[string]string{"reason": strings.Join(args[1:], " ")}, nil)
+	case "ban", "unban":
+		return c.ban(cmd == "ban", args)
+	case "bans":
+		var bans struct {
+			Users []string `json:"users"`
+			IPs   []string `json:"ips"`
+		}
+		if err := c.api.do("GET", "/api/bans", nil, &bans); err != nil {
+			return err
+		}
+		if c.json {
+			return printJSON(bans)
+		}
+		fmt.Println("用户:", strings.Join(bans.Users, ", "))
+		fmt.Println("IP:  ", strings.Join(bans.IPs, ", "))
+		return nil
+	case "accounts":
+		return c.accounts(args)
+	case "groups":
+		return c.groups(args)
+	case "announce":
+		if len(args) == 0 {
+			return fmt.Errorf("用法: announce <内容>")
+		}
+		return c.api.do("POST", "/api/announce", map[string]string{"text": strings.Join(args, " ")}, nil)
+	case "stats":
+		return c.stats()
+	case "logs":
+		return c.logs(args)
+	case "reload":
+		return c.api.do("POST", "/api/reload", nil, nil)
+	default:
+		return fmt.Errorf("未知命令: %s", cmd)
+	}
+}
+
+func (c *cli) users() error {
+	var clients []struct {
+		Username    string    `json:"username"`
+		RemoteAddr  string    `json:"remote_addr"`
+		ConnectedAt time.Time `json:"connected_at"`
+		Groups      []string  `json:"groups"`
+	}
+	if err := c.api.do("GET", "/api/clients", nil, &clients); err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(clients)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "用户名\t远端地址\t在线时长\t群组")
+	for _, cl := range clients {
+		name := cl.Username
+		if name == "" {
+			name = "(未登录)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, cl.RemoteAddr,
+			time.Since(cl.ConnectedAt).Round(time.Second), strings.Join(cl.Groups, ","))
+	}
+	return w.Flush()
+}
+
+func (c *cli) ban(add bool, args []string) error {
+	if len(args) != 2 || (args[0] != "user" && args[0] != "ip") {
+		return fmt.Errorf("用法: ban|unban user <用户名> 或 ban|unban ip <IP 或 CIDR>")
+	}
+	kind, value := args[0], args[1]
+	if add {
+		body := map[string]string{"username": value}
+		if kind == "ip" {
+			body = map[string]string{"ip": value}
+		}
+		return c.api.do("POST", "/api/bans", body, nil)
+	}
+	if kind == "user" {
+		return c.api.do("DELETE", "/api/bans/users/"+url.PathEscape(value), nil, nil)
+	}
+	// CIDR 中的 / 作为路径的一部分传递
+	return c.api.do("DELETE", "/api/bans/ips/"+value, nil, nil)
+}
+
+func (c *cli) accounts(args []string) error {
+	if len(args) == 0 {
+		var names []string
+		if err := c.api.do("GET", "/api/accounts", nil, &names); err != nil {
+			return err
+		}
+		if c.json {
+			return printJSON(names)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("用法: accounts [add|passwd|remove <用户名>]")
+	}
+	name := args[1]
+	switch args[0] {
+	case "add":
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		return c.api.do("POST", "/api/accounts", map[string]string{"username": name, "password": password}, nil)
+	case "passwd":
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		return c.api.do("PUT", "/api/accounts/"+url.PathEscape(name)+"/password", map[string]string{"password": password}, nil)
+	case "remove":
+		return c.api.do("DELETE", "/api/accounts/"+url.PathEscape(name), nil, nil)
+	default:
+		return fmt.Errorf("未知的 accounts 子命令: %s", args[0])
+	}
+}
+
+func (c *cli) groups(args []string) error {
+	if len(args) == 0 {
+		var groups []struct {
+			Name       string   `json:"name"`
+			Persistent bool     `json:"persistent"`
+			Members    []string `json:"members"`
+		}
+		if err := c.api.do("GET", "/api/groups", nil, &groups); err != nil {
+			return err
+		}
+		if c.json {
+			return printJSON(groups)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "群组\t持久\t成员")
+		for _, g := range groups {
+			persistent := ""
+			if g.Persistent {
+				persistent = "是"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", g.Name, persistent, strings.Join(g.Members, ","))
+		}
+		return w.Flush()
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("用法: groups [create|delete <群组名>]")
+	}
+	switch args[0] {
+	case "create":
+		return c.api.do("POST", "/api/groups", map[string]string{"name": args[1]}, nil)
+	case "delete":
+		return c.api.do("DELETE", "/api/groups/"+url.PathEscape(args[1]), nil, nil)
+	default:
+		return fmt.Errorf("未知的 groups 子命令: %s", args[0])
+	}
+}
+
+func (c *cli) stats() error {
+	var stats map[string]any
+	if err := c.api.do("GET", "/api/stats", nil, &stats); err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
+
+func (c *cli) logs(args []string) error {
+	limit := "100"
+	if len(args) > 0 {
+		limit = args[0]
+	}
+	var entries []struct {
+		Time    time.Time      `json:"time"`
+		Level   string         `json:"level"`
+		Message string         `json:"msg"`
+		Attrs   map[string]any `json:"attrs"`
+	}
+	if err := c.api.do("GET", "/api/logs?limit="+url.QueryEscape(limit), nil, &entries); err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(entries)
+	}
+	for _, e := range entries {
+		var attrs []string
+		for _, k := range slices.Sorted(maps.Keys(e.Attrs)) {
+			attrs = append(attrs, fmt.Sprintf("%s=%v", k, e.Attrs[k]))
+		}
+		fmt.Printf("%s %-5s %s %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Level, e.Message, strings.Join(attrs, " "))
+	}
+	return nil
+}
+
+// readPassword 从标准输入读取一行作为密码，便于在脚本中通过管道传入
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "密码: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("读取密码失败: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
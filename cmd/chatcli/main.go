@@ -0,0 +1,362 @@
+// chatcli 是非交互式的聊天命令行客户端，便于在脚本和 CI 中发送通知或读取消息
+package main
+
+import (
+	"GoChat/internal/client"
+	"GoChat/internal/logging"
+	"GoChat/pkg/protocol"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const usage = `用法: chatcli [全局参数] <命令> [参数]
+
+全局参数:
+  -server 地址   服务器地址 (GOCHAT_SERVER，默认 127.0.0.1:8080)
+  -user 用户名   登录用户名 (GOCHAT_USER)，使用客户端证书登录时可省略
+  -tls           使用 TLS 加密连接
+  -timeout 时长  等待服务器响应的超时 (默认 10s)
+  -json          以 JSON 格式输出 who 的结果
+
+已注册账号的密码从环境变量 GOCHAT_PASSWORD 读取；GOCHAT_TLS_CA、GOCHAT_TLS_CERT、
+GOCHAT_TLS_KEY 与图形客户端相同。
+
+命令:
+  send [--group 群组 | --to 用户] [内容]      发送消息，不指定时发到世界大厅，
+                                             省略内容时从标准输入读取
+  sendfile (--group 群组 | --to 用户) <路径>  发送文件
+  tail [--group 群组] [-n 条数]               以 JSON 行输出收到的消息，指定群组时
+                                             先加入该群组并只输出该群组的消息
+  who                                        列出在线用户和群组
+`
+
+// options 是登录需要的全局参数
+type options struct {
+	address  string
+	username string
+	password string
+	tls      bool
+	timeout  time.Duration
+}
+
+func main() {
+	var opts options
+	fs := flag.NewFlagSet("chatcli", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.StringVar(&opts.address, "server", envOr("GOCHAT_SERVER", "127.0.0.1:8080"), "服务器地址")
+	fs.StringVar(&opts.username, "user", os.Getenv("GOCHAT_USER"), "用户名")
+	fs.BoolVar(&opts.tls, "tls", false, "使用 TLS 加密连接")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "等待服务器响应的超时")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts.password = os.Getenv("GOCHAT_PASSWORD")
+
+	// 标准输出留给命令结果，日志默认只输出警告和错误
+	if _, err := logging.Setup(logging.Options{
+		Level:  envOr("GOCHAT_LOG_LEVEL", "warn"),
+		Format: os.Getenv("GOCHAT_LOG_FORMAT"),
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "错误: 初始化日志失败:", err)
+		os.Exit(1)
+	}
+
+	cli := &cli{opts: opts, json: *asJSON}
+	if err := cli.run(fs.Arg(0), fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// errUsage 表示命令行参数有误，用法说明已经输出
+var errUsage = errors.New("参数错误")
+
+type cli struct {
+	opts options
+	json bool
+}
+
+func (c *cli) run(cmd string, args []string) error {
+	switch cmd {
+	case "send":
+		return c.send(args)
+	case "sendfile":
+		return c.sendFile(args)
+	case "tail":
+		return c.tail(args)
+	case "who":
+		return c.who()
+	default:
+		return fmt.Errorf("未知命令: %s", cmd)
+	}
+}
+
+// target 解析 --group 和 --to 参数
+type target struct {
+	group string
+	to    string
+}
+
+func (t *target) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.group, "group", "", "发送到群组")
+	fs.StringVar(&t.to, "to", "", "私聊发送给用户")
+}
+
+// subcommand 创建子命令的参数解析器，出错时输出子命令的用法
+func subcommand(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "用法: chatcli "+synopsis) }
+	return fs
+}
+
+// parse 解析子命令参数，flag 包在出错时已经输出了用法
+func parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errUsage, err)
+}
+
+// connect 创建客户端并登录
+func (c *cli) connect() (*session, error) {
+	coreClient, err := client.NewClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return login(coreClient, c.opts)
+}
+
+// prepare 登录并确认消息可以发往目标: 私聊对象必须在线，群组会先加入
+func (c *cli) prepare(t target) (*session, error) {
+	s, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.to != "":
+		if !s.online(t.to) {
+			err = fmt.Errorf("用户 %s 不在线", t.to)
+		}
+	case t.group != "":
+		err = s.joinGroup(t.group)
+	}
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *cli) send(args []string) error {
+	var t target
+	fs := subcommand("send", "send [--group 群组 | --to 用户] [内容]")
+	t.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if t.group != "" && t.to != "" {
+		fs.Usage()
+		return fmt.Errorf("%w: --group 和 --to 不能同时使用", errUsage)
+	}
+
+	text := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("读取标准输入失败: %w", err)
+		}
+		text = strings.TrimRight(string(data), "\r\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("消息内容为空")
+	}
+
+	msg := protocol.Message{Type: protocol.BroadcastMessage, TextPayload: text}
+	switch {
+	case t.to != "":
+		msg.Type, msg.Recipient = protocol.PrivateMessage, t.to
+	case t.group != "":
+		msg.Type, msg.GroupName = protocol.GroupMessage, t.group
+	}
+
+	s, err := c.prepare(t)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	return s.deliver(msg)
+}
+
+func (c *cli) sendFile(args []string) error {
+	var t target
+	fs := subcommand("sendfile", "sendfile (--group 群组 | --to 用户) <路径>")
+	t.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (t.group == "") == (t.to == "") {
+		fs.Usage()
+		return fmt.Errorf("%w: 需要一个文件路径，并且只能指定 --group 或 --to 之一", errUsage)
+	}
+	// 登录前先确认文件可以读取，避免为一个错误的路径加入群组
+	if _, err := os.Stat(fs.Arg(0)); err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	s, err := c.prepare(t)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	msgType := protocol.PrivateFileMessage
+	if t.group != "" {
+		msgType = protocol.GroupFileMessage
+	}
+	msg, err := s.client.FileMessage(msgType, t.to, t.group, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	return s.deliver(msg)
+}
+
+// event 是 tail 输出的一条消息
+type event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"` // 协议中的消息类型，如 msg_group、file_private
+	Sender    string    `json:"sender,omitempty"`
+	Recipient string    `json:"recipient,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	File      *fileInfo `json:"file,omitempty"`
+	Encrypted bool      `json:"encrypted,omitempty"` // 消息经过端到端加密
+}
+
+// fileInfo 是收到的文件的描述，不包含文件内容
+type fileInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (c *cli) tail(args []string) error {
+	fs := subcommand("tail", "tail [--group 群组] [-n 条数]")
+	group := fs.String("group", "", "只输出该群组的消息")
+	count := fs.Int("n", 0, "输出指定条数后退出，0 表示一直输出")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	s, err := c.prepare(target{group: *group})
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	printed := 0
+	for msg := range s.client.GetIncomingMessages() {
+		e, ok := toEvent(msg)
+		if !ok || (*group != "" && e.Group != *group) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		printed++
+		if *count > 0 && printed >= *count {
+			return nil
+		}
+	}
+	if err := s.client.Err(); err != nil {
+		return fmt.Errorf("%w: %w", errClosed, err)
+	}
+	return errClosed
+}
+
+// toEvent 将聊天消息和系统通知转换为 tail 的输出，其它消息返回 false
+func toEvent(msg protocol.Message) (event, bool) {
+	switch msg.Type {
+	case protocol.BroadcastMessage, protocol.PrivateMessage, protocol.GroupMessage,
+		protocol.PrivateFileMessage, protocol.GroupFileMessage, protocol.SystemMessage:
+	default:
+		return event{}, false
+	}
+	e := event{
+		Time:      msg.Timestamp,
+		Type:      msg.Type,
+		Sender:    msg.Sender,
+		Recipient: msg.Recipient,
+		Group:     msg.GroupName,
+		Text:      msg.TextPayload,
+		Encrypted: msg.Encrypted != nil,
+	}
+	if msg.Type == protocol.PrivateFileMessage || msg.Type == protocol.GroupFileMessage {
+		e.File = &fileInfo{Name: msg.FilePayload.Name, Size: msg.FilePayload.Size}
+	}
+	return e, true
+}
+
+func (c *cli) who() error {
+	s, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	users := slices.Sorted(slices.Values(s.tree.Users))
+	if c.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Users           []string            `json:"users"`
+			Groups          map[string][]string `json:"groups"`
+			EncryptedGroups []string            `json:"encrypted_groups,omitempty"`
+			Bots            []string            `json:"bots,omitempty"`
+		}{users, s.tree.Groups, s.tree.EncryptedGroups, s.tree.Bots})
+	}
+	fmt.Printf("在线用户 (%d):\n", len(users))
+	for _, user := range users {
+		if slices.Contains(s.tree.Bots, user) {
+			user += " (机器人)"
+		}
+		fmt.Println("  " + user)
+	}
+	fmt.Printf("群组 (%d):\n", len(s.tree.Groups))
+	for _, name := range slices.Sorted(maps.Keys(s.tree.Groups)) {
+		encrypted := ""
+		if slices.Contains(s.tree.EncryptedGroups, name) {
+			encrypted = " (加密)"
+		}
+		fmt.Printf("  %s%s: %s\n", name, encrypted, strings.Join(s.tree.Groups[name], ", "))
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"GoChat/internal/client"
+	"GoChat/pkg/protocol"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// pollInterval 是等待期间检查客户端内部状态的间隔
+const pollInterval = 100 * time.Millisecond
+
+// errClosed 表示等待期间与服务器的连接已断开
+var errClosed = errors.New("与服务器的连接已断开")
+
+// session 是一次非交互式的登录会话
+type session struct {
+	client   *client.Client
+	username string               // 服务器确认的用户名
+	tree     protocol.TreePayload // 最近一次收到的在线状态
+	timeout  time.Duration        // 每一步等待服务器响应的超时
+}
+
+// login 连接服务器并登录，返回前已经收到登录后的第一次状态更新
+func login(c *client.Client, opts options) (*session, error) {
+	if opts.username == "" && !c.HasCertificate() {
+		return nil, errors.New("需要用户名，请使用 -user 或 GOCHAT_USER 指定")
+	}
+	if err := c.Connect(opts.address, opts.tls); err != nil {
+		var changed *client.CertificateChangedError
+		if errors.As(err, &changed) {
+			return nil, fmt.Errorf("%w，确认服务器更换了证书后请在图形或终端客户端中重新信任", err)
+		}
+		return nil, fmt.Errorf("连接服务器失败: %w", err)
+	}
+	c.SetUsername(opts.username)
+	c.Start()
+	c.Send(protocol.Message{Type: protocol.LoginRequest, Sender: opts.username, TextPayload: opts.password})
+
+	s := &session{client: c, timeout: opts.timeout}
+	err := s.wait("登录", func(msg protocol.Message) (bool, error) {
+		switch msg.Type {
+		case protocol.LoginResponse:
+			if msg.TextPayload != "" {
+				return false, fmt.Errorf("登录失败: %s", msg.TextPayload)
+			}
+			s.username = msg.Recipient
+			c.SetUsername(s.username)
+		case protocol.TreeUpdate:
+			// 服务器在登录成功和欢迎消息之后广播状态，收到时登录过程已经完成
+			return s.username != "", nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// wait 读取服务器消息并交给 done 判断，直到 done 返回 true、返回错误或超时。
+// 状态更新会先记录到 s.tree 中。群组密钥不经过消息通道，所以还会定期以空消息调用 done
+func (s *session) wait(what string, done func(protocol.Message) (bool, error)) error {
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if finished, err := done(protocol.Message{}); err != nil || finished {
+				return err
+			}
+		case msg, ok := <-s.client.GetIncomingMessages():
+			if !ok {
+				if err := s.client.Err(); err != nil {
+					return fmt.Errorf("%s时%w: %w", what, errClosed, err)
+				}
+				return fmt.Errorf("%s时%w", what, errClosed)
+			}
+			if msg.Type == protocol.TreeUpdate {
+				s.tree = msg.TreePayload
+			}
+			finished, err := done(msg)
+			if err != nil {
+				return err
+			}
+			if finished {
+				return nil
+			}
+		case <-timer.C:
+			return fmt.Errorf("%s超时，服务器在 %s 内没有响应", what, s.timeout)
+		}
+	}
+}
+
+// online 返回用户是否在线
+func (s *session) online(username string) bool {
+	return slices.Contains(s.tree.Users, username)
+}
+
+// joinGroup 加入群组 (不存在时由服务器创建)，等待状态更新确认已是成员。
+// 加密群组还要等待收到群组密钥，之后发送的消息才能被加密
+func (s *session) joinGroup(group string) error {
+	if !s.inGroup(group) {
+		s.client.Send(protocol.Message{Type: protocol.JoinGroupRequest, Sender: s.username, GroupName: group})
+		err := s.wait("加入群组 "+group, func(msg protocol.Message) (bool, error) {
+			if msg.Type == protocol.SystemMessage {
+				return false, fmt.Errorf("加入群组 %s 失败: %s", group, msg.TextPayload)
+			}
+			return s.inGroup(group), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !s.client.IsEncryptedGroup(group) || s.client.GroupKeyReady(group) {
+		return nil
+	}
+	if !s.client.E2EEnabled() {
+		return fmt.Errorf("群组 %s 启用了端到端加密，但本机无法使用加密密钥", group)
+	}
+	return s.wait("等待群组 "+group+" 的密钥", func(protocol.Message) (bool, error) {
+		return s.client.GroupKeyReady(group), nil
+	})
+}
+
+// inGroup 返回当前用户是否是群组成员
+func (s *session) inGroup(group string) bool {
+	members, ok := s.tree.Groups[group]
+	return ok && slices.Contains(members, s.username)
+}
+
+// deliver 发送一条聊天消息，并等待服务器转发回发送者，确认消息已经送达。
+// 服务器在等待期间发来的系统通知 (例如发送过于频繁或明文被加密群组拒绝) 视为发送失败
+func (s *session) deliver(msg protocol.Message) error {
+	msg.Sender = s.username
+	s.client.Send(msg)
+	return s.wait("发送消息", func(echo protocol.Message) (bool, error) {
+		switch echo.Type {
+		case protocol.SystemMessage:
+			return false, fmt.Errorf("消息未送达: %s", echo.TextPayload)
+		case msg.Type:
+			return echo.Sender == s.username && echo.Recipient == msg.Recipient && echo.GroupName == msg.GroupName, nil
+		}
+		return false, nil
+	})
+}
+
+// close 断开与服务器的连接
+func (s *session) close() {
+	s.client.Close()
+}
@@ -2,16 +2,31 @@ package main
 
 import (
 	"GoChat/internal/client"
-	"fmt"
+	"GoChat/internal/client/gui"
+	"GoChat/internal/logging"
+	"log"
+	"log/slog"
+	"os"
 
 	"fyne.io/fyne/v2/app"
 )
 
 func main() {
+	// 日志级别和格式可通过环境变量调整，便于排查问题
+	if _, err := logging.Setup(logging.Options{
+		Level:  os.Getenv("GOCHAT_LOG_LEVEL"),
+		Format: os.Getenv("GOCHAT_LOG_FORMAT"),
+	}); err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+
 	fyneApp := app.NewWithID("io.github.lazyfu.chattool")
-	coreClient := client.NewClient()
-	gui := client.NewUI(fyneApp, coreClient)
-	gui.Run()
+	coreClient, err := client.NewClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ui := gui.NewUI(fyneApp, coreClient)
+	ui.Run()
 	coreClient.Close()
-	fmt.Println("客户端已关闭。")
+	slog.Info("客户端已关闭。")
 }
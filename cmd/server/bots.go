@@ -0,0 +1,36 @@
+package main
+
+import (
+	"GoChat/internal/server/bots"
+	"GoChat/internal/server/config"
+	"GoChat/internal/server/core"
+	"fmt"
+	"log/slog"
+)
+
+// startBots 让配置中的内置机器人登录到 Hub
+func startBots(hub *core.Hub, configs []config.BotConfig) {
+	for _, cfg := range configs {
+		bot, err := newBot(cfg)
+		if err == nil {
+			_, err = hub.RegisterBot(bot)
+		}
+		if err != nil {
+			slog.Error("启动机器人失败", "bot", cfg.Name, "error", err)
+			continue
+		}
+		slog.Info("启动机器人", "bot", cfg.Name, "kind", cfg.Kind)
+	}
+}
+
+// newBot 按类型创建内置机器人
+func newBot(cfg config.BotConfig) (core.Bot, error) {
+	switch cfg.Kind {
+	case "faq":
+		return bots.NewFAQ(cfg.Name, cfg.Groups, cfg.Answers), nil
+	case "remind":
+		return bots.NewReminder(cfg.Name, cfg.Groups), nil
+	default:
+		return nil, fmt.Errorf("未知的机器人类型: %q", cfg.Kind)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"GoChat/internal/server/config"
+	"GoChat/pkg/discovery"
+	"GoChat/pkg/protocol"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+)
+
+// startDiscovery 回复局域网内客户端的查询，公布第一个可从局域网访问的 TCP 聊天监听端点
+func startDiscovery(cfg *config.Config, tlsConfig *tls.Config) error {
+	announcement, err := discoveryAnnouncement(cfg, tlsConfig)
+	if err != nil {
+		return err
+	}
+	responder, err := discovery.Listen(cfg.Discovery.Port, announcement)
+	if err != nil {
+		return err
+	}
+	slog.Info("局域网服务器发现已启用", "port", cfg.Discovery.Port, "name", announcement.Name,
+		"chat_port", announcement.Port, "tls", announcement.TLS)
+	go func() {
+		if err := responder.Serve(); err != nil {
+			slog.Error("局域网服务器发现已停止", "error", err)
+		}
+	}()
+	return nil
+}
+
+func discoveryAnnouncement(cfg *config.Config, tlsConfig *tls.Config) (discovery.Announcement, error) {
+	name := cfg.Discovery.Name
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	for _, l := range cfg.ChatListeners() {
+		if l.Network == "unix" || (l.Protocol != "" && l.Protocol != "gochat") {
+			continue
+		}
+		host, portStr, err := net.SplitHostPort(l.Address)
+		if err != nil {
+			continue
+		}
+		if addr, err := netip.ParseAddr(host); (err == nil && addr.IsLoopback()) || host == "localhost" {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		announcement := discovery.Announcement{Name: name, Port: port, TLS: l.TLS}
+		if l.TLS && tlsConfig != nil {
+			announcement.Fingerprint = protocol.CertFingerprint(tlsConfig.Certificates[0].Leaf.Raw)
+		}
+		return announcement, nil
+	}
+	return discovery.Announcement{}, fmt.Errorf("没有可从局域网访问的 TCP 监听端点")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"GoChat/internal/server/transport"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// httpService 是一个监听地址上的路由及其 TLS 配置
+type httpService struct {
+	mux       *http.ServeMux
+	tlsConfig *tls.Config // 不为空时使用 HTTPS
+}
+
+// httpServices 按监听地址归类的 HTTP 路由，配置了相同地址的功能共用一个端口
+type httpServices map[string]*httpService
+
+// service 返回指定地址上的服务，不存在时创建
+func (s httpServices) service(address string) *httpService {
+	svc, ok := s[address]
+	if !ok {
+		svc = &httpService{mux: http.NewServeMux()}
+		s[address] = svc
+	}
+	return svc
+}
+
+// handle 在指定地址上注册处理器
+func (s httpServices) handle(address, pattern string, handler http.Handler) {
+	s.service(address).mux.Handle(pattern, handler)
+	slog.Info("HTTP 服务已注册", "address", address, "path", pattern)
+}
+
+// useTLS 使指定地址上的所有 HTTP 服务改用 HTTPS
+func (s httpServices) useTLS(address string, config *tls.Config) {
+	s.service(address).tlsConfig = config
+}
+
+// start 为每个地址启动一个 HTTP 服务，"unix:<路径>" 形式的地址监听 Unix 套接字
+func (s httpServices) start() {
+	for address, svc := range s {
+		network := "tcp"
+		if path, ok := strings.CutPrefix(address, "unix:"); ok {
+			network, address = "unix", path
+		}
+		listener, err := transport.Listen(network, address)
+		if err != nil {
+			fatal("HTTP 服务启动失败", err)
+		}
+		server := &http.Server{Handler: svc.mux, TLSConfig: svc.tlsConfig}
+		go func() {
+			var err error
+			if svc.tlsConfig != nil {
+				err = server.ServeTLS(listener, "", "")
+			} else {
+				err = server.Serve(listener)
+			}
+			fatal("HTTP 服务已停止", err)
+		}()
+	}
+}
@@ -1,23 +1,265 @@
 package main
 
 import (
+	"GoChat/internal/logging"
+	"GoChat/internal/server/admin"
+	"GoChat/internal/server/config"
 	"GoChat/internal/server/core"
+	"GoChat/internal/server/metrics"
+	"GoChat/internal/server/store"
 	"GoChat/internal/server/transport"
+	"GoChat/internal/server/web"
+	"GoChat/pkg/protocol"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
 )
 
 func main() {
+	// 加载配置
+	loader, err := config.NewLoader(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 初始化日志
+	var logOutput io.Writer = os.Stderr
+	if cfg.Log.File != "" {
+		f, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("打开日志文件失败: %v", err)
+		}
+		defer f.Close()
+		logOutput = f
+	}
+	if _, err := logging.Setup(logging.Options{
+		Level:   cfg.Log.Level,
+		Format:  cfg.Log.Format,
+		Privacy: cfg.Log.Privacy,
+		Output:  logOutput,
+		Recent:  cfg.Admin.LogBuffer,
+	}); err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+
+	if err := os.MkdirAll(cfg.Storage.DataDir, 0755); err != nil {
+		fatal("创建数据目录失败", err)
+	}
+
+	// 打开数据目录中的账号、封禁和持久群组
+	accounts, err := store.OpenAccounts(cfg.Storage.DataDir, cfg.Auth.RequireAccount)
+	if err != nil {
+		fatal("加载账号失败", err)
+	}
+	bans, err := store.OpenBans(cfg.Storage.DataDir)
+	if err != nil {
+		fatal("加载封禁列表失败", err)
+	}
+	groups, err := store.OpenGroups(cfg.Storage.DataDir)
+	if err != nil {
+		fatal("加载持久群组失败", err)
+	}
+
 	// 初始化 Hub
-	hub := core.NewHub()
+	opts := hubOptions(cfg)
+	opts.Auth = accounts
+	hub := core.NewHub(opts, hubSettings(cfg, bans.List()))
+	useWebhooks(hub, cfg.Webhooks)
 	go hub.Run()
+	for _, name := range groups.List() {
+		hub.SetGroupPersistent(name, true)
+	}
+	startBots(hub, cfg.Bots)
+
+	// 支持通过 SIGHUP、控制台命令或管理接口热加载配置
+	reloader := newReloader(loader, hub, bans, cfg)
+	go reloader.watchSignals()
+	go reloader.watchConsole(os.Stdin)
 
-	// 创建 TCP 服务器
-	server := transport.NewServer("0.0.0.0", 8080, hub)
+	// 所有监听端点共用同一份证书
+	var tlsConfig *tls.Config
+	if cfg.UsesTLS() {
+		if tlsConfig, err = serverTLSConfig(cfg); err != nil {
+			fatal("加载 TLS 证书失败", err)
+		}
+	}
 
-	fmt.Println("服务器正在启动...")
-	err := server.Start()
+	// 可选的 HTTP 服务: Prometheus 指标接口、管理接口、WebSocket、网页客户端和接收 Webhook
+	services := httpServices{}
+	if cfg.Metrics.Address != "" {
+		services.handle(cfg.Metrics.Address, cfg.Metrics.Path, metrics.Handler())
+	}
+	adminServer := admin.NewServer(admin.Options{
+		Hub:       hub,
+		Token:     cfg.Admin.Token,
+		Accounts:  accounts,
+		Bans:      bans,
+		Groups:    groups,
+		Reload:    reloader.Reload,
+		ApplyBans: reloader.ApplyBans,
+		ValidateIP: func(ip string) error {
+			_, err := config.ParseIPPrefix(ip)
+			return err
+		},
+	})
+	if cfg.Admin.Address != "" {
+		services.handle(cfg.Admin.Address, "/api/", adminServer)
+	}
+	// 只提供管理接口的监听端点，如供本机管理工具使用的 Unix 套接字
+	for _, l := range cfg.Listeners {
+		if l.AdminOnly {
+			services.handle(l.HTTPAddress(), "/api/", adminServer)
+			if l.TLS {
+				services.useTLS(l.HTTPAddress(), tlsConfig)
+			}
+		}
+	}
+	if cfg.WebSocket.Address != "" {
+		services.handle(cfg.WebSocket.Address, cfg.WebSocket.Path, transport.WebSocketHandler(hub, cfg.WebSocket.AllowedOrigins))
+		if cfg.WebSocket.Web {
+			services.handle(cfg.WebSocket.Address, "/", web.Handler(web.Options{
+				WebSocketPath: cfg.WebSocket.Path,
+				MaxFrameSize:  cfg.Limits.MaxFrameSize,
+			}))
+		}
+		if cfg.WebSocket.TLS {
+			services.useTLS(cfg.WebSocket.Address, tlsConfig)
+		}
+	}
+	if cfg.Incoming.Address != "" {
+		incoming, err := incomingWebhooks(hub, cfg.Incoming.Hooks)
+		if err != nil {
+			fatal("启用接收 Webhook 失败", err)
+		}
+		services.handle(cfg.Incoming.Address, cfg.Incoming.Path, http.StripPrefix(cfg.Incoming.Path, incoming))
+	}
+	services.start()
+
+	if cfg.Discovery.Enabled {
+		if err := startDiscovery(cfg, tlsConfig); err != nil {
+			slog.Warn("无法启用局域网服务器发现", "error", err)
+		}
+	}
+
+	// 每个聊天监听端点一个服务器，共用同一个 Hub
+	slog.Info("服务器正在启动...")
+	for _, l := range cfg.ChatListeners() {
+		server := transport.NewServer(l.Network, l.Address, hub)
+		server.Protocol = l.Protocol
+		if l.TLS {
+			server.TLSConfig = tlsConfig
+		}
+		go func() {
+			if err := server.Start(); err != nil {
+				fatal("服务器启动失败", err)
+			}
+		}()
+	}
+	if cfg.SSH.Address != "" {
+		server, err := transport.NewSSHServer("tcp", cfg.SSH.Address, hub, sshOptions(cfg, accounts))
+		if err != nil {
+			fatal("SSH 服务器初始化失败", err)
+		}
+		go func() {
+			if err := server.Start(); err != nil {
+				fatal("SSH 服务器启动失败", err)
+			}
+		}()
+	}
+	select {}
+}
+
+// fatal 记录错误并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// serverTLSConfig 读取配置的证书，未配置时使用数据目录中的自签名证书
+func serverTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if cfg.TLS.CertFile != "" {
+		cert, err = transport.LoadCertificate(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	} else {
+		cert, err = transport.SelfSignedCertificate(filepath.Join(cfg.Storage.DataDir, "tls"), cfg.TLS.Hosts)
+	}
 	if err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+		return nil, err
+	}
+	var clientCAs *x509.CertPool
+	if cfg.TLS.ClientCAFile != "" {
+		if clientCAs, err = transport.LoadCertPool(cfg.TLS.ClientCAFile); err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 失败: %w", err)
+		}
+	}
+	// 客户端首次连接自签名服务器时可以据此核对证书
+	slog.Info("TLS 已启用", "fingerprint", protocol.CertFingerprint(cert.Leaf.Raw),
+		"not_after", cert.Leaf.NotAfter, "client_auth", clientCAs != nil)
+	return transport.ServerTLSConfig(cert, clientCAs), nil
+}
+
+// sshOptions 将配置转换为 SSH 前端的认证参数
+func sshOptions(cfg *config.Config, accounts *store.Accounts) transport.SSHOptions {
+	opts := transport.SSHOptions{
+		HostKeyFile:        cfg.SSH.HostKeyFile,
+		AuthorizedKeysFile: cfg.SSH.AuthorizedKeysFile,
+	}
+	if opts.HostKeyFile == "" {
+		opts.HostKeyFile = filepath.Join(cfg.Storage.DataDir, "ssh", "host_key")
+	}
+	if opts.AuthorizedKeysFile == "" {
+		opts.AuthorizedKeysFile = filepath.Join(cfg.Storage.DataDir, "ssh", "authorized_keys")
+	}
+	if cfg.SSH.PasswordAuth {
+		opts.Password = accounts.Authenticate
+	}
+	return opts
+}
+
+// hubOptions 将配置转换为 Hub 的运行参数
+func hubOptions(cfg *config.Config) core.Options {
+	return core.Options{
+		SendBuffer:   cfg.Limits.SendBuffer,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		MaxFrameSize: cfg.Limits.MaxFrameSize,
+		MaxClients:   cfg.Limits.MaxClients,
+		History:      cfg.Limits.History,
+	}
+}
+
+// hubSettings 提取配置中可热加载的部分，并合并运行时添加的封禁
+func hubSettings(cfg *config.Config, extra store.BanList) core.Settings {
+	bannedIPs := make([]netip.Prefix, 0, len(cfg.Bans.IPs)+len(extra.IPs))
+	for _, ip := range slices.Concat(cfg.Bans.IPs, extra.IPs) {
+		// 配置校验和管理接口都已经保证可以解析
+		prefix, _ := config.ParseIPPrefix(ip)
+		bannedIPs = append(bannedIPs, prefix)
+	}
+	return core.Settings{
+		RateLimit:   cfg.RateLimit.MessagesPerSecond,
+		RateBurst:   cfg.RateLimit.Burst,
+		BannedUsers: slices.Concat(cfg.Bans.Users, extra.Users),
+		BannedIPs:   bannedIPs,
+		MOTD:        cfg.Chat.MOTD,
+		WordFilters: cfg.Chat.WordFilters,
 	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"GoChat/internal/logging"
+	"GoChat/internal/server/config"
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/store"
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// reloader 负责重新读取配置文件，并把可热加载的部分应用到运行中的 Hub
+type reloader struct {
+	loader  *config.Loader
+	hub     *core.Hub
+	bans    *store.Bans // 通过管理接口添加的封禁，与配置文件中的封禁合并
+	mu      sync.Mutex
+	current *config.Config // 当前生效的配置
+}
+
+func newReloader(loader *config.Loader, hub *core.Hub, bans *store.Bans, cfg *config.Config) *reloader {
+	return &reloader{loader: loader, hub: hub, bans: bans, current: cfg}
+}
+
+// ApplyBans 在运行时封禁列表变化后重新应用当前配置
+func (r *reloader) ApplyBans() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	settings := hubSettings(r.current, r.bans.List())
+	r.hub.Reload <- &settings
+	return nil
+}
+
+// Reload 重新加载配置，source 用于在审计日志中标明触发来源
+func (r *reloader) Reload(source string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.loader.Load()
+	if err != nil {
+		slog.Error("配置重新加载失败", "source", source, "error", err)
+		return err
+	}
+	for _, section := range config.RestartRequired(r.current, cfg) {
+		slog.Warn("配置段已修改，需要重启服务器才能生效", "section", section)
+	}
+
+	changes := config.Diff(r.current, cfg)
+	settings := hubSettings(cfg, r.bans.List())
+	r.hub.Reload <- &settings
+	logging.SetLevel(cfg.Log.Level)
+	logging.SetPrivacy(cfg.Log.Privacy)
+
+	r.current = config.ApplyReloadable(r.current, cfg)
+
+	slog.Info("配置已重新加载", "source", source, "changes", changes)
+	return nil
+}
+
+// watchSignals 在收到 SIGHUP 时重新加载配置
+func (r *reloader) watchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		r.Reload("SIGHUP")
+	}
+}
+
+// watchConsole 从控制台读取管理命令，目前支持 reload
+func (r *reloader) watchConsole(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		switch strings.TrimSpace(scanner.Text()) {
+		case "":
+		case "reload":
+			r.Reload("控制台")
+		default:
+			fmt.Println("未知命令，可用命令: reload")
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"GoChat/internal/server/config"
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/webhook"
+	"log/slog"
+)
+
+// useWebhooks 把配置中的外发 Webhook 加入 Hub 的转发流程，需要在 Hub 运行之前调用
+func useWebhooks(hub *core.Hub, configs []config.WebhookConfig) {
+	if len(configs) == 0 {
+		return
+	}
+	rules := make([]webhook.Rule, 0, len(configs))
+	for _, cfg := range configs {
+		rules = append(rules, webhook.Rule{
+			Name:     cfg.Name,
+			URL:      cfg.URL,
+			Secret:   cfg.Secret,
+			Lobby:    cfg.Lobby,
+			Groups:   cfg.Groups,
+			Keywords: cfg.Keywords,
+			Retries:  cfg.Retries,
+			Timeout:  cfg.Timeout,
+		})
+		slog.Info("启用外发 Webhook", "webhook", cfg.Name, "lobby", cfg.Lobby, "groups", cfg.Groups, "keywords", len(cfg.Keywords))
+	}
+	hub.Use(webhook.New(rules).Middleware)
+}
+
+// incomingWebhooks 创建接收外部消息的 HTTP 处理器，Webhook 使用的机器人随之登录到 Hub
+func incomingWebhooks(hub *core.Hub, configs []config.IncomingHookConfig) (*webhook.Incoming, error) {
+	hooks := make([]webhook.IncomingHook, 0, len(configs))
+	for _, cfg := range configs {
+		hooks = append(hooks, webhook.IncomingHook{
+			Name:     cfg.Name,
+			Token:    cfg.Token,
+			Bot:      cfg.Bot,
+			Group:    cfg.Group,
+			Template: cfg.Template,
+		})
+	}
+	return webhook.NewIncoming(hub, hooks)
+}
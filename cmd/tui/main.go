@@ -0,0 +1,49 @@
+package main
+
+import (
+	"GoChat/internal/client"
+	"GoChat/internal/client/tui"
+	"GoChat/internal/logging"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+)
+
+func main() {
+	var opts tui.Options
+	flag.StringVar(&opts.Address, "server", os.Getenv("GOCHAT_SERVER"), "服务器地址，如 127.0.0.1:8080 (环境变量 GOCHAT_SERVER)")
+	flag.StringVar(&opts.Username, "user", os.Getenv("GOCHAT_USER"), "用户名 (环境变量 GOCHAT_USER)")
+	flag.BoolVar(&opts.TLS, "tls", false, "使用 TLS 加密连接")
+	flag.Parse()
+
+	// 终端界面占用了整个屏幕，日志只在设置了 GOCHAT_LOG_FILE 时写入文件
+	var logOutput io.Writer = io.Discard
+	if path := os.Getenv("GOCHAT_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatalf("打开日志文件失败: %v", err)
+		}
+		defer f.Close()
+		logOutput = f
+	}
+	if _, err := logging.Setup(logging.Options{
+		Level:  os.Getenv("GOCHAT_LOG_LEVEL"),
+		Format: os.Getenv("GOCHAT_LOG_FORMAT"),
+		Output: logOutput,
+	}); err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+
+	coreClient, err := client.NewClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ui := tui.NewUI(coreClient, opts)
+	if err := ui.Run(); err != nil {
+		log.Fatalf("终端界面运行失败: %v", err)
+	}
+	coreClient.Close()
+	slog.Info("客户端已关闭。")
+}
@@ -4,14 +4,20 @@ import (
 	"GoChat/pkg/protocol"
 	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	"io"
+	"log/slog"
 	"net"
 	"os"
 	"sync"
+	"time"
 )
 
+const dialTimeout = 10 * time.Second // 建立连接 (含 TLS 握手) 的超时
+
 type Client struct {
 	username string        // 客户端唯一标识
 	conn     net.Conn      // TCP 连接
@@ -21,6 +27,11 @@ type Client struct {
 	cancel   context.CancelFunc    // 用于取消上下文
 	incoming chan protocol.Message // 用于接收来自 Hub 的消息
 	outgoing chan protocol.Message // 用于发送消息到 Hub
+	rootCAs  *x509.CertPool        // 校验服务器证书时额外信任的 CA，为空时只使用系统证书
+	pins     *PinStore             // 已信任的服务器证书指纹，为空时不使用首次信任
+	cert     *tls.Certificate      // 服务器要求双向 TLS 时出示的客户端证书
+	err      error                 // 连接异常断开的原因，在 incoming 关闭前写入
+	e2e      *e2eState             // 私聊端到端加密，为空时不加密
 }
 
 func NewClient() *Client {
@@ -33,19 +44,43 @@ func NewClient() *Client {
 	}
 }
 
-// Connect 连接到服务器
-func (c *Client) Connect(address string) error {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		return err
+// Connect 连接到服务器，useTLS 为 true 时使用 TLS 加密连接，之前关闭过的 Client 可以再次连接
+func (c *Client) Connect(address string, useTLS bool) error {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, c.tlsConfig(address))
+		if err != nil {
+			var opErr *net.OpError
+			var changed *CertificateChangedError
+			switch {
+			case errors.As(err, &changed):
+				return changed
+			case errors.As(err, &opErr) && opErr.Op == "dial":
+				return err
+			}
+			return describeTLSError(err)
+		}
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+		if err != nil {
+			return err
+		}
 	}
+	// 等待上一次连接的收发协程退出后再重置状态
+	c.wg.Wait()
+	c.err = nil
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.incoming = make(chan protocol.Message, 256)
+	c.outgoing = make(chan protocol.Message, 256)
 	c.conn = conn
 	c.reader = bufio.NewReader(conn)
 	return nil
 }
 
 func (c *Client) Start() {
-	fmt.Println("Client.Start(): 启动 sendLoop 和 receiveLoop")
+	slog.Debug("启动 sendLoop 和 receiveLoop")
 	c.wg.Add(2)
 	go c.receiveLoop()
 	go c.sendLoop()
@@ -55,29 +90,53 @@ func (c *Client) Start() {
 func (c *Client) receiveLoop() {
 	defer c.wg.Done()
 	defer close(c.incoming)
-	fmt.Println("receiveLoop 开始等待消息")
 	for {
 		select {
 		case <-c.ctx.Done():
-			fmt.Println("receiveLoop 检测到 ctx.Done，退出")
+			slog.Debug("receiveLoop 检测到 ctx.Done，退出")
 			return
 		default:
 			message, err := protocol.DecodeMessage(c.reader)
 			if err != nil {
-				fmt.Println("receiveLoop 读取失败:", err)
-				if err != io.EOF || isNetClosedErr(err) {
-					fmt.Println("Connection closed by server:", err)
+				if isNetClosedErr(err) {
+					slog.Info("与服务器的连接已关闭", "error", err)
+				} else {
+					slog.Error("读取服务器消息失败", "error", err)
+					c.err = describeReadError(err)
 				}
 				c.Close()
 				return
 			}
 
-			if message.Type == protocol.TreeUpdate {
-				fmt.Printf("TreeUpdate收到: 用户数:%d, 群组数:%d\n",
-					len(message.TreePayload.Users),
-					len(message.TreePayload.Groups))
-			} else {
-				fmt.Println("receiveLoop 收到消息:", message.Type) // ✅
+			switch message.Type {
+			case protocol.TreeUpdate:
+				slog.Debug("收到状态更新",
+					"users", len(message.TreePayload.Users),
+					"groups", len(message.TreePayload.Groups))
+				notices, keyMessages := c.onPresence(message.TreePayload)
+				for _, notice := range notices {
+					c.incoming <- notice
+				}
+				for _, keyMessage := range keyMessages {
+					c.Send(keyMessage)
+				}
+			case protocol.GroupKeyMessage:
+				c.onGroupKey(message)
+				continue
+			case protocol.LoginResponse:
+				if message.TextPayload == "" {
+					c.onLogin(message.Recipient)
+				}
+			case protocol.HistoryResponse:
+				for i := range message.History {
+					c.decrypt(&message.History[i])
+				}
+			case protocol.PrivateMessage, protocol.PrivateFileMessage,
+				protocol.GroupMessage, protocol.GroupFileMessage:
+				c.decrypt(message)
+				slog.Debug("收到消息", "type", message.Type, "sender", message.Sender, "encrypted", message.Encrypted != nil)
+			default:
+				slog.Debug("收到消息", "type", message.Type, "sender", message.Sender)
 			}
 
 			c.incoming <- *message
@@ -95,14 +154,14 @@ func (c *Client) sendLoop() {
 		case message := <-c.outgoing:
 			frame, err := protocol.EncodeMessage(message)
 			if err != nil {
-				fmt.Println("Error encoding message:", err)
+				slog.Error("编码消息失败", "error", err)
 				continue
 			}
 			if _, err := c.conn.Write(frame); err != nil {
 				if isNetClosedErr(err) {
-					fmt.Println("Connection closed by server:", err)
+					slog.Info("与服务器的连接已关闭", "error", err)
 				} else {
-					fmt.Println("Error writing to connection:", err)
+					slog.Error("发送消息失败", "error", err)
 				}
 				c.Close()
 				return
@@ -111,6 +170,36 @@ func (c *Client) sendLoop() {
 	}
 }
 
+// SetRootCAs 设置校验服务器证书时信任的 CA
+func (c *Client) SetRootCAs(pool *x509.CertPool) {
+	c.rootCAs = pool
+}
+
+// SetPinStore 启用首次信任 (TOFU) 证书校验
+func (c *Client) SetPinStore(pins *PinStore) {
+	c.pins = pins
+}
+
+// SetCertificate 设置双向 TLS 认证使用的客户端证书，服务器将以证书中的名称作为用户名
+func (c *Client) SetCertificate(cert tls.Certificate) {
+	c.cert = &cert
+}
+
+// HasCertificate 返回是否配置了客户端证书
+func (c *Client) HasCertificate() bool {
+	return c.cert != nil
+}
+
+// Err 返回连接异常断开的原因，应在 GetIncomingMessages 返回的通道关闭后调用
+func (c *Client) Err() error {
+	return c.err
+}
+
+// PinStore 返回已信任的服务器证书记录，未启用时返回 nil
+func (c *Client) PinStore() *PinStore {
+	return c.pins
+}
+
 func (c *Client) SetUsername(name string) {
 	c.username = name
 }
@@ -128,10 +217,14 @@ func (c *Client) SendChatMessage(msgType, recipient, groupName, payload string)
 }
 
 func (c *Client) Send(msg protocol.Message) {
+	if err := c.encrypt(&msg); err != nil {
+		slog.Error("加密消息失败，消息未发送", "type", msg.Type, "error", err)
+		return
+	}
 	select {
 	case c.outgoing <- msg:
 	case <-c.ctx.Done():
-		fmt.Println("Client is closed, cannot send message")
+		slog.Warn("客户端已关闭，无法发送消息", "type", msg.Type)
 	}
 }
 
@@ -160,45 +253,55 @@ func isNetClosedErr(err error) bool {
 func (c *Client) SendFile(msgType, recipient, groupName, filePath string) {
 	// 将文件读取和编码等耗时操作放入后台goroutine，防止阻塞UI
 	go func() {
-		fileData, err := os.ReadFile(filePath)
+		fileMsg, err := c.FileMessage(msgType, recipient, groupName, filePath)
 		if err != nil {
-			fmt.Printf("错误：读取文件失败: %v\n", err)
+			slog.Error("读取文件失败", "path", filePath, "error", err)
 			// 可以在这里通过channel等方式通知UI发送失败
 			return
 		}
-
-		fileInfo, _ := os.Stat(filePath) // 获取文件名等信息
-		encodedData := base64.StdEncoding.EncodeToString(fileData)
-
-		fileMsg := protocol.Message{
-			Type:      msgType,
-			Sender:    c.username,
-			Recipient: recipient,
-			GroupName: groupName,
-			FilePayload: protocol.FilePayload{
-				Name: fileInfo.Name(),
-				Size: fileInfo.Size(),
-				Data: []byte(encodedData),
-			},
-		}
 		c.Send(fileMsg)
 	}()
 }
 
+// FileMessage 读取文件并构造文件消息，供需要同步处理读取错误的调用方使用
+func (c *Client) FileMessage(msgType, recipient, groupName, filePath string) (protocol.Message, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	fileInfo, err := os.Stat(filePath) // 获取文件名等信息
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	encodedData := base64.StdEncoding.EncodeToString(fileData)
+
+	return protocol.Message{
+		Type:      msgType,
+		Sender:    c.username,
+		Recipient: recipient,
+		GroupName: groupName,
+		FilePayload: protocol.FilePayload{
+			Name: fileInfo.Name(),
+			Size: fileInfo.Size(),
+			Data: []byte(encodedData),
+		},
+	}, nil
+}
+
 // SaveFile 是一个处理文件保存
 func (c *Client) SaveFile(fileInfo protocol.FilePayload, savePath string) {
 	go func() {
 		decodedData, err := base64.StdEncoding.DecodeString(string(fileInfo.Data))
 		if err != nil {
-			fmt.Printf("错误：Base64解码失败: %v\n", err)
+			slog.Error("文件 Base64 解码失败", "file", fileInfo.Name, "error", err)
 			return
 		}
 
 		err = os.WriteFile(savePath, decodedData, 0644)
 		if err != nil {
-			fmt.Printf("错误：写入文件失败: %v\n", err)
+			slog.Error("写入文件失败", "path", savePath, "error", err)
 		} else {
-			fmt.Printf("文件 %s 已成功保存到 %s\n", fileInfo.Name, savePath)
+			slog.Info("文件已保存", "file", fileInfo.Name, "path", savePath)
 		}
 	}()
 }
@@ -0,0 +1,576 @@
+package client
+
+import (
+	"GoChat/pkg/e2e"
+	"GoChat/pkg/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownKey 是见过的某个用户的公钥
+type KnownKey struct {
+	Key       string    `json:"key"`
+	Verified  bool      `json:"verified"`   // 用户是否已核对过安全码
+	FirstSeen time.Time `json:"first_seen"` // 首次见到该公钥的时间
+}
+
+// e2eState 保存端到端加密的密钥和对端公钥
+type e2eState struct {
+	dir string // 密钥目录，每个本地用户一个子目录
+
+	mu       sync.Mutex
+	self     string              // 当前登录的用户名
+	identity *e2e.Identity       // 当前用户的密钥对，登录成功后加载
+	peers    map[string]string   // 服务器发布的在线用户公钥
+	known    map[string]KnownKey // 见过的公钥，首次见到时信任，变化时提醒
+	// plaintextOK 是用户确认可以不加密私聊的对端，对端重新发布公钥后清除
+	plaintextOK map[string]bool
+
+	encryptedGroups map[string][]string   // 启用端到端加密的群组及其成员
+	groups          map[string]*groupKeys // 当前用户所在加密群组的密钥
+}
+
+// groupKeys 是一个加密群组的密钥。成员中用户名最小的一人负责生成密钥，
+// 成员变化时重新生成并分发给其他成员，旧密钥保留一段时间用于解密途中的消息
+type groupKeys struct {
+	current    string                   // 发送消息使用的密钥编号
+	keys       map[string]*e2e.GroupKey // 按编号保存的密钥
+	order      []string                 // 密钥的加入顺序，超出 keepGroupKeys 时丢弃最早的
+	currentFor string                   // 当前密钥由本客户端生成时对应的成员列表，收到他人分发的密钥时为空
+}
+
+const keepGroupKeys = 8
+
+// errGroupKeyNotReady 表示还没有收到加密群组的密钥
+var errGroupKeyNotReady = errors.New("群组密钥尚未就绪，请稍后再试")
+
+// add 保存密钥并将其作为当前密钥，members 为本客户端生成密钥时的成员列表
+func (g *groupKeys) add(key *e2e.GroupKey, members string) {
+	if _, ok := g.keys[key.ID]; !ok {
+		g.keys[key.ID] = key
+		g.order = append(g.order, key.ID)
+		if len(g.order) > keepGroupKeys {
+			delete(g.keys, g.order[0])
+			g.order = g.order[1:]
+		}
+	}
+	g.current = key.ID
+	g.currentFor = members
+}
+
+// sealedContent 是加密前的消息内容
+type sealedContent struct {
+	Text string                `json:"text,omitempty"`
+	File *protocol.FilePayload `json:"file,omitempty"`
+}
+
+// DefaultKeyDir 返回用户配置目录下的默认密钥目录
+func DefaultKeyDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "GoChat", "keys"), nil
+}
+
+// EnableE2E 启用私聊端到端加密，密钥保存在 dir 中，登录成功后自动加载或生成并发布公钥
+func (c *Client) EnableE2E(dir string) {
+	c.e2e = &e2eState{dir: dir}
+}
+
+// E2EEnabled 返回当前连接是否已启用端到端加密
+func (c *Client) E2EEnabled() bool {
+	if c.e2e == nil {
+		return false
+	}
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	return c.e2e.identity != nil
+}
+
+// CanEncryptTo 返回发给 peer 的私聊是否会被加密
+func (c *Client) CanEncryptTo(peer string) bool {
+	_, ok := c.peerKey(peer)
+	return ok
+}
+
+// PlaintextDowngrade 返回 peer 是否曾经发布过公钥、现在却没有可用的公钥。
+// 服务器可以通过不转发公钥让私聊退回明文，此时私聊只有在用户通过 AllowPlaintextTo 确认后才会发送
+func (c *Client) PlaintextDowngrade(peer string) bool {
+	if c.e2e == nil {
+		return false
+	}
+	s := c.e2e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.identity == nil {
+		return false
+	}
+	if _, ok := s.peers[peer]; ok {
+		return false
+	}
+	_, known := s.known[peer]
+	return known && !s.plaintextOK[peer]
+}
+
+// AllowPlaintextTo 记录用户确认本次登录期间可以向 peer 发送未加密的私聊
+func (c *Client) AllowPlaintextTo(peer string) {
+	if c.e2e == nil {
+		return
+	}
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	if c.e2e.plaintextOK != nil {
+		c.e2e.plaintextOK[peer] = true
+	}
+}
+
+// SafetyNumber 返回与 peer 的安全码，以及是否已经核对过
+func (c *Client) SafetyNumber(peer string) (number string, verified bool, err error) {
+	if c.e2e == nil {
+		return "", false, errors.New("未启用端到端加密")
+	}
+	s := c.e2e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.identity == nil {
+		return "", false, errors.New("尚未登录")
+	}
+	peerKey, ok := s.peers[peer]
+	if !ok {
+		return "", false, fmt.Errorf("%s 不在线或没有发布公钥", peer)
+	}
+	known := s.known[peer]
+	verified = known.Key == peerKey && known.Verified
+	return e2e.SafetyNumber(s.self, s.identity.PublicKey(), peer, peerKey), verified, nil
+}
+
+// MarkVerified 记录已经与 peer 核对过安全码
+func (c *Client) MarkVerified(peer string) error {
+	if c.e2e == nil {
+		return errors.New("未启用端到端加密")
+	}
+	s := c.e2e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peerKey, ok := s.peers[peer]
+	if !ok {
+		return fmt.Errorf("%s 不在线或没有发布公钥", peer)
+	}
+	known := s.known[peer]
+	if known.Key != peerKey {
+		known = KnownKey{Key: peerKey, FirstSeen: time.Now()}
+	}
+	known.Verified = true
+	s.known[peer] = known
+	return s.saveKnown()
+}
+
+// peerKey 返回可以用于加密的对端公钥
+func (c *Client) peerKey(peer string) (string, bool) {
+	if c.e2e == nil {
+		return "", false
+	}
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	if c.e2e.identity == nil {
+		return "", false
+	}
+	key, ok := c.e2e.peers[peer]
+	return key, ok
+}
+
+// onLogin 在登录成功后加载当前用户的密钥并发布公钥
+func (c *Client) onLogin(username string) {
+	if c.e2e == nil {
+		return
+	}
+	s := c.e2e
+	userDir := filepath.Join(s.dir, pathName(username))
+	identity, err := e2e.LoadOrCreateIdentity(filepath.Join(userDir, "identity.json"))
+	if err != nil {
+		slog.Error("加载端到端加密密钥失败，私聊将不加密", "error", err)
+		return
+	}
+	known := make(map[string]KnownKey)
+	if data, err := os.ReadFile(filepath.Join(userDir, "known_keys.json")); err == nil {
+		if err := json.Unmarshal(data, &known); err != nil {
+			slog.Warn("解析已知公钥失败", "error", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("读取已知公钥失败", "error", err)
+	}
+
+	s.mu.Lock()
+	s.self = username
+	s.identity = identity
+	s.peers = make(map[string]string)
+	s.known = known
+	s.plaintextOK = make(map[string]bool)
+	s.groups = make(map[string]*groupKeys)
+	s.mu.Unlock()
+
+	c.Send(protocol.Message{Type: protocol.PublishKeyRequest, Sender: username, TextPayload: identity.PublicKey()})
+}
+
+// IsEncryptedGroup 返回群组是否启用了端到端加密
+func (c *Client) IsEncryptedGroup(group string) bool {
+	if c.e2e == nil {
+		return false
+	}
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	_, ok := c.e2e.encryptedGroups[group]
+	return ok
+}
+
+// GroupKeyReady 返回是否已经拿到加密群组的密钥，可以发送消息
+func (c *Client) GroupKeyReady(group string) bool {
+	if c.e2e == nil {
+		return false
+	}
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	g, ok := c.e2e.groups[group]
+	return ok && g.current != ""
+}
+
+// onPresence 更新在线用户的公钥和加密群组，返回需要提醒用户的公钥变化，
+// 以及当前用户负责分发的群组密钥消息
+func (c *Client) onPresence(tree protocol.TreePayload) (notices, outgoing []protocol.Message) {
+	if c.e2e == nil {
+		return nil, nil
+	}
+	s := c.e2e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encryptedGroups = make(map[string][]string, len(tree.EncryptedGroups))
+	for _, group := range tree.EncryptedGroups {
+		s.encryptedGroups[group] = tree.Groups[group]
+	}
+	if s.identity == nil {
+		return nil, nil
+	}
+
+	changed := false
+	s.peers = make(map[string]string, len(tree.Keys))
+	for user, key := range tree.Keys {
+		if user == s.self {
+			continue
+		}
+		s.peers[user] = key
+		delete(s.plaintextOK, user)
+		known, ok := s.known[user]
+		if ok && known.Key == key {
+			continue
+		}
+		if ok {
+			slog.Warn("用户的加密公钥发生了变化", "peer", user)
+			notices = append(notices, protocol.Message{
+				Type:      protocol.SystemMessage,
+				Sender:    "系统",
+				Recipient: s.self,
+				Timestamp: time.Now(),
+				TextPayload: fmt.Sprintf("警告: %s 的加密密钥已改变，可能是对方重新安装了客户端，也可能有人冒充对方。"+
+					"请打开与 %s 的私聊并重新核对安全码。", user, user),
+			})
+		}
+		s.known[user] = KnownKey{Key: key, FirstSeen: time.Now()}
+		changed = true
+	}
+	if changed {
+		if err := s.saveKnown(); err != nil {
+			slog.Error("保存已知公钥失败", "error", err)
+		}
+	}
+	return notices, s.rotateGroupKeys(tree.Keys)
+}
+
+// rotateGroupKeys 检查当前用户负责的加密群组，成员变化时生成新密钥并返回分发消息，调用者需持有锁
+func (s *e2eState) rotateGroupKeys(keys map[string]string) []protocol.Message {
+	for group := range s.groups {
+		if !slices.Contains(s.encryptedGroups[group], s.self) {
+			delete(s.groups, group)
+		}
+	}
+
+	var outgoing []protocol.Message
+	for group, members := range s.encryptedGroups {
+		if !slices.Contains(members, s.self) {
+			continue
+		}
+		g, ok := s.groups[group]
+		if !ok {
+			g = &groupKeys{keys: make(map[string]*e2e.GroupKey)}
+			s.groups[group] = g
+		}
+
+		// 只有发布了公钥的成员才能收到密钥
+		var eligible []string
+		for _, m := range members {
+			if keys[m] != "" {
+				eligible = append(eligible, m)
+			}
+		}
+		slices.Sort(eligible)
+		eligible = slices.Compact(eligible)
+		if len(eligible) == 0 || eligible[0] != s.self {
+			continue
+		}
+		signature := strings.Join(eligible, "\x00")
+		// 负责人变化时也要重新生成，离开的成员可能知道之前负责人分发的密钥
+		if g.current != "" && g.currentFor == signature {
+			continue
+		}
+
+		key, err := e2e.NewGroupKey()
+		if err != nil {
+			slog.Error("生成群组密钥失败", "group", group, "error", err)
+			continue
+		}
+		data, err := json.Marshal(key)
+		if err != nil {
+			continue
+		}
+		g.add(key, signature)
+		slog.Info("群组成员发生变化，已生成新的群组密钥", "group", group, "members", len(eligible))
+
+		for _, member := range eligible[1:] {
+			payload, err := s.identity.Seal(s.self, member, keys[member], data)
+			if err != nil {
+				slog.Error("加密群组密钥失败", "group", group, "member", member, "error", err)
+				continue
+			}
+			outgoing = append(outgoing, protocol.Message{
+				Type:      protocol.GroupKeyMessage,
+				Sender:    s.self,
+				Recipient: member,
+				GroupName: group,
+				Encrypted: payload,
+			})
+		}
+	}
+	return outgoing
+}
+
+// onGroupKey 保存其他成员分发的群组密钥
+func (c *Client) onGroupKey(msg *protocol.Message) {
+	if c.e2e == nil || msg.Encrypted == nil {
+		return
+	}
+	s := c.e2e
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.identity == nil || msg.Recipient != s.self {
+		return
+	}
+	if !slices.Contains(s.encryptedGroups[msg.GroupName], msg.Sender) {
+		slog.Warn("忽略非群组成员发来的群组密钥", "group", msg.GroupName, "sender", msg.Sender)
+		return
+	}
+
+	plaintext, peerKey, err := s.identity.Open(msg.Sender, msg.Recipient, msg.Encrypted)
+	if err != nil {
+		slog.Warn("解密群组密钥失败", "group", msg.GroupName, "sender", msg.Sender, "error", err)
+		return
+	}
+	if peerKey != s.peers[msg.Sender] {
+		slog.Warn("群组密钥使用的公钥与发送者当前的公钥不一致", "group", msg.GroupName, "sender", msg.Sender)
+		return
+	}
+	var key e2e.GroupKey
+	if err := json.Unmarshal(plaintext, &key); err != nil || len(key.Key) != 32 {
+		slog.Warn("群组密钥格式无效", "group", msg.GroupName, "sender", msg.Sender)
+		return
+	}
+
+	g, ok := s.groups[msg.GroupName]
+	if !ok {
+		g = &groupKeys{keys: make(map[string]*e2e.GroupKey)}
+		s.groups[msg.GroupName] = g
+	}
+	g.add(&key, "")
+	slog.Debug("收到新的群组密钥", "group", msg.GroupName, "sender", msg.Sender)
+}
+
+// encrypt 加密发给在线用户的私聊和发往加密群组的消息。私聊对方从未发布过公钥时保持明文，
+// 对方曾经有公钥但现在没有时，除非用户已经确认，否则拒绝发送
+func (c *Client) encrypt(msg *protocol.Message) error {
+	var payload *protocol.EncryptedPayload
+	switch msg.Type {
+	case protocol.PrivateMessage, protocol.PrivateFileMessage:
+		peerKey, ok := c.peerKey(msg.Recipient)
+		if !ok {
+			if c.PlaintextDowngrade(msg.Recipient) {
+				return fmt.Errorf("%s 之前使用端到端加密，但现在没有可用的公钥，为避免以明文发送，消息未发送", msg.Recipient)
+			}
+			return nil
+		}
+		plaintext, err := sealContent(msg)
+		if err != nil {
+			return err
+		}
+		c.e2e.mu.Lock()
+		identity := c.e2e.identity
+		c.e2e.mu.Unlock()
+		if payload, err = identity.Seal(msg.Sender, msg.Recipient, peerKey, plaintext); err != nil {
+			return err
+		}
+
+	case protocol.GroupMessage, protocol.GroupFileMessage:
+		if !c.IsEncryptedGroup(msg.GroupName) {
+			return nil
+		}
+		c.e2e.mu.Lock()
+		var key *e2e.GroupKey
+		if g, ok := c.e2e.groups[msg.GroupName]; ok {
+			key = g.keys[g.current]
+		}
+		c.e2e.mu.Unlock()
+		if key == nil {
+			return errGroupKeyNotReady
+		}
+		plaintext, err := sealContent(msg)
+		if err != nil {
+			return err
+		}
+		if payload, err = e2e.SealGroup(key, msg.GroupName, msg.Sender, plaintext); err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	msg.Encrypted = payload
+	msg.TextPayload = ""
+	msg.FilePayload = protocol.FilePayload{}
+	return nil
+}
+
+// sealContent 将消息的文字和文件内容编码为待加密的明文
+func sealContent(msg *protocol.Message) ([]byte, error) {
+	content := sealedContent{Text: msg.TextPayload}
+	if msg.Type == protocol.PrivateFileMessage || msg.Type == protocol.GroupFileMessage {
+		file := msg.FilePayload
+		content.File = &file
+	}
+	return json.Marshal(content)
+}
+
+// openContent 将解密后的明文还原到消息中
+func openContent(msg *protocol.Message, plaintext []byte) error {
+	var content sealedContent
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return err
+	}
+	msg.TextPayload = content.Text
+	if content.File != nil {
+		msg.FilePayload = *content.File
+	}
+	return nil
+}
+
+// decrypt 就地解密收到的私聊或群聊，失败时将消息替换为说明原因的文本
+func (c *Client) decrypt(msg *protocol.Message) {
+	if msg.Encrypted == nil {
+		return
+	}
+	var err error
+	switch msg.Type {
+	case protocol.GroupMessage, protocol.GroupFileMessage:
+		if err = c.openGroup(msg); err != nil {
+			msg.Type = protocol.GroupMessage
+		}
+	default:
+		if err = c.open(msg); err != nil {
+			msg.Type = protocol.PrivateMessage
+		}
+	}
+	if err != nil {
+		slog.Warn("解密消息失败", "sender", msg.Sender, "error", err)
+		msg.TextPayload = fmt.Sprintf("[无法解密的加密消息: %v]", err)
+		msg.FilePayload = protocol.FilePayload{}
+	}
+}
+
+func (c *Client) openGroup(msg *protocol.Message) error {
+	if c.e2e == nil {
+		return errors.New("未启用端到端加密")
+	}
+	c.e2e.mu.Lock()
+	var key *e2e.GroupKey
+	if g, ok := c.e2e.groups[msg.GroupName]; ok {
+		key = g.keys[msg.Encrypted.KeyID]
+	}
+	c.e2e.mu.Unlock()
+	if key == nil {
+		return errors.New("没有对应的群组密钥")
+	}
+	plaintext, err := e2e.OpenGroup(key, msg.GroupName, msg.Sender, msg.Encrypted)
+	if err != nil {
+		return err
+	}
+	return openContent(msg, plaintext)
+}
+
+func (c *Client) open(msg *protocol.Message) error {
+	if c.e2e == nil {
+		return errors.New("未启用端到端加密")
+	}
+	c.e2e.mu.Lock()
+	identity, self := c.e2e.identity, c.e2e.self
+	c.e2e.mu.Unlock()
+	if identity == nil {
+		return errors.New("尚未加载密钥")
+	}
+
+	plaintext, peerKey, err := identity.Open(msg.Sender, msg.Recipient, msg.Encrypted)
+	if err != nil {
+		return err
+	}
+	peer := msg.Sender
+	if peer == self {
+		peer = msg.Recipient
+	}
+	c.e2e.mu.Lock()
+	known, ok := c.e2e.known[peer]
+	c.e2e.mu.Unlock()
+	if ok && known.Key != peerKey {
+		return fmt.Errorf("消息使用的密钥与 %s 当前的密钥不一致", peer)
+	}
+
+	return openContent(msg, plaintext)
+}
+
+// saveKnown 保存见过的公钥，调用者需持有锁
+func (s *e2eState) saveKnown() error {
+	data, err := json.MarshalIndent(s.known, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, pathName(s.self), "known_keys.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// pathName 将用户名转换为安全的目录名
+func pathName(username string) string {
+	name := url.PathEscape(username)
+	if name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
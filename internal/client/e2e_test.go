@@ -0,0 +1,319 @@
+package client
+
+import (
+	"GoChat/pkg/e2e"
+	"GoChat/pkg/protocol"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newE2EClient 创建已登录并加载了密钥的客户端，不连接服务器
+func newE2EClient(t *testing.T, username string) *Client {
+	t.Helper()
+	c := NewClient()
+	c.SetUsername(username)
+	c.EnableE2E(t.TempDir())
+	c.onLogin(username)
+	if !c.E2EEnabled() {
+		t.Fatalf("%s 没有加载密钥", username)
+	}
+	return c
+}
+
+// publicKey 返回客户端发布的公钥
+func (c *Client) publicKey() string {
+	c.e2e.mu.Lock()
+	defer c.e2e.mu.Unlock()
+	return c.e2e.identity.PublicKey()
+}
+
+// presence 让客户端收到一次在线列表，keys 为在线用户发布的公钥
+func presence(c *Client, keys map[string]string) {
+	users := make([]string, 0, len(keys))
+	for user := range keys {
+		users = append(users, user)
+	}
+	c.onPresence(protocol.TreePayload{Users: users, Keys: keys})
+}
+
+func TestPrivateMessageEncryption(t *testing.T) {
+	alice, bob := newE2EClient(t, "alice"), newE2EClient(t, "bob")
+	keys := map[string]string{"alice": alice.publicKey(), "bob": bob.publicKey()}
+	presence(alice, keys)
+	presence(bob, keys)
+
+	file := protocol.FilePayload{Name: "a.txt", Size: 5, Data: []byte("hello")}
+	tests := []struct {
+		name string
+		msg  protocol.Message
+	}{
+		{"文字", protocol.Message{Type: protocol.PrivateMessage, Sender: "alice", Recipient: "bob", TextPayload: "你好"}},
+		{"文件", protocol.Message{Type: protocol.PrivateFileMessage, Sender: "alice", Recipient: "bob", FilePayload: file}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			if err := alice.encrypt(&msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Encrypted == nil || msg.TextPayload != "" || msg.FilePayload.Data != nil {
+				t.Fatalf("加密后的消息仍包含明文: %+v", msg)
+			}
+			for _, reader := range []*Client{bob, alice} {
+				got := msg
+				reader.decrypt(&got)
+				if got.TextPayload != tt.msg.TextPayload || !bytes.Equal(got.FilePayload.Data, tt.msg.FilePayload.Data) ||
+					got.FilePayload.Name != tt.msg.FilePayload.Name {
+					t.Fatalf("%s 解密得到 %+v, 期望 %+v", reader.username, got, tt.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestPrivateMessageDowngrade(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(alice *Client, bobKey string)
+		wantEncrypted bool
+		wantErr       bool
+	}{
+		{"对方在线并发布了公钥", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"bob": bobKey})
+		}, true, false},
+		{"对方从未发布过公钥", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"carol": bobKey})
+		}, false, false},
+		{"对方的公钥消失", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"bob": bobKey})
+			presence(alice, map[string]string{})
+		}, false, true},
+		{"用户确认后以明文发送", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"bob": bobKey})
+			presence(alice, map[string]string{})
+			alice.AllowPlaintextTo("bob")
+		}, false, false},
+		{"对方重新发布公钥后恢复加密", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"bob": bobKey})
+			presence(alice, map[string]string{})
+			alice.AllowPlaintextTo("bob")
+			presence(alice, map[string]string{"bob": bobKey})
+		}, true, false},
+		{"确认只在公钥再次消失前有效", func(alice *Client, bobKey string) {
+			presence(alice, map[string]string{"bob": bobKey})
+			presence(alice, map[string]string{})
+			alice.AllowPlaintextTo("bob")
+			presence(alice, map[string]string{"bob": bobKey})
+			presence(alice, map[string]string{})
+		}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice, bob := newE2EClient(t, "alice"), newE2EClient(t, "bob")
+			tt.setup(alice, bob.publicKey())
+			msg := protocol.Message{Type: protocol.PrivateMessage, Sender: "alice", Recipient: "bob", TextPayload: "hi"}
+			err := alice.encrypt(&msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encrypt() 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if alice.PlaintextDowngrade("bob") != tt.wantErr {
+				t.Errorf("PlaintextDowngrade() = %v, 期望 %v", !tt.wantErr, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (msg.Encrypted != nil) != tt.wantEncrypted {
+				t.Fatalf("消息是否加密 = %v, 期望 %v", msg.Encrypted != nil, tt.wantEncrypted)
+			}
+		})
+	}
+}
+
+func TestPrivateMessageKeyChanged(t *testing.T) {
+	alice, bob, mallory := newE2EClient(t, "alice"), newE2EClient(t, "bob"), newE2EClient(t, "bob")
+	presence(bob, map[string]string{"alice": alice.publicKey()})
+	presence(alice, map[string]string{"bob": bob.publicKey()})
+
+	// 服务器把 bob 的公钥换成 mallory 的，alice 会收到警告
+	notices, _ := alice.onPresence(protocol.TreePayload{Keys: map[string]string{"bob": mallory.publicKey()}})
+	if len(notices) != 1 || !strings.Contains(notices[0].TextPayload, "bob 的加密密钥已改变") {
+		t.Fatalf("公钥变化时没有提醒: %+v", notices)
+	}
+
+	// bob 仍记着 alice 的旧公钥，用其它密钥冒充 alice 的消息无法通过校验
+	eve := newE2EClient(t, "alice")
+	presence(eve, map[string]string{"bob": bob.publicKey()})
+	msg := protocol.Message{Type: protocol.PrivateMessage, Sender: "alice", Recipient: "bob", TextPayload: "我是 alice"}
+	if err := eve.encrypt(&msg); err != nil {
+		t.Fatal(err)
+	}
+	bob.decrypt(&msg)
+	if !strings.Contains(msg.TextPayload, "无法解密") {
+		t.Fatalf("冒充的消息被解密: %q", msg.TextPayload)
+	}
+}
+
+// groupPresence 让客户端收到加密群组 dev 的成员列表，返回客户端需要分发的群组密钥
+func groupPresence(c *Client, keys map[string]string, members ...string) []protocol.Message {
+	_, outgoing := c.onPresence(protocol.TreePayload{
+		Keys:            keys,
+		Groups:          map[string][]string{"dev": members},
+		EncryptedGroups: []string{"dev"},
+	})
+	return outgoing
+}
+
+// recipients 返回群组密钥消息的接收者
+func recipients(messages []protocol.Message) []string {
+	var names []string
+	for _, msg := range messages {
+		names = append(names, msg.Recipient)
+	}
+	return names
+}
+
+func TestRotateGroupKeys(t *testing.T) {
+	clients := map[string]*Client{}
+	keys := map[string]string{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		clients[name] = newE2EClient(t, name)
+		keys[name] = clients[name].publicKey()
+	}
+	without := func(name string) map[string]string {
+		rest := maps.Clone(keys)
+		delete(rest, name)
+		return rest
+	}
+
+	tests := []struct {
+		name    string
+		client  string
+		keys    map[string]string
+		members []string
+		want    []string // 需要分发密钥的成员
+	}{
+		{"用户名最小的成员生成密钥", "alice", keys, []string{"carol", "alice", "bob"}, []string{"bob", "carol"}},
+		{"成员没有变化时不重新生成", "alice", keys, []string{"carol", "alice", "bob"}, nil},
+		{"其他成员不生成密钥", "bob", keys, []string{"alice", "bob", "carol"}, nil},
+		{"有成员离开时重新生成", "alice", keys, []string{"alice", "bob"}, []string{"bob"}},
+		{"没有公钥的成员不参与", "bob", without("alice"), []string{"alice", "bob", "carol"}, []string{"carol"}},
+		{"只剩自己时也生成密钥", "carol", keys, []string{"carol"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clients[tt.client]
+			outgoing := groupPresence(c, tt.keys, tt.members...)
+			if got := recipients(outgoing); !slices.Equal(got, tt.want) {
+				t.Fatalf("分发给 %q, 期望 %q", got, tt.want)
+			}
+			for _, msg := range outgoing {
+				if msg.Type != protocol.GroupKeyMessage || msg.GroupName != "dev" || msg.Encrypted == nil {
+					t.Fatalf("群组密钥消息格式错误: %+v", msg)
+				}
+			}
+		})
+	}
+	if !clients["carol"].GroupKeyReady("dev") {
+		t.Error("只剩自己时没有生成群组密钥")
+	}
+}
+
+func TestGroupKeyDistribution(t *testing.T) {
+	alice, bob, carol, mallory := newE2EClient(t, "alice"), newE2EClient(t, "bob"), newE2EClient(t, "carol"), newE2EClient(t, "mallory")
+	keys := map[string]string{"alice": alice.publicKey(), "bob": bob.publicKey(), "carol": carol.publicKey(), "mallory": mallory.publicKey()}
+	members := []string{"alice", "bob", "carol"}
+
+	// deliver 把 alice 分发的密钥交给对应的成员
+	deliver := func(outgoing []protocol.Message) {
+		for _, msg := range outgoing {
+			for _, c := range []*Client{bob, carol} {
+				if c.username == msg.Recipient {
+					c.onGroupKey(&msg)
+				}
+			}
+		}
+	}
+	for _, c := range []*Client{bob, carol} {
+		groupPresence(c, keys, members...)
+	}
+	deliver(groupPresence(alice, keys, members...))
+	for _, c := range []*Client{alice, bob, carol} {
+		if !c.GroupKeyReady("dev") {
+			t.Fatalf("%s 没有拿到群组密钥", c.username)
+		}
+	}
+
+	send := func(from *Client, text string) protocol.Message {
+		t.Helper()
+		msg := protocol.Message{Type: protocol.GroupMessage, Sender: from.username, GroupName: "dev", TextPayload: text}
+		if err := from.encrypt(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Encrypted == nil || msg.TextPayload != "" {
+			t.Fatalf("群聊消息没有加密: %+v", msg)
+		}
+		return msg
+	}
+	read := func(c *Client, msg protocol.Message) string {
+		c.decrypt(&msg)
+		return msg.TextPayload
+	}
+
+	before := send(bob, "carol 还在")
+	for _, c := range []*Client{alice, carol} {
+		if got := read(c, before); got != "carol 还在" {
+			t.Fatalf("%s 解密得到 %q", c.username, got)
+		}
+	}
+
+	// 非成员发来的群组密钥被忽略，bob 仍使用 alice 分发的密钥
+	forged, err := e2e.NewGroupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := mallory.e2e.identity.Seal("mallory", "bob", bob.publicKey(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.onGroupKey(&protocol.Message{Type: protocol.GroupKeyMessage, Sender: "mallory", Recipient: "bob", GroupName: "dev", Encrypted: payload})
+	if got := read(alice, send(bob, "密钥没有被替换")); got != "密钥没有被替换" {
+		t.Fatalf("bob 接受了非成员的密钥: %q", got)
+	}
+
+	// carol 离开后重新生成密钥，carol 无法解密之后的消息
+	members = []string{"alice", "bob"}
+	groupPresence(bob, keys, members...)
+	deliver(groupPresence(alice, keys, members...))
+	after := send(bob, "carol 已离开")
+	if got := read(alice, after); got != "carol 已离开" {
+		t.Fatalf("alice 解密得到 %q", got)
+	}
+	if got := read(carol, after); !strings.Contains(got, "无法解密") {
+		t.Fatalf("离开的成员解密了新消息: %q", got)
+	}
+	// 旧密钥仍保留，途中的旧消息可以解密
+	if got := read(alice, before); got != "carol 还在" {
+		t.Fatalf("旧消息无法解密: %q", got)
+	}
+}
+
+func TestGroupKeyNotReady(t *testing.T) {
+	alice, bob := newE2EClient(t, "alice"), newE2EClient(t, "bob")
+	groupPresence(bob, map[string]string{"alice": alice.publicKey(), "bob": bob.publicKey()}, "alice", "bob")
+	if bob.GroupKeyReady("dev") {
+		t.Fatal("还没有收到 alice 分发的密钥")
+	}
+	msg := protocol.Message{Type: protocol.GroupMessage, Sender: "bob", GroupName: "dev", TextPayload: "hi"}
+	if err := bob.encrypt(&msg); !errors.Is(err, errGroupKeyNotReady) {
+		t.Fatalf("encrypt() 错误 = %v, 期望 %v", err, errGroupKeyNotReady)
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// NewClientFromEnv 创建客户端，并按照环境变量和用户配置目录启用各项功能，供各个客户端程序共用:
+//   - GOCHAT_TLS_CA: 服务器使用自建 CA 或自签名证书时需要信任的 PEM 证书
+//   - GOCHAT_TLS_CERT、GOCHAT_TLS_KEY: 服务器启用双向 TLS 时使用的客户端证书
+//   - 用户配置目录中的证书指纹记录和端到端加密密钥，无法使用时只记录警告
+func NewClientFromEnv() (*Client, error) {
+	c := NewClient()
+	if caFile := os.Getenv("GOCHAT_TLS_CA"); caFile != "" {
+		pool, err := LoadRootCAs(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 CA 证书失败: %w", err)
+		}
+		c.SetRootCAs(pool)
+	}
+	if certFile, keyFile := os.Getenv("GOCHAT_TLS_CERT"), os.Getenv("GOCHAT_TLS_KEY"); certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		c.SetCertificate(cert)
+	}
+	// 记录自签名服务器的证书指纹，证书改变时提醒用户
+	if path, err := DefaultPinStorePath(); err != nil {
+		slog.Warn("无法确定证书指纹文件位置，不记录服务器证书", "error", err)
+	} else if pins, err := OpenPinStore(path); err != nil {
+		slog.Warn("读取证书指纹文件失败，不记录服务器证书", "error", err)
+	} else {
+		c.SetPinStore(pins)
+	}
+	// 私聊端到端加密，密钥保存在用户配置目录中
+	if dir, err := DefaultKeyDir(); err != nil {
+		slog.Warn("无法确定密钥目录，私聊将不加密", "error", err)
+	} else {
+		c.EnableE2E(dir)
+	}
+	return c, nil
+}
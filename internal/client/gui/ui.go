@@ -1,8 +1,12 @@
-package client
+package gui
 
 import (
+	"GoChat/internal/client"
+	"GoChat/pkg/discovery"
 	"GoChat/pkg/protocol"
+	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
 	"sync"
 	"time"
@@ -41,7 +45,7 @@ func (e *customEntry) TappedSecondary(pe *fyne.PointEvent) {
 }
 
 type UI struct {
-	client *Client
+	client *client.Client
 	app    fyne.App
 	window fyne.Window
 
@@ -49,6 +53,7 @@ type UI struct {
 	chatTabs  *container.DocTabs
 
 	username          string
+	loginError        string // 服务器拒绝登录的原因，连接断开时展示
 	usersListBinding  binding.StringList
 	groupsListBinding binding.StringList
 
@@ -57,7 +62,7 @@ type UI struct {
 }
 
 // NewUI 创建并初始化UI
-func NewUI(app fyne.App, c *Client) *UI {
+func NewUI(app fyne.App, c *client.Client) *UI {
 	w := app.NewWindow("Go Chat")
 	w.SetMaster()
 
@@ -89,15 +94,22 @@ func (ui *UI) createLoginView() fyne.CanvasObject {
 	serverAddrEntry.SetPlaceHolder("输入服务器地址，如: 127.0.0.1:8080")
 	usernameEntry := widget.NewEntry()
 	usernameEntry.SetPlaceHolder("输入用户名")
+	if ui.client.HasCertificate() {
+		usernameEntry.SetPlaceHolder("已配置客户端证书，用户名可留空")
+	}
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("密码 (未注册的用户名可留空)")
+	tlsCheck := widget.NewCheck("使用 TLS 加密连接", nil)
 	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
 
 	var loginButton *widget.Button
 	loginButton = widget.NewButton("登录", func() {
 		username := usernameEntry.Text
 		server := serverAddrEntry.Text
 
-		// 校验用户名
-		if username == "" {
+		// 校验用户名，使用客户端证书登录时用户名由服务器根据证书确定
+		if username == "" && !ui.client.HasCertificate() {
 			dialog.ShowError(fmt.Errorf("用户名不能为空"), ui.window)
 			return
 		}
@@ -111,36 +123,59 @@ func (ui *UI) createLoginView() fyne.CanvasObject {
 		loginButton.Disable()
 		statusLabel.SetText("正在连接服务器...")
 
+		useTLS := tlsCheck.Checked
 		go func() {
-			if err := ui.client.Connect(server); err != nil {
+			if err := ui.client.Connect(server, useTLS); err != nil {
+				slog.Warn("连接服务器失败", "address", server, "tls", useTLS, "error", err)
 				fyne.Do(func() {
-					dialog.ShowError(err, ui.window)
 					loginButton.Enable()
-					statusLabel.SetText("连接失败")
+					statusLabel.SetText("连接失败: " + err.Error())
+					var changed *client.CertificateChangedError
+					if errors.As(err, &changed) {
+						ui.showCertificateChangedDialog(changed, loginButton.OnTapped)
+						return
+					}
+					dialog.ShowError(err, ui.window)
 				})
 				return
 			}
 
 			fyne.Do(func() {
-				ui.switchToChatView(username)
+				ui.switchToChatView(username, passwordEntry.Text)
 			})
 		}()
 	})
 
+	// 选择局域网内发现的服务器后自动填写地址并登录
+	discoverButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
+		ui.showDiscoveryDialog(func(server discovery.Server) {
+			serverAddrEntry.SetText(server.Address)
+			tlsCheck.SetChecked(server.TLS)
+			if usernameEntry.Text == "" && !ui.client.HasCertificate() {
+				statusLabel.SetText("已选择服务器 " + server.Name + "，请输入用户名后登录")
+				ui.window.Canvas().Focus(usernameEntry)
+				return
+			}
+			loginButton.OnTapped()
+		})
+	})
+
 	return container.NewCenter(container.NewVBox(
 		widget.NewLabel("欢迎来到聊天室"),
-		serverAddrEntry,
+		container.NewBorder(nil, nil, nil, discoverButton, serverAddrEntry),
 		usernameEntry,
+		passwordEntry,
+		tlsCheck,
 		loginButton,
+		widget.NewButton("已信任的服务器证书", ui.showPinnedServersDialog),
 		statusLabel,
 	))
 }
 
 // switchToChatView 负责创建主聊天界面
-func (ui *UI) switchToChatView(username string) {
-	ui.username = username
-	ui.client.SetUsername(username)
-	ui.window.SetTitle(fmt.Sprintf("Go Chat - %s", ui.username))
+func (ui *UI) switchToChatView(username, password string) {
+	ui.setUsername(username)
+	ui.loginError = ""
 
 	ui.accordion = ui.createAccordion()
 	createGroupBtn := widget.NewButton("创建群组", ui.showCreateGroupDialog)
@@ -149,7 +184,7 @@ func (ui *UI) switchToChatView(username string) {
 	ui.chatTabs = container.NewDocTabs()
 	ui.chatTabs.OnClosed = func(item *container.TabItem) {
 		name := item.Text
-		fmt.Printf("标签页 '%s' 已关闭\n", name)
+		slog.Debug("标签页已关闭", "tab", name)
 
 		ui.chatHistoriesMutex.Lock()
 		delete(ui.chatHistories, name)
@@ -175,10 +210,17 @@ func (ui *UI) switchToChatView(username string) {
 	ui.client.Start()
 	ui.startBackgroundTasks()
 
-	loginMsg := protocol.Message{Type: protocol.LoginRequest, Sender: ui.username}
+	loginMsg := protocol.Message{Type: protocol.LoginRequest, Sender: ui.username, TextPayload: password}
 	ui.client.Send(loginMsg)
 }
 
+// setUsername 更新当前用户名和窗口标题
+func (ui *UI) setUsername(username string) {
+	ui.username = username
+	ui.client.SetUsername(username)
+	ui.window.SetTitle(fmt.Sprintf("Go Chat - %s", ui.username))
+}
+
 // openChatTab 确保一个聊天标签页被创建并选中
 func (ui *UI) openChatTab(name string) {
 	for _, tab := range ui.chatTabs.Items {
@@ -226,6 +268,9 @@ func (ui *UI) createChatTabContent(name string) fyne.CanvasObject {
 		if input.Text == "" {
 			return
 		}
+		if !ui.readyToSend(name) {
+			return
+		}
 		var msgType, recipient, groupName string
 		if name == "世界大厅" {
 			msgType = protocol.BroadcastMessage
@@ -242,7 +287,13 @@ func (ui *UI) createChatTabContent(name string) fyne.CanvasObject {
 	fileBtn := widget.NewButtonWithIcon("", theme.FileIcon(), func() {
 		ui.showFileOpenDialog(name)
 	})
-	inputBox := container.NewBorder(nil, nil, nil, container.NewHBox(sendBtn, fileBtn), input)
+	buttons := container.NewHBox(sendBtn, fileBtn)
+	if name != "世界大厅" && !ui.isGroup(name) {
+		buttons.Add(widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
+			ui.showSafetyNumberDialog(name)
+		}))
+	}
+	inputBox := container.NewBorder(nil, nil, nil, buttons, input)
 	input.OnSubmitted = func(_ string) { sendBtn.OnTapped() }
 
 	return container.NewBorder(nil, inputBox, nil, nil, historyList)
@@ -256,7 +307,7 @@ func (ui *UI) createAccordion() *widget.Accordion {
 	usersList.OnSelected = func(id widget.ListItemID) {
 		selectedUsername, _ := ui.usersListBinding.GetValue(id)
 		usersList.Unselect(id)
-		fmt.Printf("准备与 %s 私聊...\n", selectedUsername)
+		slog.Debug("打开私聊", "peer", selectedUsername)
 		ui.openChatTab(selectedUsername)
 	}
 
@@ -267,7 +318,11 @@ func (ui *UI) createAccordion() *widget.Accordion {
 	groupsList.OnSelected = func(id widget.ListItemID) {
 		groupName, _ := ui.groupsListBinding.GetValue(id)
 		groupsList.Unselect(id)
-		dialog.ShowConfirm("加入群组", fmt.Sprintf("您想加入群组 '%s' 吗？", groupName), func(join bool) {
+		question := fmt.Sprintf("您想加入群组 '%s' 吗？", groupName)
+		if ui.client.IsEncryptedGroup(groupName) {
+			question += "\n该群组已启用端到端加密，加入后需等待其他成员分发密钥才能收发消息。"
+		}
+		dialog.ShowConfirm("加入群组", question, func(join bool) {
 			if !join {
 				return
 			}
@@ -294,6 +349,13 @@ func (ui *UI) startBackgroundTasks() {
 			localMsg := msg
 			fyne.Do(func() {
 				switch localMsg.Type {
+				case protocol.LoginResponse:
+					if localMsg.TextPayload != "" {
+						ui.loginError = localMsg.TextPayload
+					} else if localMsg.Recipient != "" && localMsg.Recipient != ui.username {
+						// 服务器使用证书中的名称作为用户名
+						ui.setUsername(localMsg.Recipient)
+					}
 				case protocol.TreeUpdate:
 					var otherUsers []string
 					for _, user := range localMsg.TreePayload.Users {
@@ -309,7 +371,7 @@ func (ui *UI) startBackgroundTasks() {
 					}
 					ui.groupsListBinding.Set(groupNames)
 
-				case protocol.BroadcastMessage:
+				case protocol.BroadcastMessage, protocol.SystemMessage:
 					ui.addMessage("世界大厅", localMsg)
 				case protocol.GroupMessage:
 					ui.addMessage(localMsg.GroupName, localMsg)
@@ -338,7 +400,13 @@ func (ui *UI) startBackgroundTasks() {
 			})
 		}
 		fyne.Do(func() {
-			dialog.ShowInformation("连接断开", "您已与服务器断开连接。", ui.window)
+			if ui.loginError != "" {
+				dialog.ShowError(fmt.Errorf("登录失败: %s", ui.loginError), ui.window)
+			} else if err := ui.client.Err(); err != nil {
+				dialog.ShowError(fmt.Errorf("连接断开: %w", err), ui.window)
+			} else {
+				dialog.ShowInformation("连接断开", "您已与服务器断开连接。", ui.window)
+			}
 			ui.window.SetContent(ui.createLoginView())
 			ui.window.Resize(fyne.NewSize(400, 200))
 		})
@@ -347,16 +415,53 @@ func (ui *UI) startBackgroundTasks() {
 
 func (ui *UI) showCreateGroupDialog() {
 	entry := widget.NewEntry()
+	encryptedCheck := widget.NewCheck("端到端加密", nil)
+	if !ui.client.E2EEnabled() {
+		encryptedCheck.Disable()
+	}
 	dialog.ShowForm("创建新群组", "创建", "取消", []*widget.FormItem{
 		widget.NewFormItem("群组名", entry),
+		widget.NewFormItem("", encryptedCheck),
 	}, func(create bool) {
 		if !create || entry.Text == "" {
 			return
 		}
-		ui.client.SendChatMessage(protocol.CreateGroupRequest, "", "", entry.Text)
+		msgType := protocol.CreateGroupRequest
+		if encryptedCheck.Checked {
+			msgType = protocol.CreateEncryptedGroupRequest
+		}
+		ui.client.SendChatMessage(msgType, "", "", entry.Text)
 	}, ui.window)
 }
 
+// readyToSend 检查加密群组的密钥是否就绪，未就绪时提示用户；
+// 私聊对方的公钥消失时，需要用户确认后才能以明文发送
+func (ui *UI) readyToSend(name string) bool {
+	if name != "世界大厅" && !ui.isGroup(name) && ui.client.PlaintextDowngrade(name) {
+		dialog.ShowConfirm("私聊将不加密",
+			fmt.Sprintf("%s 之前使用端到端加密，但服务器现在没有提供对方的公钥，可能是对方换了不支持加密的客户端，也可能是服务器有意让消息以明文发送。\n\n"+
+				"确认后本次登录期间发给 %s 的私聊将不加密，请再次发送。", name, name),
+			func(ok bool) {
+				if ok {
+					ui.client.AllowPlaintextTo(name)
+				}
+			}, ui.window)
+		return false
+	}
+	if !ui.isGroup(name) || !ui.client.IsEncryptedGroup(name) {
+		return true
+	}
+	if !ui.client.E2EEnabled() {
+		dialog.ShowInformation("无法发送", "该群组已启用端到端加密，但本客户端没有可用的密钥。", ui.window)
+		return false
+	}
+	if !ui.client.GroupKeyReady(name) {
+		dialog.ShowInformation("无法发送", "群组密钥尚未就绪，请稍后再试。", ui.window)
+		return false
+	}
+	return true
+}
+
 func (ui *UI) addMessage(tabName string, msg protocol.Message) {
 	ui.chatHistoriesMutex.Lock()
 	history, ok := ui.chatHistories[tabName]
@@ -371,7 +476,11 @@ func (ui *UI) addMessage(tabName string, msg protocol.Message) {
 
 	if history != nil {
 		timestampStr := msg.Timestamp.Format("15:04:05")
-		formattedMsg := fmt.Sprintf("[%s] %s: %s", timestampStr, msg.Sender, msg.TextPayload)
+		sender := msg.Sender
+		if msg.Type == protocol.PrivateMessage && msg.Encrypted == nil && ui.client.E2EEnabled() {
+			sender += " (未加密)"
+		}
+		formattedMsg := fmt.Sprintf("[%s] %s: %s", timestampStr, sender, msg.TextPayload)
 		history.Append(formattedMsg)
 	}
 }
@@ -390,6 +499,9 @@ func (ui *UI) showFileOpenDialog(targetName string) {
 		if readCloser == nil {
 			return
 		}
+		if !ui.readyToSend(targetName) {
+			return
+		}
 
 		filePath := readCloser.URI().Path()
 
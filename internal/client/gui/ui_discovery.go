@@ -0,0 +1,94 @@
+package gui
+
+import (
+	"GoChat/pkg/discovery"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// discoveryTimeout 是等待局域网服务器回复的时间
+const discoveryTimeout = 1500 * time.Millisecond
+
+// showDiscoveryDialog 搜索局域网内的服务器，用户选择后调用 connect
+func (ui *UI) showDiscoveryDialog(connect func(server discovery.Server)) {
+	var servers []discovery.Server
+	status := widget.NewLabel("正在搜索局域网内的服务器...")
+	status.Wrapping = fyne.TextWrapWord
+
+	var d *dialog.CustomDialog
+	list := widget.NewList(
+		func() int { return len(servers) },
+		func() fyne.CanvasObject {
+			name := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			detail := widget.NewLabel("")
+			detail.Wrapping = fyne.TextWrapBreak
+			return container.NewVBox(name, detail)
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			server := servers[id]
+			labels := o.(*fyne.Container)
+			labels.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s (%s)", server.Name, server.Address))
+			labels.Objects[1].(*widget.Label).SetText(ui.describeDiscovered(server))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		d.Hide()
+		connect(servers[id])
+	}
+
+	var search func()
+	search = func() {
+		status.SetText("正在搜索局域网内的服务器...")
+		go func() {
+			found, err := discovery.Discover(discovery.DefaultPort, discoveryTimeout)
+			if err != nil {
+				slog.Warn("搜索局域网服务器失败", "error", err)
+			}
+			fyne.Do(func() {
+				servers = found
+				list.UnselectAll()
+				list.Refresh()
+				switch {
+				case err != nil:
+					status.SetText("搜索失败: " + err.Error())
+				case len(servers) == 0:
+					status.SetText("没有找到服务器。服务器需要开启局域网发现，且与本机位于同一网段。")
+				default:
+					status.SetText(fmt.Sprintf("找到 %d 个服务器，点击即可连接", len(servers)))
+				}
+			})
+		}()
+	}
+
+	refresh := widget.NewButtonWithIcon("重新搜索", theme.ViewRefreshIcon(), search)
+	content := container.NewBorder(status, refresh, nil, nil, list)
+	d = dialog.NewCustom("局域网服务器", "关闭", content, ui.window)
+	d.Resize(fyne.NewSize(560, 360))
+	d.Show()
+	search()
+}
+
+// describeDiscovered 描述服务器的加密方式，并与已信任的证书指纹比较
+func (ui *UI) describeDiscovered(server discovery.Server) string {
+	if !server.TLS {
+		return "未加密"
+	}
+	text := "TLS 加密，证书指纹: " + server.Fingerprint
+	if pins := ui.client.PinStore(); pins != nil {
+		if pinned, ok := pins.Lookup(server.Address); ok {
+			if pinned.Fingerprint == server.Fingerprint {
+				text += "\n与已信任的证书一致"
+			} else {
+				text += "\n注意: 与之前信任的证书不一致！"
+			}
+		}
+	}
+	return text
+}
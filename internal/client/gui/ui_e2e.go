@@ -0,0 +1,64 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showSafetyNumberDialog 展示与 peer 的安全码，双方核对一致后可以标记为已验证
+func (ui *UI) showSafetyNumberDialog(peer string) {
+	number, verified, err := ui.client.SafetyNumber(peer)
+	if err != nil {
+		dialog.ShowInformation("端到端加密", fmt.Sprintf("与 %s 的私聊未加密: %v", peer, err), ui.window)
+		return
+	}
+
+	digits := widget.NewLabelWithStyle(safetyNumberLines(number), fyne.TextAlignCenter, fyne.TextStyle{Monospace: true, Bold: true})
+	hint := widget.NewLabel(fmt.Sprintf("与 %s 的私聊已端到端加密。\n"+
+		"请当面或通过电话等可信渠道与对方核对下面的安全码，两边一致说明没有人冒充。", peer))
+	hint.Wrapping = fyne.TextWrapWord
+
+	status := widget.NewLabel("状态: 尚未验证")
+	if verified {
+		status.SetText("状态: 已验证")
+	}
+
+	var verifyBtn *widget.Button
+	verifyBtn = widget.NewButton("标记为已验证", func() {
+		if err := ui.client.MarkVerified(peer); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		status.SetText("状态: 已验证")
+		verifyBtn.Disable()
+	})
+	if verified {
+		verifyBtn.Disable()
+	}
+
+	content := container.NewVBox(hint, digits, status, verifyBtn)
+	d := dialog.NewCustom("安全码 - "+peer, "关闭", content, ui.window)
+	d.Resize(fyne.NewSize(460, 300))
+	d.Show()
+}
+
+// safetyNumberLines 将 12 组安全码排成 3 行，便于朗读核对
+func safetyNumberLines(number string) string {
+	var lines strings.Builder
+	for i, group := range strings.Fields(number) {
+		switch {
+		case i == 0:
+		case i%4 == 0:
+			lines.WriteString("\n")
+		default:
+			lines.WriteString("  ")
+		}
+		lines.WriteString(group)
+	}
+	return lines.String()
+}
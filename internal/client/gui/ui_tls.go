@@ -0,0 +1,102 @@
+package gui
+
+import (
+	"GoChat/internal/client"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showCertificateChangedDialog 在服务器证书与之前信任的不同时给出醒目的警告，
+// 用户确认后信任新证书并调用 retry 重新连接
+func (ui *UI) showCertificateChangedDialog(e *client.CertificateChangedError, retry func()) {
+	title := widget.NewLabelWithStyle("警告: 服务器证书已改变！", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	title.Importance = widget.DangerImportance
+
+	message := widget.NewLabel(fmt.Sprintf(
+		"服务器 %s 出示的证书与之前信任的不同。\n"+
+			"这可能是有人正在冒充该服务器 (中间人攻击)，也可能是管理员更换了证书。\n"+
+			"请通过其他途径向管理员核对新指纹，在确认之前不要继续连接。", e.Address))
+	message.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		container.NewHBox(widget.NewIcon(theme.WarningIcon()), title),
+		message,
+		widget.NewForm(
+			widget.NewFormItem("之前的指纹", fingerprintLabel(e.Pinned)),
+			widget.NewFormItem("当前的指纹", fingerprintLabel(e.Presented)),
+		),
+	)
+
+	d := dialog.NewCustomConfirm("证书已改变", "信任新证书并连接", "取消连接", content, func(trust bool) {
+		if !trust {
+			return
+		}
+		if err := ui.client.PinStore().Pin(e.Address, e.Presented); err != nil {
+			dialog.ShowError(fmt.Errorf("保存证书指纹失败: %w", err), ui.window)
+			return
+		}
+		retry()
+	}, ui.window)
+	d.Resize(fyne.NewSize(620, 320))
+	d.Show()
+}
+
+// showPinnedServersDialog 展示已信任的服务器证书，并允许删除记录
+func (ui *UI) showPinnedServersDialog() {
+	pins := ui.client.PinStore()
+	if pins == nil {
+		dialog.ShowInformation("已信任的服务器证书", "未启用证书指纹记录。", ui.window)
+		return
+	}
+
+	servers := pins.List()
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(servers) },
+		func() fyne.CanvasObject {
+			address := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			detail := widget.NewLabel("")
+			detail.TextStyle = fyne.TextStyle{Monospace: true}
+			detail.Wrapping = fyne.TextWrapBreak
+			remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
+			return container.NewBorder(nil, nil, nil, remove, container.NewVBox(address, detail))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			server := servers[id]
+			border := o.(*fyne.Container)
+			labels := border.Objects[0].(*fyne.Container)
+			labels.Objects[0].(*widget.Label).SetText(server.Address)
+			labels.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%s\n首次信任于 %s",
+				server.Fingerprint, server.FirstSeen.Format("2006-01-02 15:04")))
+			border.Objects[1].(*widget.Button).OnTapped = func() {
+				dialog.ShowConfirm("删除记录", fmt.Sprintf("删除后下次连接 %s 时将重新信任其证书，确定吗？", server.Address), func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := pins.Remove(server.Address); err != nil {
+						dialog.ShowError(err, ui.window)
+						return
+					}
+					servers = pins.List()
+					list.Refresh()
+				}, ui.window)
+			}
+		},
+	)
+
+	d := dialog.NewCustom("已信任的服务器证书", "关闭", list, ui.window)
+	d.Resize(fyne.NewSize(640, 360))
+	d.Show()
+}
+
+func fingerprintLabel(fingerprint string) *widget.Label {
+	label := widget.NewLabel(fingerprint)
+	label.TextStyle = fyne.TextStyle{Monospace: true}
+	label.Wrapping = fyne.TextWrapBreak
+	return label
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PinnedServer 是一条已信任的服务器证书记录
+type PinnedServer struct {
+	Address     string    `json:"address"`     // 登录时输入的服务器地址
+	Fingerprint string    `json:"fingerprint"` // 证书 SHA-256 指纹
+	FirstSeen   time.Time `json:"first_seen"`  // 首次信任的时间
+}
+
+// PinStore 以 JSON 文件保存每个服务器地址对应的证书指纹 (首次使用时信任)
+type PinStore struct {
+	path string
+	mu   sync.Mutex
+	pins map[string]PinnedServer
+}
+
+// CertificateChangedError 表示服务器出示的证书与之前信任的不同，可能遭到中间人攻击
+type CertificateChangedError struct {
+	Address   string // 服务器地址
+	Pinned    string // 之前信任的指纹
+	Presented string // 本次连接收到的指纹
+}
+
+func (e *CertificateChangedError) Error() string {
+	return fmt.Sprintf("服务器 %s 的证书已改变 (之前信任的指纹 %s，当前指纹 %s)", e.Address, e.Pinned, e.Presented)
+}
+
+// DefaultPinStorePath 返回用户配置目录下的默认指纹文件路径
+func DefaultPinStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "GoChat", "known_servers.json"), nil
+}
+
+// OpenPinStore 读取指纹文件，文件不存在时创建空记录
+func OpenPinStore(path string) (*PinStore, error) {
+	s := &PinStore{path: path, pins: make(map[string]PinnedServer)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []PinnedServer
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	for _, p := range list {
+		s.pins[p.Address] = p
+	}
+	return s, nil
+}
+
+// Lookup 返回地址对应的已信任指纹
+func (s *PinStore) Lookup(address string) (PinnedServer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pins[address]
+	return p, ok
+}
+
+// List 按地址排序返回所有已信任的服务器
+func (s *PinStore) List() []PinnedServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]PinnedServer, 0, len(s.pins))
+	for _, p := range s.pins {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b PinnedServer) int { return strings.Compare(a.Address, b.Address) })
+	return list
+}
+
+// Pin 信任地址对应的证书指纹，覆盖之前的记录
+func (s *PinStore) Pin(address, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pins[address] = PinnedServer{Address: address, Fingerprint: fingerprint, FirstSeen: time.Now()}
+	return s.save()
+}
+
+// Remove 删除地址对应的记录，下次连接时会重新信任服务器出示的证书
+func (s *PinStore) Remove(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pins, address)
+	return s.save()
+}
+
+// save 写入指纹文件，调用者需持有锁
+func (s *PinStore) save() error {
+	list := make([]PinnedServer, 0, len(s.pins))
+	for _, p := range s.pins {
+		list = append(list, p)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"GoChat/pkg/protocol"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// tlsConfig 返回连接 address 时使用的 TLS 配置。启用指纹记录时由 verifyPinned 代替默认的证书链校验
+func (c *Client) tlsConfig(address string) *tls.Config {
+	config := &tls.Config{
+		RootCAs:    c.rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.cert != nil {
+		config.Certificates = []tls.Certificate{*c.cert}
+	}
+	if c.pins != nil {
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return c.verifyPinned(address, state)
+		}
+	}
+	return config
+}
+
+// verifyPinned 按首次信任的规则校验服务器证书:
+// 已记录指纹的地址必须出示相同的证书；未记录的地址如果证书能通过 CA 校验则直接接受，
+// 否则 (如自签名证书) 记录本次的指纹
+func (c *Client) verifyPinned(address string, state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("服务器没有提供证书")
+	}
+	leaf := state.PeerCertificates[0]
+	fingerprint := protocol.CertFingerprint(leaf.Raw)
+
+	if pin, ok := c.pins.Lookup(address); ok {
+		if pin.Fingerprint != fingerprint {
+			return &CertificateChangedError{Address: address, Pinned: pin.Fingerprint, Presented: fingerprint}
+		}
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         c.rootCAs,
+		Intermediates: intermediates,
+		DNSName:       state.ServerName,
+	})
+	if err == nil {
+		return nil
+	}
+
+	slog.Warn("首次连接该服务器，信任其证书", "address", address, "fingerprint", fingerprint, "reason", err)
+	if err := c.pins.Pin(address, fingerprint); err != nil {
+		slog.Error("保存服务器证书指纹失败", "error", err)
+	}
+	return nil
+}
+
+// LoadRootCAs 读取 PEM 格式的 CA 证书，用于信任自建 CA 或自签名服务器证书
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s 中没有有效的 PEM 证书", path)
+	}
+	return pool, nil
+}
+
+// describeReadError 说明连接中途断开的原因。TLS 1.3 中服务器在握手完成后才校验客户端证书，
+// 因此证书被拒绝的错误会在第一次读取时出现
+func describeReadError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return describeRemoteError(err)
+	}
+	return err
+}
+
+// describeRemoteError 说明服务器发来的 TLS 警报，crypto/tls 没有导出警报类型，只能比较描述文字
+func describeRemoteError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch opErr.Err.Error() {
+		case "tls: certificate required":
+			return fmt.Errorf("服务器要求客户端证书，请配置 GOCHAT_TLS_CERT 和 GOCHAT_TLS_KEY: %w", err)
+		case "tls: bad certificate", "tls: unknown certificate authority", "tls: expired certificate",
+			"tls: unsupported certificate", "tls: revoked certificate", "tls: unknown certificate":
+			return fmt.Errorf("服务器不接受客户端证书: %w", err)
+		}
+	}
+	return fmt.Errorf("服务器拒绝了 TLS 握手: %w", err)
+}
+
+// describeTLSError 将 TLS 握手错误转换为便于用户理解的说明
+func describeTLSError(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("服务器证书不受信任，可能是自签名证书: %w", err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("服务器证书与地址 %s 不匹配: %w", hostname.Host, err)
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return fmt.Errorf("服务器证书已过期或尚未生效: %w", err)
+	case errors.As(err, &recordHeader):
+		return fmt.Errorf("服务器没有启用 TLS，请取消勾选加密连接: %w", err)
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		return describeRemoteError(err)
+	default:
+		return fmt.Errorf("TLS 握手失败: %w", err)
+	}
+}
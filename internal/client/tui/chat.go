@@ -0,0 +1,498 @@
+package tui
+
+import (
+	"GoChat/pkg/protocol"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const keyHelp = `快捷键:
+  Tab / Shift+Tab     在输入框、在线用户和群组列表之间切换
+  Ctrl+N / Ctrl+P     切换到下一个 / 上一个会话
+  Alt+1 ... Alt+9     切换到第 N 个会话
+  Ctrl+W              关闭当前会话 (群组会话同时退出群组)
+  Ctrl+G              创建群组
+  Ctrl+F              向当前会话发送文件
+  Ctrl+S              保存当前会话中最近收到的文件
+  Ctrl+E              查看私聊的安全码
+  PgUp / PgDn         滚动聊天记录
+  F1                  显示本帮助
+  Ctrl+C              退出
+
+命令:
+  /join <群组>        加入群组
+  /create [-e] <群组> 创建群组，-e 启用端到端加密
+  /leave              退出当前群组
+  /file <路径>        向当前会话发送文件
+  /save [编号] [路径] 保存收到的文件
+  /safety             查看私聊的安全码
+  /quit               退出`
+
+// conversation 是一个聊天会话 (世界大厅、群组或私聊)
+type conversation struct {
+	name   string
+	view   *tview.TextView
+	unread int
+}
+
+// receivedFile 是收到的文件，用户选择保存后才写入磁盘
+type receivedFile struct {
+	id           int
+	conversation string
+	sender       string
+	payload      protocol.FilePayload
+}
+
+// chatView 是登录后的聊天界面: 左侧为在线用户和群组，右侧为会话标签、聊天记录和输入框
+type chatView struct {
+	ui *UI
+
+	layout   *tview.Flex
+	users    *tview.List
+	groups   *tview.List
+	tabs     *tview.TextView
+	messages *tview.Pages
+	input    *tview.InputField
+
+	conversations []*conversation
+	current       int
+	userNames     []string // 在线用户列表中的用户名，不含自己
+	groupNames    []string // 群组列表中的群组名
+	files         []receivedFile
+}
+
+func newChatView(ui *UI) *chatView {
+	c := &chatView{
+		ui:       ui,
+		users:    tview.NewList().ShowSecondaryText(false),
+		groups:   tview.NewList().ShowSecondaryText(false),
+		tabs:     tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetWrap(false),
+		messages: tview.NewPages(),
+		input:    tview.NewInputField(),
+	}
+
+	c.users.SetBorder(true).SetTitle(" 在线用户 ")
+	c.users.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		if i < len(c.userNames) {
+			c.open(c.userNames[i])
+			ui.app.SetFocus(c.input)
+		}
+	})
+	c.groups.SetBorder(true).SetTitle(" 可用群组 ")
+	c.groups.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		if i < len(c.groupNames) {
+			c.confirmJoin(c.groupNames[i])
+		}
+	})
+
+	c.tabs.SetHighlightedFunc(func(added, _, _ []string) {
+		if len(added) > 0 {
+			if i, err := strconv.Atoi(added[0]); err == nil && i != c.current {
+				c.switchTo(i)
+			}
+		}
+	})
+
+	c.input.SetPlaceholder("在这里输入消息，F1 查看帮助").
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				c.submit()
+			}
+		})
+
+	sidebar := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(c.users, 0, 2, false).
+		AddItem(c.groups, 0, 1, false)
+	main := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(c.tabs, 1, 0, false).
+		AddItem(c.messages, 0, 1, false).
+		AddItem(c.input, 1, 0, true)
+	main.SetBorder(true)
+	c.layout = tview.NewFlex().
+		AddItem(sidebar, 28, 0, false).
+		AddItem(main, 0, 1, true)
+
+	c.open(lobby)
+	return c
+}
+
+// refreshTitle 在聊天区域的边框上显示当前用户和会话
+func (c *chatView) refreshTitle() {
+	main := c.layout.GetItem(1).(*tview.Flex)
+	main.SetTitle(fmt.Sprintf(" Go Chat - %s | %s ", tview.Escape(c.ui.username), tview.Escape(c.conversations[c.current].name)))
+}
+
+// refreshTabs 重新绘制会话标签，未读消息数显示在名称后面
+func (c *chatView) refreshTabs() {
+	var b strings.Builder
+	for i, conv := range c.conversations {
+		label := fmt.Sprintf("%d:%s", i+1, tview.Escape(conv.name))
+		if conv.unread > 0 {
+			label += fmt.Sprintf(" [yellow](%d)[-]", conv.unread)
+		}
+		fmt.Fprintf(&b, `["%d"] %s [""]`, i, label)
+		if i < len(c.conversations)-1 {
+			b.WriteString("│")
+		}
+	}
+	c.tabs.SetText(b.String())
+	c.tabs.Highlight(strconv.Itoa(c.current))
+	c.tabs.ScrollToHighlight()
+	c.refreshTitle()
+}
+
+// find 返回会话的序号，不存在时返回 -1
+func (c *chatView) find(name string) int {
+	return slices.IndexFunc(c.conversations, func(conv *conversation) bool { return conv.name == name })
+}
+
+// ensure 确保会话存在但不切换到该会话
+func (c *chatView) ensure(name string) *conversation {
+	if i := c.find(name); i >= 0 {
+		return c.conversations[i]
+	}
+	view := tview.NewTextView().SetDynamicColors(true).SetWrap(true).SetScrollable(true)
+	view.SetChangedFunc(func() { view.ScrollToEnd() })
+	conv := &conversation{name: name, view: view}
+	c.conversations = append(c.conversations, conv)
+	c.messages.AddPage(name, view, true, false)
+	c.refreshTabs()
+	return conv
+}
+
+// open 打开并切换到会话
+func (c *chatView) open(name string) {
+	c.ensure(name)
+	c.switchTo(c.find(name))
+}
+
+func (c *chatView) switchTo(i int) {
+	if i < 0 || i >= len(c.conversations) {
+		return
+	}
+	c.current = i
+	conv := c.conversations[i]
+	conv.unread = 0
+	c.messages.SwitchToPage(conv.name)
+	c.input.SetLabel(tview.Escape("["+conv.name+"]") + " ")
+	c.refreshTabs()
+}
+
+// closeCurrent 关闭当前会话，群组会话同时退出群组，世界大厅不能关闭
+func (c *chatView) closeCurrent() {
+	conv := c.conversations[c.current]
+	if conv.name == lobby {
+		return
+	}
+	if c.isGroup(conv.name) {
+		c.ui.client.Send(protocol.Message{Type: protocol.LeaveGroupRequest, Sender: c.ui.username, GroupName: conv.name})
+	}
+	c.messages.RemovePage(conv.name)
+	c.conversations = slices.Delete(c.conversations, c.current, c.current+1)
+	c.switchTo(min(c.current, len(c.conversations)-1))
+}
+
+// handleKey 处理聊天界面的快捷键
+func (c *chatView) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	app := c.ui.app
+	switch event.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		order := []tview.Primitive{c.input, c.users, c.groups}
+		i := slices.Index(order, app.GetFocus())
+		if event.Key() == tcell.KeyTab {
+			i = (i + 1) % len(order)
+		} else {
+			i = (i + len(order) - 1) % len(order)
+		}
+		app.SetFocus(order[i])
+		return nil
+	case tcell.KeyCtrlN:
+		c.switchTo((c.current + 1) % len(c.conversations))
+		return nil
+	case tcell.KeyCtrlP:
+		c.switchTo((c.current + len(c.conversations) - 1) % len(c.conversations))
+		return nil
+	case tcell.KeyCtrlW:
+		c.closeCurrent()
+		return nil
+	case tcell.KeyCtrlG:
+		c.ui.showCreateGroup()
+		return nil
+	case tcell.KeyCtrlF:
+		c.ui.showSendFile(c.conversations[c.current].name)
+		return nil
+	case tcell.KeyCtrlS:
+		c.saveLatest()
+		return nil
+	case tcell.KeyCtrlE:
+		c.showSafety()
+		return nil
+	case tcell.KeyF1:
+		c.ui.showMessage("帮助", keyHelp)
+		return nil
+	case tcell.KeyPgUp, tcell.KeyPgDn:
+		// 输入框获得焦点时也可以滚动聊天记录
+		if app.GetFocus() == c.input {
+			handler := c.conversations[c.current].view.InputHandler()
+			handler(event, func(p tview.Primitive) {})
+			return nil
+		}
+	case tcell.KeyRune:
+		if event.Modifiers()&tcell.ModAlt != 0 && event.Rune() >= '1' && event.Rune() <= '9' {
+			c.switchTo(int(event.Rune() - '1'))
+			return nil
+		}
+	}
+	return event
+}
+
+// submit 发送输入框中的内容，以 / 开头的内容作为命令处理
+func (c *chatView) submit() {
+	text := c.input.GetText()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "//") {
+		c.input.SetText("")
+		c.command(text)
+		return
+	}
+	name := c.conversations[c.current].name
+	if !c.ui.readyToSend(name) {
+		return
+	}
+	var msgType, recipient, groupName string
+	switch {
+	case name == lobby:
+		msgType = protocol.BroadcastMessage
+	case c.isGroup(name):
+		msgType = protocol.GroupMessage
+		groupName = name
+	default:
+		msgType = protocol.PrivateMessage
+		recipient = name
+	}
+	c.ui.client.SendChatMessage(msgType, recipient, groupName, strings.TrimPrefix(text, "/"))
+	c.input.SetText("")
+}
+
+// command 执行斜杠命令
+func (c *chatView) command(line string) {
+	fields := strings.Fields(line)
+	args := fields[1:]
+	current := c.conversations[c.current].name
+	switch fields[0] {
+	case "/help":
+		c.ui.showMessage("帮助", keyHelp)
+	case "/join":
+		if len(args) != 1 {
+			c.notice(current, "用法: /join <群组>")
+			return
+		}
+		c.join(args[0])
+	case "/create":
+		encrypted := len(args) > 0 && args[0] == "-e"
+		if encrypted {
+			args = args[1:]
+		}
+		if len(args) != 1 {
+			c.notice(current, "用法: /create [-e] <群组>")
+			return
+		}
+		c.ui.createGroup(args[0], encrypted)
+	case "/leave":
+		if !c.isGroup(current) {
+			c.notice(current, "当前会话不是群组")
+			return
+		}
+		c.closeCurrent()
+	case "/file":
+		if len(args) == 0 {
+			c.ui.showSendFile(current)
+			return
+		}
+		c.ui.sendFile(current, strings.TrimSpace(strings.TrimPrefix(line, "/file")))
+	case "/save":
+		c.saveCommand(args)
+	case "/safety":
+		c.showSafety()
+	case "/quit":
+		c.ui.app.Stop()
+	default:
+		c.notice(current, "未知命令 "+fields[0]+"，按 F1 查看帮助")
+	}
+}
+
+// join 加入群组并打开会话
+func (c *chatView) join(group string) {
+	c.ui.client.Send(protocol.Message{Type: protocol.JoinGroupRequest, Sender: c.ui.username, GroupName: group})
+	c.open(group)
+	c.ui.app.SetFocus(c.input)
+}
+
+func (c *chatView) confirmJoin(group string) {
+	question := fmt.Sprintf("您想加入群组 '%s' 吗？", group)
+	if c.ui.client.IsEncryptedGroup(group) {
+		question += "\n该群组已启用端到端加密，加入后需等待其他成员分发密钥才能收发消息。"
+	}
+	c.ui.showConfirm(question, "加入", func() { c.join(group) })
+}
+
+func (c *chatView) showSafety() {
+	name := c.conversations[c.current].name
+	if name == lobby || c.isGroup(name) {
+		c.notice(name, "安全码只适用于私聊")
+		return
+	}
+	c.ui.showSafetyNumber(name)
+}
+
+// saveLatest 保存当前会话中最近收到的文件
+func (c *chatView) saveLatest() {
+	name := c.conversations[c.current].name
+	for i := len(c.files) - 1; i >= 0; i-- {
+		if c.files[i].conversation == name {
+			c.ui.showSaveFile(c.files[i])
+			return
+		}
+	}
+	c.notice(name, "当前会话中没有收到文件")
+}
+
+// saveCommand 处理 /save [编号] [路径]
+func (c *chatView) saveCommand(args []string) {
+	if len(args) == 0 {
+		c.saveLatest()
+		return
+	}
+	name := c.conversations[c.current].name
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 1 || id > len(c.files) {
+		c.notice(name, "没有编号为 "+args[0]+" 的文件")
+		return
+	}
+	file := c.files[id-1]
+	if len(args) == 1 {
+		c.ui.showSaveFile(file)
+		return
+	}
+	c.ui.saveFile(file, strings.Join(args[1:], " "))
+}
+
+// handle 处理服务器发来的消息
+func (c *chatView) handle(msg protocol.Message) {
+	ui := c.ui
+	switch msg.Type {
+	case protocol.LoginResponse:
+		if msg.TextPayload != "" {
+			ui.loginError = msg.TextPayload
+		} else if msg.Recipient != "" && msg.Recipient != ui.username {
+			// 服务器使用证书中的名称作为用户名
+			ui.setUsername(msg.Recipient)
+		}
+	case protocol.TreeUpdate:
+		c.updatePresence(msg.TreePayload)
+	case protocol.BroadcastMessage, protocol.SystemMessage:
+		c.add(lobby, msg)
+	case protocol.GroupMessage:
+		c.add(msg.GroupName, msg)
+	case protocol.PrivateMessage:
+		c.add(c.partner(msg), msg)
+	case protocol.PrivateFileMessage, protocol.GroupFileMessage:
+		if msg.Sender == ui.username {
+			return
+		}
+		name := msg.GroupName
+		if msg.Type == protocol.PrivateFileMessage {
+			name = c.partner(msg)
+		}
+		file := receivedFile{id: len(c.files) + 1, conversation: name, sender: msg.Sender, payload: msg.FilePayload}
+		c.files = append(c.files, file)
+		c.notice(name, fmt.Sprintf("%s 发送了文件 %s (%s)，按 Ctrl+S 或输入 /save %d 保存",
+			msg.Sender, msg.FilePayload.Name, formatSize(msg.FilePayload.Size), file.id))
+	}
+}
+
+// partner 返回私聊的对方
+func (c *chatView) partner(msg protocol.Message) string {
+	if msg.Sender == c.ui.username {
+		return msg.Recipient
+	}
+	return msg.Sender
+}
+
+// updatePresence 刷新在线用户和群组列表
+func (c *chatView) updatePresence(tree protocol.TreePayload) {
+	c.userNames = c.userNames[:0]
+	for _, user := range tree.Users {
+		if user != "" && user != c.ui.username {
+			c.userNames = append(c.userNames, user)
+		}
+	}
+	slices.Sort(c.userNames)
+	c.groupNames = slices.Sorted(maps.Keys(tree.Groups))
+
+	refill := func(list *tview.List, items []string, label func(string) string) {
+		current := list.GetCurrentItem()
+		list.Clear()
+		for _, item := range items {
+			list.AddItem(label(item), "", 0, nil)
+		}
+		list.SetCurrentItem(min(current, max(len(items)-1, 0)))
+	}
+	refill(c.users, c.userNames, func(user string) string {
+		if slices.Contains(tree.Bots, user) {
+			return tview.Escape(user) + " [blue]机器人[-]"
+		}
+		return tview.Escape(user)
+	})
+	refill(c.groups, c.groupNames, func(group string) string {
+		label := fmt.Sprintf("%s (%d)", tview.Escape(group), len(tree.Groups[group]))
+		if slices.Contains(tree.EncryptedGroups, group) {
+			label += " [green]加密[-]"
+		}
+		return label
+	})
+	c.users.SetTitle(fmt.Sprintf(" 在线用户 (%d) ", len(c.userNames)))
+}
+
+// add 在会话中显示一条聊天消息，不在当前会话时增加未读数
+func (c *chatView) add(name string, msg protocol.Message) {
+	sender := tview.Escape(msg.Sender)
+	switch {
+	case msg.Type == protocol.SystemMessage:
+		sender = "[yellow]" + sender + "[-]"
+	case msg.Sender == c.ui.username:
+		sender = "[green]" + sender + "[-]"
+	}
+	if msg.Type == protocol.PrivateMessage && msg.Encrypted == nil && c.ui.client.E2EEnabled() {
+		sender += " [red](未加密)[-]"
+	}
+	c.write(name, fmt.Sprintf("[gray]%s[-] %s: %s", msg.Timestamp.Local().Format("15:04:05"), sender, tview.Escape(msg.TextPayload)))
+}
+
+// notice 在会话中显示一条本地提示
+func (c *chatView) notice(name, text string) {
+	c.write(name, fmt.Sprintf("[gray]%s[-] [yellow]* %s[-]", time.Now().Format("15:04:05"), tview.Escape(text)))
+}
+
+func (c *chatView) write(name, line string) {
+	conv := c.ensure(name)
+	fmt.Fprintln(conv.view, line)
+	if c.conversations[c.current] != conv {
+		conv.unread++
+		c.refreshTabs()
+	}
+}
+
+func (c *chatView) isGroup(name string) bool {
+	return slices.Contains(c.groupNames, name)
+}
@@ -0,0 +1,416 @@
+package tui
+
+import (
+	"GoChat/internal/client"
+	"GoChat/pkg/discovery"
+	"GoChat/pkg/protocol"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// discoveryTimeout 是等待局域网服务器回复的时间
+const discoveryTimeout = 1500 * time.Millisecond
+
+// openDialog 在当前界面上方弹出对话框，同一时间只显示一个对话框
+func (ui *UI) openDialog(p tview.Primitive) {
+	if !ui.pages.HasPage("dialog") {
+		ui.lastFocus = ui.app.GetFocus()
+	}
+	ui.pages.AddPage("dialog", p, true, true)
+	ui.app.SetFocus(p)
+}
+
+// closeDialog 关闭对话框并恢复之前的焦点
+func (ui *UI) closeDialog() {
+	if !ui.pages.HasPage("dialog") {
+		return
+	}
+	ui.pages.RemovePage("dialog")
+	if ui.lastFocus != nil {
+		ui.app.SetFocus(ui.lastFocus)
+		ui.lastFocus = nil
+	}
+}
+
+// showMessage 显示一条只有“确定”按钮的消息
+func (ui *UI) showMessage(title, text string) {
+	view := tview.NewTextView().SetText(text).SetScrollable(true)
+	view.SetBorder(true).SetTitle(" " + title + " (Esc 关闭) ")
+	view.SetDoneFunc(func(tcell.Key) { ui.closeDialog() })
+	lines := strings.Count(text, "\n") + 3
+	ui.openDialog(center(view, 72, min(lines, 30)))
+}
+
+// showConfirm 请用户确认一个操作
+func (ui *UI) showConfirm(text, action string, confirmed func()) {
+	modal := tview.NewModal().SetText(text).AddButtons([]string{action, "取消"})
+	modal.SetDoneFunc(func(i int, _ string) {
+		ui.closeDialog()
+		if i == 0 {
+			confirmed()
+		}
+	})
+	ui.openDialog(modal)
+}
+
+// showForm 显示带有“确定”和“取消”按钮的表单，确定时调用 submit
+func (ui *UI) showForm(title string, form *tview.Form, height int, submit func()) {
+	form.AddButton("确定", func() {
+		ui.closeDialog()
+		submit()
+	}).AddButton("取消", ui.closeDialog)
+	form.SetCancelFunc(ui.closeDialog)
+	form.SetBorder(true).SetTitle(" " + title + " ")
+	ui.openDialog(center(form, 72, height))
+}
+
+// showCertificateChanged 在服务器证书与之前信任的不同时给出醒目的警告，
+// 用户确认后信任新证书并调用 retry 重新连接
+func (ui *UI) showCertificateChanged(e *client.CertificateChangedError, retry func()) {
+	text := fmt.Sprintf("警告: 服务器证书已改变！\n\n"+
+		"服务器 %s 出示的证书与之前信任的不同。"+
+		"这可能是有人正在冒充该服务器 (中间人攻击)，也可能是管理员更换了证书。"+
+		"请通过其他途径向管理员核对新指纹，在确认之前不要继续连接。\n\n"+
+		"之前的指纹:\n%s\n\n当前的指纹:\n%s", e.Address, e.Pinned, e.Presented)
+	modal := tview.NewModal().SetText(text).AddButtons([]string{"信任新证书并连接", "取消连接"})
+	modal.SetBackgroundColor(tcell.ColorDarkRed)
+	modal.SetDoneFunc(func(i int, _ string) {
+		ui.closeDialog()
+		if i != 0 {
+			return
+		}
+		if err := ui.client.PinStore().Pin(e.Address, e.Presented); err != nil {
+			ui.showMessage("错误", "保存证书指纹失败: "+err.Error())
+			return
+		}
+		retry()
+	})
+	ui.openDialog(modal)
+}
+
+// showPinnedServers 展示已信任的服务器证书，按 Delete 删除记录
+func (ui *UI) showPinnedServers() {
+	pins := ui.client.PinStore()
+	if pins == nil {
+		ui.showMessage("已信任的服务器证书", "未启用证书指纹记录。")
+		return
+	}
+	servers := pins.List()
+	if len(servers) == 0 {
+		ui.showMessage("已信任的服务器证书", "还没有信任任何服务器的证书。")
+		return
+	}
+	list := tview.NewList()
+	for _, server := range servers {
+		list.AddItem(server.Address, fmt.Sprintf("%s  首次信任于 %s",
+			server.Fingerprint, server.FirstSeen.Format("2006-01-02 15:04")), 0, nil)
+	}
+	list.SetDoneFunc(ui.closeDialog)
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyDelete {
+			return event
+		}
+		server := servers[list.GetCurrentItem()]
+		ui.showConfirm(fmt.Sprintf("删除后下次连接 %s 时将重新信任其证书，确定吗？", server.Address), "删除", func() {
+			if err := pins.Remove(server.Address); err != nil {
+				ui.showMessage("错误", err.Error())
+				return
+			}
+			ui.showPinnedServers()
+		})
+		return nil
+	})
+	list.SetBorder(true).SetTitle(" 已信任的服务器证书 (Delete 删除，Esc 关闭) ")
+	ui.openDialog(center(list, 120, 20))
+}
+
+// showDiscovery 搜索局域网内的服务器，用户选择后调用 connect
+func (ui *UI) showDiscovery(connect func(server discovery.Server)) {
+	var servers []discovery.Server
+	status := tview.NewTextView().SetWrap(true)
+	list := tview.NewList()
+	list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		ui.closeDialog()
+		connect(servers[i])
+	})
+	list.SetDoneFunc(ui.closeDialog)
+
+	var search func()
+	search = func() {
+		status.SetText("正在搜索局域网内的服务器...")
+		go func() {
+			found, err := discovery.Discover(discovery.DefaultPort, discoveryTimeout)
+			if err != nil {
+				slog.Warn("搜索局域网服务器失败", "error", err)
+			}
+			ui.app.QueueUpdateDraw(func() {
+				servers = found
+				list.Clear()
+				for _, server := range servers {
+					list.AddItem(fmt.Sprintf("%s (%s)", server.Name, server.Address), ui.describeDiscovered(server), 0, nil)
+				}
+				switch {
+				case err != nil:
+					status.SetText("搜索失败: " + err.Error())
+				case len(servers) == 0:
+					status.SetText("没有找到服务器。服务器需要开启局域网发现，且与本机位于同一网段。")
+				default:
+					status.SetText(fmt.Sprintf("找到 %d 个服务器，按回车连接", len(servers)))
+				}
+			})
+		}()
+	}
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == 'r' {
+			search()
+			return nil
+		}
+		return event
+	})
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(status, 2, 0, false).
+		AddItem(list, 0, 1, true)
+	layout.SetBorder(true).SetTitle(" 局域网服务器 (r 重新搜索，Esc 关闭) ")
+	ui.openDialog(center(layout, 100, 16))
+	search()
+}
+
+// describeDiscovered 描述服务器的加密方式，并与已信任的证书指纹比较
+func (ui *UI) describeDiscovered(server discovery.Server) string {
+	if !server.TLS {
+		return "未加密"
+	}
+	text := "TLS 加密，证书指纹: " + server.Fingerprint
+	if pins := ui.client.PinStore(); pins != nil {
+		if pinned, ok := pins.Lookup(server.Address); ok {
+			if pinned.Fingerprint == server.Fingerprint {
+				text += "，与已信任的证书一致"
+			} else {
+				text += "，注意: 与之前信任的证书不一致！"
+			}
+		}
+	}
+	return text
+}
+
+func (ui *UI) showCreateGroup() {
+	form := tview.NewForm().
+		AddInputField("群组名", "", 30, nil, nil).
+		AddCheckbox("端到端加密", false, nil)
+	encrypted := form.GetFormItemByLabel("端到端加密").(*tview.Checkbox)
+	if !ui.client.E2EEnabled() {
+		encrypted.SetDisabled(true)
+	}
+	ui.showForm("创建新群组", form, 9, func() {
+		name := strings.TrimSpace(form.GetFormItemByLabel("群组名").(*tview.InputField).GetText())
+		if name != "" {
+			ui.createGroup(name, encrypted.IsChecked())
+		}
+	})
+}
+
+// createGroup 创建群组，创建者自动加入
+func (ui *UI) createGroup(name string, encrypted bool) {
+	if encrypted && !ui.client.E2EEnabled() {
+		ui.showMessage("无法创建", "本客户端没有可用的密钥，无法创建加密群组。")
+		return
+	}
+	msgType := protocol.CreateGroupRequest
+	if encrypted {
+		msgType = protocol.CreateEncryptedGroupRequest
+	}
+	ui.client.SendChatMessage(msgType, "", "", name)
+	ui.chat.open(name)
+	ui.app.SetFocus(ui.chat.input)
+}
+
+// readyToSend 检查加密群组的密钥是否就绪，未就绪时提示用户；
+// 私聊对方的公钥消失时，需要用户确认后才能以明文发送
+func (ui *UI) readyToSend(name string) bool {
+	if name != lobby && !ui.chat.isGroup(name) && ui.client.PlaintextDowngrade(name) {
+		ui.showConfirm(fmt.Sprintf("%s 之前使用端到端加密，但服务器现在没有提供对方的公钥，可能是对方换了不支持加密的客户端，也可能是服务器有意让消息以明文发送。\n\n"+
+			"确认后本次登录期间发给 %s 的私聊将不加密，请再次发送。", name, name), "不加密发送", func() {
+			ui.client.AllowPlaintextTo(name)
+		})
+		return false
+	}
+	if !ui.chat.isGroup(name) || !ui.client.IsEncryptedGroup(name) {
+		return true
+	}
+	if !ui.client.E2EEnabled() {
+		ui.showMessage("无法发送", "该群组已启用端到端加密，但本客户端没有可用的密钥。")
+		return false
+	}
+	if !ui.client.GroupKeyReady(name) {
+		ui.showMessage("无法发送", "群组密钥尚未就绪，请稍后再试。")
+		return false
+	}
+	return true
+}
+
+func (ui *UI) showSendFile(target string) {
+	if target == lobby {
+		ui.chat.notice(target, "不能向世界大厅发送文件，请在私聊或群组中发送")
+		return
+	}
+	form := tview.NewForm().AddInputField("文件路径", "", 50, nil, nil)
+	path := form.GetFormItemByLabel("文件路径").(*tview.InputField)
+	path.SetAutocompleteFunc(completePath)
+	ui.showForm("发送文件到 "+target, form, 7, func() {
+		ui.sendFile(target, path.GetText())
+	})
+}
+
+// sendFile 向私聊或群组发送文件
+func (ui *UI) sendFile(target, path string) {
+	if target == lobby {
+		ui.chat.notice(target, "不能向世界大厅发送文件，请在私聊或群组中发送")
+		return
+	}
+	path = expandHome(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		ui.chat.notice(target, "无法读取文件: "+err.Error())
+		return
+	}
+	if info.IsDir() {
+		ui.chat.notice(target, path+" 是目录")
+		return
+	}
+	if !ui.readyToSend(target) {
+		return
+	}
+	msgType := protocol.PrivateFileMessage
+	if ui.chat.isGroup(target) {
+		msgType = protocol.GroupFileMessage
+	}
+	ui.client.SendFile(msgType, target, target, path)
+	ui.chat.notice(target, fmt.Sprintf("正在向 %s 发送文件: %s...", target, info.Name()))
+}
+
+func (ui *UI) showSaveFile(file receivedFile) {
+	form := tview.NewForm().AddInputField("保存到", filepath.Join(downloadDir(), file.payload.Name), 50, nil, nil)
+	path := form.GetFormItemByLabel("保存到").(*tview.InputField)
+	path.SetAutocompleteFunc(completePath)
+	title := fmt.Sprintf("保存来自 %s 的文件 %s (%s)", file.sender, file.payload.Name, formatSize(file.payload.Size))
+	ui.showForm(title, form, 7, func() {
+		ui.saveFile(file, path.GetText())
+	})
+}
+
+// saveFile 保存收到的文件，path 为目录时使用原文件名
+func (ui *UI) saveFile(file receivedFile, path string) {
+	path = expandHome(path)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, filepath.Base(file.payload.Name))
+	}
+	ui.client.SaveFile(file.payload, path)
+	ui.chat.notice(file.conversation, fmt.Sprintf("正在保存来自 %s 的文件: %s 到 %s...", file.sender, file.payload.Name, path))
+}
+
+// showSafetyNumber 展示与 peer 的安全码，双方核对一致后可以标记为已验证
+func (ui *UI) showSafetyNumber(peer string) {
+	number, verified, err := ui.client.SafetyNumber(peer)
+	if err != nil {
+		ui.showMessage("端到端加密", fmt.Sprintf("与 %s 的私聊未加密: %v", peer, err))
+		return
+	}
+	status := "状态: 尚未验证"
+	buttons := []string{"标记为已验证", "关闭"}
+	if verified {
+		status = "状态: 已验证"
+		buttons = []string{"关闭"}
+	}
+	text := fmt.Sprintf("与 %s 的私聊已端到端加密。\n"+
+		"请当面或通过电话等可信渠道与对方核对下面的安全码，两边一致说明没有人冒充。\n\n%s\n\n%s",
+		peer, safetyNumberLines(number), status)
+	modal := tview.NewModal().SetText(text).AddButtons(buttons)
+	modal.SetDoneFunc(func(_ int, label string) {
+		ui.closeDialog()
+		if label != "标记为已验证" {
+			return
+		}
+		if err := ui.client.MarkVerified(peer); err != nil {
+			ui.showMessage("错误", err.Error())
+			return
+		}
+		ui.showSafetyNumber(peer)
+	})
+	ui.openDialog(modal)
+}
+
+// safetyNumberLines 将 12 组安全码排成 3 行，便于朗读核对
+func safetyNumberLines(number string) string {
+	var lines strings.Builder
+	for i, group := range strings.Fields(number) {
+		switch {
+		case i == 0:
+		case i%4 == 0:
+			lines.WriteString("\n")
+		default:
+			lines.WriteString("  ")
+		}
+		lines.WriteString(group)
+	}
+	return lines.String()
+}
+
+// completePath 补全文件路径
+func completePath(text string) []string {
+	if text == "" {
+		return nil
+	}
+	dir, prefix := filepath.Split(expandHome(text))
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) || (prefix == "" && strings.HasPrefix(entry.Name(), ".")) {
+			continue
+		}
+		match := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			match += string(filepath.Separator)
+		}
+		matches = append(matches, match)
+		if len(matches) == 20 {
+			break
+		}
+	}
+	return matches
+}
+
+// expandHome 将路径开头的 ~ 展开为用户主目录
+func expandHome(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "~" || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// downloadDir 返回保存文件的默认目录，用户的下载目录不存在时使用当前目录
+func downloadDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		dir := filepath.Join(home, "Downloads")
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return "."
+}
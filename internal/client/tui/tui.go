@@ -0,0 +1,215 @@
+// Package tui 是基于终端的聊天客户端界面，与图形界面共用 client.Client，
+// 适合在 SSH 会话或没有图形环境的机器上使用
+package tui
+
+import (
+	"GoChat/internal/client"
+	"GoChat/pkg/discovery"
+	"GoChat/pkg/protocol"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// lobby 是世界大厅会话的名称，与图形界面一致
+const lobby = "世界大厅"
+
+// Options 是登录界面的初始值
+type Options struct {
+	Address  string
+	Username string
+	TLS      bool
+}
+
+type UI struct {
+	client *client.Client
+	app    *tview.Application
+	pages  *tview.Pages
+	opts   Options
+
+	// 以下字段只在 tview 的事件协程中访问
+	username   string
+	loginError string // 服务器拒绝登录的原因，连接断开时展示
+	chat       *chatView
+	lastFocus  tview.Primitive // 弹出对话框前获得焦点的控件，关闭后恢复
+}
+
+// NewUI 创建终端界面
+func NewUI(c *client.Client, opts Options) *UI {
+	ui := &UI{
+		client: c,
+		app:    tview.NewApplication(),
+		pages:  tview.NewPages(),
+		opts:   opts,
+	}
+	ui.app.SetRoot(ui.pages, true).EnableMouse(true)
+	ui.app.SetInputCapture(ui.handleKey)
+	return ui
+}
+
+// Run 显示登录界面并运行，直到用户退出
+func (ui *UI) Run() error {
+	ui.showLogin("")
+	return ui.app.Run()
+}
+
+// showLogin 显示登录界面，status 为显示在表单下方的提示
+func (ui *UI) showLogin(status string) {
+	statusView := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	statusView.SetText(status)
+
+	usernameLabel := "用户名"
+	if ui.client.HasCertificate() {
+		usernameLabel = "用户名 (已配置客户端证书，可留空)"
+	}
+	form := tview.NewForm().
+		AddInputField("服务器地址", ui.opts.Address, 40, nil, nil).
+		AddInputField(usernameLabel, ui.opts.Username, 40, nil, nil).
+		AddPasswordField("密码 (未注册可留空)", "", 40, '*', nil).
+		AddCheckbox("使用 TLS 加密连接", ui.opts.TLS, nil)
+
+	field := func(label string) *tview.InputField { return form.GetFormItemByLabel(label).(*tview.InputField) }
+	tlsCheck := form.GetFormItemByLabel("使用 TLS 加密连接").(*tview.Checkbox)
+
+	var login func()
+	login = func() {
+		ui.opts.Address = field("服务器地址").GetText()
+		ui.opts.Username = field(usernameLabel).GetText()
+		ui.opts.TLS = tlsCheck.IsChecked()
+		password := field("密码 (未注册可留空)").GetText()
+
+		// 使用客户端证书登录时用户名由服务器根据证书确定
+		if ui.opts.Username == "" && !ui.client.HasCertificate() {
+			statusView.SetText("[red]用户名不能为空")
+			return
+		}
+		if ui.opts.Address == "" {
+			statusView.SetText("[red]服务器地址不能为空")
+			return
+		}
+		statusView.SetText("正在连接服务器...")
+		address, username, useTLS := ui.opts.Address, ui.opts.Username, ui.opts.TLS
+		go func() {
+			err := ui.client.Connect(address, useTLS)
+			ui.app.QueueUpdateDraw(func() {
+				if err != nil {
+					slog.Warn("连接服务器失败", "address", address, "tls", useTLS, "error", err)
+					var changed *client.CertificateChangedError
+					if errors.As(err, &changed) {
+						statusView.SetText("[red]服务器证书已改变")
+						ui.showCertificateChanged(changed, login)
+						return
+					}
+					statusView.SetText("[red]连接失败: " + tview.Escape(err.Error()))
+					return
+				}
+				ui.startChat(username, password)
+			})
+		}()
+	}
+
+	form.AddButton("登录", login).
+		AddButton("局域网服务器", func() {
+			ui.showDiscovery(func(server discovery.Server) {
+				field("服务器地址").SetText(server.Address)
+				tlsCheck.SetChecked(server.TLS)
+				if field(usernameLabel).GetText() == "" && !ui.client.HasCertificate() {
+					statusView.SetText("已选择服务器 " + tview.Escape(server.Name) + "，请输入用户名后登录")
+					form.SetFocus(1)
+					ui.app.SetFocus(form)
+					return
+				}
+				login()
+			})
+		}).
+		AddButton("已信任的证书", ui.showPinnedServers).
+		AddButton("退出", ui.app.Stop)
+	form.SetBorder(true).SetTitle(" 欢迎来到聊天室 ")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 13, 0, true).
+		AddItem(statusView, 3, 0, false)
+	ui.chat = nil
+	ui.pages.AddAndSwitchToPage("login", center(layout, 72, 16), true)
+	ui.pages.RemovePage("chat")
+	ui.app.SetFocus(form)
+}
+
+// startChat 切换到聊天界面并发送登录请求
+func (ui *UI) startChat(username, password string) {
+	ui.loginError = ""
+	ui.chat = newChatView(ui)
+	ui.setUsername(username)
+	ui.pages.AddAndSwitchToPage("chat", ui.chat.layout, true)
+	ui.pages.RemovePage("login")
+	ui.app.SetFocus(ui.chat.input)
+
+	ui.client.Start()
+	go ui.receive()
+	ui.client.Send(protocol.Message{Type: protocol.LoginRequest, Sender: username, TextPayload: password})
+}
+
+// setUsername 更新当前用户名和标题栏
+func (ui *UI) setUsername(username string) {
+	ui.username = username
+	ui.client.SetUsername(username)
+	if ui.chat != nil {
+		ui.chat.refreshTitle()
+	}
+}
+
+// receive 在后台读取服务器消息并交给界面处理，连接断开后回到登录界面
+func (ui *UI) receive() {
+	for msg := range ui.client.GetIncomingMessages() {
+		ui.app.QueueUpdateDraw(func() {
+			if ui.chat != nil {
+				ui.chat.handle(msg)
+			}
+		})
+	}
+	ui.app.QueueUpdateDraw(func() {
+		var status string
+		if ui.loginError != "" {
+			status = "[red]登录失败: " + tview.Escape(ui.loginError)
+		} else if err := ui.client.Err(); err != nil {
+			status = "[red]连接断开: " + tview.Escape(err.Error())
+		} else {
+			status = "您已与服务器断开连接。"
+		}
+		ui.closeDialog()
+		ui.showLogin(status)
+	})
+}
+
+// handleKey 处理全局快捷键，只在聊天界面且没有弹出对话框时生效
+func (ui *UI) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if name, _ := ui.pages.GetFrontPage(); name != "chat" || ui.chat == nil {
+		return event
+	}
+	return ui.chat.handleKey(event)
+}
+
+// center 将控件以固定大小放在屏幕中央
+func center(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// formatSize 以字节、KB 或 MB 显示文件大小
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d 字节", size)
+	}
+	if size >= 1<<20 {
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/1024)
+}
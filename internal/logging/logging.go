@@ -0,0 +1,122 @@
+// Package logging 基于 log/slog 为服务器和客户端提供统一的日志初始化
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// BodyKey 是记录消息正文时使用的属性名，隐私模式下带有该属性的字段会被丢弃
+const BodyKey = "body"
+
+var (
+	level   = new(slog.LevelVar)
+	privacy atomic.Bool
+)
+
+// Options 日志初始化参数
+type Options struct {
+	Level   string    // debug, info, warn, error
+	Format  string    // text 或 json
+	Privacy bool      // 隐私模式，开启后从不记录消息正文
+	Output  io.Writer // 输出位置，为空时使用标准错误
+	Recent  int       // 在内存中保留的最近日志条数，可通过 Recent 读取，0 表示不保留
+}
+
+// Setup 按照参数创建日志记录器，并设置为 slog 的默认记录器
+func Setup(opts Options) (*slog.Logger, error) {
+	if err := SetLevel(opts.Level); err != nil {
+		return nil, err
+	}
+	SetPrivacy(opts.Privacy)
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch opts.Format {
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return nil, fmt.Errorf("未知的日志格式: %q", opts.Format)
+	}
+
+	recent.resize(opts.Recent)
+	logger := slog.New(&privacyHandler{next: &ringHandler{next: handler}})
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// ParseLevel 解析日志级别字符串
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(strings.ToUpper(s))); err != nil {
+		return l, fmt.Errorf("未知的日志级别: %q", s)
+	}
+	return l, nil
+}
+
+// SetLevel 在运行时修改日志级别
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// SetPrivacy 在运行时开启或关闭隐私模式
+func SetPrivacy(enabled bool) {
+	privacy.Store(enabled)
+}
+
+// Body 返回记录消息正文的属性，隐私模式下该属性不会被输出
+func Body(text string) slog.Attr {
+	return slog.String(BodyKey, text)
+}
+
+// privacyHandler 在隐私模式下丢弃所有消息正文属性
+type privacyHandler struct {
+	next slog.Handler
+}
+
+func (h *privacyHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *privacyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !privacy.Load() {
+		return h.next.Handle(ctx, r)
+	}
+	filtered := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != BodyKey {
+			filtered.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, filtered)
+}
+
+func (h *privacyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	// 通过 With 附加的属性不会在每条记录中重新检查，因此不能携带消息正文
+	return &privacyHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *privacyHandler) WithGroup(name string) slog.Handler {
+	return &privacyHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Entry 是保存在内存中的一条日志
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// ring 是固定容量的环形日志缓冲区
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+var recent = &ring{}
+
+func (r *ring) resize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make([]Entry, size)
+	r.next = 0
+	r.full = false
+}
+
+func (r *ring) add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent 返回最近的至多 limit 条日志，按时间先后排列，limit 不大于 0 时返回全部
+func Recent(limit int) []Entry {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	var all []Entry
+	if recent.full {
+		all = append(slices.Clone(recent.entries[recent.next:]), recent.entries[:recent.next]...)
+	} else {
+		all = slices.Clone(recent.entries[:recent.next])
+	}
+	if limit > 0 && len(all) > limit {
+		all = all[len(all)-limit:]
+	}
+	return all
+}
+
+// ringHandler 将日志同时写入下一个处理器和内存缓冲区
+type ringHandler struct {
+	next   slog.Handler
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *ringHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *ringHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := Entry{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		entry.Attrs = make(map[string]any, len(h.attrs)+r.NumAttrs())
+		for _, a := range h.attrs {
+			entry.Attrs[a.Key] = attrValue(a.Value)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			entry.Attrs[h.prefix+a.Key] = attrValue(a.Value)
+			return true
+		})
+	}
+	recent.add(entry)
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := slices.Clone(h.attrs)
+	for _, a := range attrs {
+		merged = append(merged, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &ringHandler{next: h.next.WithAttrs(attrs), attrs: merged, prefix: h.prefix}
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	return &ringHandler{next: h.next.WithGroup(name), attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// attrValue 将属性值转换为可以编码为 JSON 的形式
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	return v.Any()
+}
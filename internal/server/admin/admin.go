@@ -0,0 +1,319 @@
+// Package admin 提供需要令牌认证的管理 HTTP/JSON 接口
+package admin
+
+import (
+	"GoChat/internal/logging"
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/store"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Options 是管理接口依赖的组件
+type Options struct {
+	Hub      *core.Hub
+	Token    string          // 访问令牌
+	Accounts *store.Accounts // 账号存储
+	Bans     *store.Bans     // 运行时封禁列表
+	Groups   *store.Groups   // 持久群组列表
+
+	// Reload 重新读取配置文件；ApplyBans 在封禁列表变化后重新应用设置
+	Reload    func(source string) error
+	ApplyBans func() error
+	// ValidateIP 校验要封禁的 IP 或网段
+	ValidateIP func(string) error
+}
+
+// Server 是管理接口的 HTTP 处理器
+type Server struct {
+	Options
+	mux *http.ServeMux
+}
+
+// NewServer 创建管理接口，所有请求都必须携带 "Authorization: Bearer <token>"
+func NewServer(opts Options) *Server {
+	s := &Server{Options: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /api/stats", s.handleStats)
+	s.mux.HandleFunc("GET /api/clients", s.handleListClients)
+	s.mux.HandleFunc("POST /api/clients/{username}/kick", s.handleKick)
+	s.mux.HandleFunc("GET /api/groups", s.handleListGroups)
+	s.mux.HandleFunc("POST /api/groups", s.handleCreateGroup)
+	s.mux.HandleFunc("DELETE /api/groups/{name}", s.handleDeleteGroup)
+	s.mux.HandleFunc("GET /api/bans", s.handleListBans)
+	s.mux.HandleFunc("POST /api/bans", s.handleBan)
+	s.mux.HandleFunc("DELETE /api/bans/users/{username}", s.handleUnbanUser)
+	s.mux.HandleFunc("DELETE /api/bans/ips/{ip...}", s.handleUnbanIP)
+	s.mux.HandleFunc("GET /api/accounts", s.handleListAccounts)
+	s.mux.HandleFunc("POST /api/accounts", s.handleCreateAccount)
+	s.mux.HandleFunc("PUT /api/accounts/{username}/password", s.handleSetPassword)
+	s.mux.HandleFunc("DELETE /api/accounts/{username}", s.handleDeleteAccount)
+	s.mux.HandleFunc("POST /api/announce", s.handleAnnounce)
+	s.mux.HandleFunc("GET /api/logs", s.handleLogs)
+	s.mux.HandleFunc("POST /api/reload", s.handleReload)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		slog.Warn("管理接口认证失败", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+		writeError(w, http.StatusUnauthorized, "未授权")
+		return
+	}
+	slog.Info("管理接口请求", "remote_addr", r.RemoteAddr, "method", r.Method, "path", r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized 以常量时间比较请求携带的令牌
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Hub.Stats())
+}
+
+func (s *Server) handleListClients(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Hub.ListClients())
+}
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Hub.ListGroups())
+}
+
+// handleCreateGroup 创建持久群组，持久群组在成员为空时不会被销毁，重启后依然存在
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "群组名不能为空")
+		return
+	}
+	if _, err := s.Groups.Add(req.Name); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.Hub.SetGroupPersistent(req.Name, true)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "被管理员踢出"
+	}
+	username := r.PathValue("username")
+	if !s.Hub.KickUser(username, req.Reason) {
+		writeError(w, http.StatusNotFound, "用户不在线: "+username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	persistent, err := s.Groups.Remove(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !s.Hub.DeleteGroup(name) && !persistent {
+		writeError(w, http.StatusNotFound, "群组不存在: "+name)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListBans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Bans.List())
+}
+
+// handleBan 封禁用户名或 IP，请求体为 {"username": "..."} 或 {"ip": "..."}
+func (s *Server) handleBan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		IP       string `json:"ip"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var err error
+	switch {
+	case req.Username != "" && req.IP == "":
+		_, err = s.Bans.BanUser(req.Username)
+	case req.IP != "" && req.Username == "":
+		if err := s.ValidateIP(req.IP); err != nil {
+			writeError(w, http.StatusBadRequest, "IP 无效: "+err.Error())
+			return
+		}
+		_, err = s.Bans.BanIP(req.IP)
+	default:
+		writeError(w, http.StatusBadRequest, "username 和 ip 必须且只能提供一个")
+		return
+	}
+	s.finishBanUpdate(w, true, err)
+}
+
+func (s *Server) handleUnbanUser(w http.ResponseWriter, r *http.Request) {
+	found, err := s.Bans.UnbanUser(r.PathValue("username"))
+	s.finishBanUpdate(w, found, err)
+}
+
+func (s *Server) handleUnbanIP(w http.ResponseWriter, r *http.Request) {
+	found, err := s.Bans.UnbanIP(r.PathValue("ip"))
+	s.finishBanUpdate(w, found, err)
+}
+
+// finishBanUpdate 在封禁列表修改后重新应用设置并写出响应
+func (s *Server) finishBanUpdate(w http.ResponseWriter, found bool, err error) {
+	if err == nil {
+		err = s.ApplyBans()
+	}
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case !found:
+		writeError(w, http.StatusNotFound, "封禁记录不存在")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Accounts.List())
+}
+
+func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err := s.Accounts.Add(req.Username, req.Password)
+	switch {
+	case errors.Is(err, store.ErrAccountExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Info("账号已创建", "account", req.Username)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (s *Server) handleSetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	username := r.PathValue("username")
+	err := s.Accounts.SetPassword(username, req.Password)
+	switch {
+	case errors.Is(err, store.ErrAccountNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Info("账号密码已修改", "account", username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	err := s.Accounts.Remove(username)
+	switch {
+	case errors.Is(err, store.ErrAccountNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		slog.Info("账号已删除", "account", username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "公告内容不能为空")
+		return
+	}
+	s.Hub.Announce(req.Text)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "limit 无效")
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, logging.Recent(limit))
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if s.Reload == nil {
+		writeError(w, http.StatusNotImplemented, "不支持重新加载配置")
+		return
+	}
+	if err := s.Reload("管理接口"); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readJSON 解析请求体，允许请求体为空
+func readJSON(r *http.Request, v any) error {
+	err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.New("请求体不是有效的 JSON")
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,73 @@
+// Package bots 是服务器内置的机器人，通过 core.Hub.RegisterBot 运行在服务器进程中
+package bots
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/pkg/protocol"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// FAQ 根据关键词回答常见问题。私聊中的任何消息都会被当作问题；
+// 在世界大厅和群组中只回答以问号开头的消息，如 "?wifi"
+type FAQ struct {
+	name    string
+	groups  []string
+	answers map[string]string // 关键词 (小写) 到答案的映射
+}
+
+// NewFAQ 创建问答机器人，groups 为启动后加入的群组
+func NewFAQ(name string, groups []string, answers map[string]string) *FAQ {
+	lower := make(map[string]string, len(answers))
+	for keyword, answer := range answers {
+		lower[strings.ToLower(keyword)] = answer
+	}
+	return &FAQ{name: name, groups: groups, answers: lower}
+}
+
+func (f *FAQ) Name() string { return f.name }
+
+func (f *FAQ) Start(ctx context.Context, s *core.BotSession) {
+	for _, group := range f.groups {
+		s.JoinGroup(group)
+	}
+}
+
+func (f *FAQ) HandleMessage(s *core.BotSession, message protocol.Message) {
+	question := strings.TrimSpace(message.TextPayload)
+	switch message.Type {
+	case protocol.PrivateMessage:
+	case protocol.BroadcastMessage, protocol.GroupMessage:
+		rest, ok := strings.CutPrefix(question, "?")
+		if !ok {
+			if rest, ok = strings.CutPrefix(question, "？"); !ok {
+				return
+			}
+		}
+		question = strings.TrimSpace(rest)
+	default:
+		return
+	}
+
+	keywords := slices.Sorted(maps.Keys(f.answers))
+	if question == "" || question == "帮助" || strings.EqualFold(question, "help") {
+		s.Reply(message, "可以询问的问题: "+strings.Join(keywords, "、"))
+		return
+	}
+	var answers []string
+	lower := strings.ToLower(question)
+	for _, keyword := range keywords {
+		if strings.Contains(lower, keyword) {
+			answers = append(answers, f.answers[keyword])
+		}
+	}
+	if len(answers) == 0 {
+		s.Reply(message, "没有找到答案，可以询问的问题: "+strings.Join(keywords, "、"))
+		return
+	}
+	s.Reply(message, strings.Join(answers, "\n"))
+}
+
+func (f *FAQ) HandlePresence(*core.BotSession, protocol.TreePayload) {}
@@ -0,0 +1,93 @@
+package bots
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/pkg/protocol"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxReminderDelay = 7 * 24 * time.Hour // 最长提醒时间
+	maxReminders     = 10                 // 每个用户同时等待的提醒数
+)
+
+// Reminder 在指定时间后提醒用户，命令为 "!remind <时长> <内容>"，如 "!remind 10m 开会"，
+// 在私聊、世界大厅或机器人所在的群组中发送均可，提醒发回原来的会话
+type Reminder struct {
+	name   string
+	groups []string
+
+	mu      sync.Mutex
+	ctx     context.Context // 机器人断开时取消，未触发的提醒随之作废
+	pending map[string]int  // 每个用户等待中的提醒数
+}
+
+// NewReminder 创建提醒机器人，groups 为启动后加入的群组
+func NewReminder(name string, groups []string) *Reminder {
+	return &Reminder{name: name, groups: groups, ctx: context.Background(), pending: make(map[string]int)}
+}
+
+func (r *Reminder) Name() string { return r.name }
+
+func (r *Reminder) Start(ctx context.Context, s *core.BotSession) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+	for _, group := range r.groups {
+		s.JoinGroup(group)
+	}
+}
+
+func (r *Reminder) HandleMessage(s *core.BotSession, message protocol.Message) {
+	if message.Type != protocol.PrivateMessage && message.Type != protocol.BroadcastMessage &&
+		message.Type != protocol.GroupMessage {
+		return
+	}
+	args, ok := strings.CutPrefix(strings.TrimSpace(message.TextPayload), "!remind")
+	if !ok {
+		return
+	}
+	delayText, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	text = strings.TrimSpace(text)
+	delay, err := time.ParseDuration(delayText)
+	if err != nil || text == "" {
+		s.Reply(message, "用法: !remind <时长> <内容>，时长如 30s、10m、1h30m")
+		return
+	}
+	if delay <= 0 || delay > maxReminderDelay {
+		s.Reply(message, fmt.Sprintf("提醒时间必须在 0 到 %s 之间", maxReminderDelay))
+		return
+	}
+
+	r.mu.Lock()
+	if r.pending[message.Sender] >= maxReminders {
+		r.mu.Unlock()
+		s.Reply(message, fmt.Sprintf("%s 已经有 %d 个等待中的提醒", message.Sender, maxReminders))
+		return
+	}
+	r.pending[message.Sender]++
+	ctx := r.ctx
+	r.mu.Unlock()
+
+	s.Reply(message, fmt.Sprintf("好的，%s 后提醒 %s", delay, message.Sender))
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			s.Reply(message, fmt.Sprintf("@%s 提醒: %s", message.Sender, text))
+		case <-ctx.Done():
+		}
+		r.mu.Lock()
+		if r.pending[message.Sender]--; r.pending[message.Sender] == 0 {
+			delete(r.pending, message.Sender)
+		}
+		r.mu.Unlock()
+	}()
+}
+
+func (r *Reminder) HandlePresence(*core.BotSession, protocol.TreePayload) {}
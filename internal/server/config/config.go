@@ -0,0 +1,623 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config 是服务器的完整配置
+type Config struct {
+	Listen    ListenConfig     `toml:"listen"`
+	Listeners []ListenerConfig `toml:"listeners"`
+	TLS       TLSConfig        `toml:"tls"`
+	Discovery DiscoveryConfig  `toml:"discovery"`
+	SSH       SSHConfig        `toml:"ssh"`
+	WebSocket WebSocketConfig  `toml:"websocket"`
+	Timeouts  TimeoutConfig    `toml:"timeouts"`
+	Limits    LimitConfig      `toml:"limits"`
+	Storage   StorageConfig    `toml:"storage"`
+	Metrics   MetricsConfig    `toml:"metrics"`
+	Admin     AdminConfig      `toml:"admin"`
+	Auth      AuthConfig       `toml:"auth"`
+	Bots      []BotConfig      `toml:"bots"`
+	Webhooks  []WebhookConfig  `toml:"webhooks"`
+	Incoming  IncomingConfig   `toml:"incoming"`
+
+	// 以下配置支持热加载
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+	Bans      BanConfig       `toml:"bans"`
+	Chat      ChatConfig      `toml:"chat"`
+	Log       LogConfig       `toml:"log"`
+}
+
+// ListenConfig 监听相关配置
+type ListenConfig struct {
+	Address string `toml:"address"` // 监听地址
+	Port    int    `toml:"port"`    // 监听端口
+}
+
+// ListenerConfig 一个监听端点。配置了任何监听端点时，[listen] 不再生效
+type ListenerConfig struct {
+	Network   string `toml:"network"`    // tcp、tcp4、tcp6 或 unix
+	Address   string `toml:"address"`    // 如 "[::]:8080"，unix 为套接字文件路径
+	Protocol  string `toml:"protocol"`   // gochat (默认)、text (纯文本行协议，可直接用 nc 连接) 或 irc
+	TLS       bool   `toml:"tls"`        // 使用 [tls] 中的证书，只接受 TLS 连接
+	AdminOnly bool   `toml:"admin_only"` // 只提供管理接口 (HTTP)，不接受聊天客户端
+}
+
+// HTTPAddress 返回管理接口等 HTTP 服务使用的地址形式，Unix 套接字为 "unix:<路径>"
+func (l ListenerConfig) HTTPAddress() string {
+	if l.Network == "unix" {
+		return "unix:" + l.Address
+	}
+	return l.Address
+}
+
+// ChatListeners 返回接受聊天客户端的监听端点，未配置 [[listeners]] 时使用 [listen] 和 [tls]
+func (c *Config) ChatListeners() []ListenerConfig {
+	if len(c.Listeners) == 0 {
+		return []ListenerConfig{{
+			Network: "tcp",
+			Address: net.JoinHostPort(c.Listen.Address, strconv.Itoa(c.Listen.Port)),
+			TLS:     c.TLS.Enabled,
+		}}
+	}
+	var listeners []ListenerConfig
+	for _, l := range c.Listeners {
+		if !l.AdminOnly {
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
+
+// UsesTLS 判断是否有任何端点需要 [tls] 中的证书
+func (c *Config) UsesTLS() bool {
+	if c.TLS.Enabled || c.WebSocket.TLS {
+		return true
+	}
+	for _, l := range c.Listeners {
+		if l.TLS {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSConfig 传输加密配置
+type TLSConfig struct {
+	Enabled  bool     `toml:"enabled"`   // [listen] 是否使用 TLS，开启后只接受加密连接
+	CertFile string   `toml:"cert_file"` // PEM 格式的证书文件
+	KeyFile  string   `toml:"key_file"`  // PEM 格式的私钥文件
+	AutoCert bool     `toml:"auto_cert"` // 未配置证书时自动生成自签名证书，保存在数据目录中
+	Hosts    []string `toml:"hosts"`     // 自签名证书包含的额外主机名或 IP
+
+	// ClientCAFile 不为空时启用双向 TLS: 客户端必须出示由该 CA 签发的证书，
+	// 证书的 Subject CN 作为用户名，登录请求中的用户名和密码将被忽略。
+	// 此时所有聊天端点都必须校验客户端证书，SSH 只能使用公钥登录
+	ClientCAFile string `toml:"client_ca_file"`
+}
+
+// WebSocketConfig WebSocket 传输配置，供浏览器或只能使用 HTTP 的客户端连接
+type WebSocketConfig struct {
+	Address string `toml:"address"` // HTTP 监听地址，如 0.0.0.0:8081，为空表示不启用
+	Path    string `toml:"path"`    // WebSocket 路径
+	TLS     bool   `toml:"tls"`     // 使用 [tls] 中的证书提供 wss://，该地址上的其它 HTTP 服务也会改用 HTTPS
+	Web     bool   `toml:"web"`     // 在该地址的根路径上提供网页客户端
+
+	// AllowedOrigins 允许连接的网页来源，如 https://chat.example.com；
+	// 为空时只允许与请求的 Host 相同的来源，没有 Origin 头的非浏览器客户端总是允许
+	AllowedOrigins []string `toml:"allowed_origins"`
+}
+
+// DiscoveryConfig 局域网服务器发现配置
+type DiscoveryConfig struct {
+	Enabled bool   `toml:"enabled"` // 回复局域网内客户端的 UDP 查询
+	Port    int    `toml:"port"`    // 接收查询的 UDP 端口
+	Name    string `toml:"name"`    // 显示在客户端中的服务器名称，为空时使用主机名
+}
+
+// SSHConfig SSH 前端配置，用户通过 SSH 登录后使用基于行的聊天界面
+type SSHConfig struct {
+	Address string `toml:"address"` // 监听地址，如 0.0.0.0:2222，为空表示不启用
+
+	HostKeyFile string `toml:"host_key_file"` // 主机私钥，为空时使用 <data_dir>/ssh/host_key，不存在时自动生成
+
+	// AuthorizedKeysFile 是 authorized_keys 格式的公钥列表，公钥的注释即聊天用户名，
+	// 为空时使用 <data_dir>/ssh/authorized_keys
+	AuthorizedKeysFile string `toml:"authorized_keys_file"`
+
+	// PasswordAuth 允许以 SSH 登录名和账号密码登录，规则与普通客户端登录相同
+	PasswordAuth bool `toml:"password_auth"`
+}
+
+// TimeoutConfig 连接读写超时配置
+type TimeoutConfig struct {
+	Read  time.Duration `toml:"read"`  // 读取超时，超过该时间未收到任何数据则断开
+	Write time.Duration `toml:"write"` // 单条消息的写入超时
+}
+
+// LimitConfig 各类容量与数量限制
+type LimitConfig struct {
+	SendBuffer   int `toml:"send_buffer"`    // 每个客户端发送通道的缓冲大小
+	MaxFrameSize int `toml:"max_frame_size"` // 单个数据帧的最大字节数
+	MaxClients   int `toml:"max_clients"`    // 最大连接数，0 表示不限制
+	History      int `toml:"history"`        // 世界大厅和每个群组保留的最近消息数，0 表示不保留
+}
+
+// StorageConfig 持久化数据的存放位置
+type StorageConfig struct {
+	DataDir string `toml:"data_dir"` // 数据目录
+}
+
+// MetricsConfig Prometheus 指标接口配置
+type MetricsConfig struct {
+	Address string `toml:"address"` // HTTP 监听地址，如 127.0.0.1:9090，为空表示不启用
+	Path    string `toml:"path"`    // 指标路径
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Address   string `toml:"address"`    // HTTP 监听地址，如 127.0.0.1:9091，为空表示不启用
+	Token     string `toml:"token"`      // 访问令牌，建议通过环境变量设置
+	LogBuffer int    `toml:"log_buffer"` // 可通过管理接口查看的最近日志条数
+}
+
+// AuthConfig 登录认证配置，账号通过管理接口维护
+type AuthConfig struct {
+	RequireAccount bool `toml:"require_account"` // 为 true 时只有已注册的账号才能登录
+}
+
+// BotConfig 服务器内置的机器人，以自己的用户名登录，在在线列表中标记为机器人
+type BotConfig struct {
+	Kind    string            `toml:"kind"`    // faq (关键词问答) 或 remind (定时提醒)
+	Name    string            `toml:"name"`    // 机器人的用户名
+	Groups  []string          `toml:"groups"`  // 启动后加入的群组
+	Answers map[string]string `toml:"answers"` // faq 使用的关键词和答案
+}
+
+// WebhookConfig 一个外发 Webhook: 世界大厅或指定群组中的消息，以及提到关键词的消息，
+// 以 JSON 格式 POST 到 URL。私聊和端到端加密的消息从不外发
+type WebhookConfig struct {
+	Name     string        `toml:"name"`     // 名称，用于日志和指标
+	URL      string        `toml:"url"`      // 接收消息的 HTTP(S) 地址
+	Secret   string        `toml:"secret"`   // 不为空时用 HMAC-SHA256 签名请求体
+	Lobby    bool          `toml:"lobby"`    // 外发世界大厅的所有消息
+	Groups   []string      `toml:"groups"`   // 外发这些群组的所有消息
+	Keywords []string      `toml:"keywords"` // 外发世界大厅和任意群组中包含这些词的消息，不区分大小写
+	Retries  int           `toml:"retries"`  // 请求失败后的重试次数，0 表示不重试
+	Timeout  time.Duration `toml:"timeout"`  // 单次请求的超时，0 表示默认的 10 秒
+}
+
+// IncomingConfig 接收外部消息的 Webhook，CI、监控等服务通过 HTTP 请求以机器人的身份在群组或世界大厅发言
+type IncomingConfig struct {
+	Address string               `toml:"address"` // HTTP 监听地址，可以与指标或管理接口相同，为空表示不启用
+	Path    string               `toml:"path"`    // 路径前缀，每个 Webhook 的地址为 <path><name>
+	Hooks   []IncomingHookConfig `toml:"hooks"`
+}
+
+// IncomingHookConfig 一个接收外部消息的 Webhook
+type IncomingHookConfig struct {
+	Name     string `toml:"name"`     // 请求路径中的名称
+	Token    string `toml:"token"`    // 访问令牌，请求需携带 "Authorization: Bearer <token>"
+	Bot      string `toml:"bot"`      // 发言使用的机器人用户名，多个 Webhook 可以共用
+	Group    string `toml:"group"`    // 目标群组，为空时发到世界大厅
+	Template string `toml:"template"` // 消息格式，text/template 模板，数据为请求体中的 JSON 对象
+}
+
+// RateLimitConfig 每个客户端的聊天消息频率限制（令牌桶）
+type RateLimitConfig struct {
+	MessagesPerSecond float64 `toml:"messages_per_second"` // 每秒恢复的令牌数，0 表示不限制
+	Burst             int     `toml:"burst"`               // 令牌桶容量
+}
+
+// BanConfig 封禁列表
+type BanConfig struct {
+	Users []string `toml:"users"` // 被封禁的用户名
+	IPs   []string `toml:"ips"`   // 被封禁的 IP 或网段 (CIDR)
+}
+
+// ChatConfig 聊天内容相关配置
+type ChatConfig struct {
+	MOTD        string   `toml:"motd"`         // 登录后发送给用户的欢迎消息
+	WordFilters []string `toml:"word_filters"` // 需要被屏蔽的词语，不区分大小写
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level   string `toml:"level"`   // debug, info, warn, error
+	Format  string `toml:"format"`  // text 或 json
+	Privacy bool   `toml:"privacy"` // 隐私模式，开启后从不记录消息正文
+	File    string `toml:"file"`    // 日志文件路径，为空时输出到标准错误
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Listen: ListenConfig{
+			Address: "0.0.0.0",
+			Port:    8080,
+		},
+		WebSocket: WebSocketConfig{
+			Path: "/ws",
+		},
+		Discovery: DiscoveryConfig{
+			Port: 8089,
+		},
+		Timeouts: TimeoutConfig{
+			Read:  120 * time.Second,
+			Write: 60 * time.Second,
+		},
+		Limits: LimitConfig{
+			SendBuffer:   256,
+			MaxFrameSize: 64 << 20,
+			MaxClients:   0,
+			History:      100,
+		},
+		Storage: StorageConfig{
+			DataDir: "data",
+		},
+		Metrics: MetricsConfig{
+			Path: "/metrics",
+		},
+		Admin: AdminConfig{
+			LogBuffer: 1000,
+		},
+		Incoming: IncomingConfig{
+			Path: "/hooks/",
+		},
+		RateLimit: RateLimitConfig{
+			MessagesPerSecond: 0,
+			Burst:             10,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Validate 检查配置是否合法
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Listen.Port < 1 || c.Listen.Port > 65535 {
+		errs = append(errs, fmt.Errorf("listen.port 超出范围: %d", c.Listen.Port))
+	}
+	if c.TLS.Enabled && c.TLS.CertFile == "" && !c.TLS.AutoCert {
+		errs = append(errs, fmt.Errorf("启用 TLS 时需要设置 tls.cert_file 或开启 tls.auto_cert"))
+	}
+	if c.TLS.ClientCAFile != "" {
+		if !c.UsesTLS() {
+			errs = append(errs, fmt.Errorf("设置 tls.client_ca_file 时必须启用 TLS"))
+		}
+		// 用户可以自己填写用户名的端点会被用来冒充持有证书的用户，双向 TLS 下全部拒绝
+		for _, l := range c.ChatListeners() {
+			if !l.TLS {
+				errs = append(errs, fmt.Errorf("设置 tls.client_ca_file 时聊天端点 %s 必须开启 tls", l.Address))
+			}
+			if l.Protocol == "text" {
+				errs = append(errs, fmt.Errorf("设置 tls.client_ca_file 时不能使用纯文本协议端点 %s，它不支持客户端证书认证", l.Address))
+			}
+		}
+		if c.WebSocket.Address != "" && !c.WebSocket.TLS {
+			errs = append(errs, fmt.Errorf("设置 tls.client_ca_file 时 websocket.tls 必须开启"))
+		}
+		if c.SSH.PasswordAuth {
+			errs = append(errs, fmt.Errorf("设置 tls.client_ca_file 时不能开启 ssh.password_auth"))
+		}
+	}
+	for i, l := range c.Listeners {
+		switch l.Network {
+		case "tcp", "tcp4", "tcp6", "unix":
+		default:
+			errs = append(errs, fmt.Errorf("listeners[%d].network 无效: %q", i, l.Network))
+		}
+		switch l.Protocol {
+		case "", "gochat", "text", "irc":
+		default:
+			errs = append(errs, fmt.Errorf("listeners[%d].protocol 无效: %q", i, l.Protocol))
+		}
+		if l.Address == "" {
+			errs = append(errs, fmt.Errorf("listeners[%d].address 不能为空", i))
+		}
+		if l.TLS && c.TLS.CertFile == "" && !c.TLS.AutoCert {
+			errs = append(errs, fmt.Errorf("listeners[%d].tls 需要设置 tls.cert_file 或开启 tls.auto_cert", i))
+		}
+		if l.AdminOnly && len(c.Admin.Token) < 16 {
+			errs = append(errs, fmt.Errorf("listeners[%d] 提供管理接口，admin.token 至少需要 16 个字符", i))
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("tls.cert_file 和 tls.key_file 必须同时设置"))
+	}
+	if c.WebSocket.Address != "" && !strings.HasPrefix(c.WebSocket.Path, "/") {
+		errs = append(errs, fmt.Errorf("websocket.path 必须以 / 开头"))
+	}
+	if c.WebSocket.Web && (c.WebSocket.Address == "" || c.WebSocket.Path == "/") {
+		errs = append(errs, fmt.Errorf("websocket.web 需要设置 websocket.address，且 websocket.path 不能为 /"))
+	}
+	if c.WebSocket.TLS && c.TLS.CertFile == "" && !c.TLS.AutoCert {
+		errs = append(errs, fmt.Errorf("websocket.tls 需要设置 tls.cert_file 或开启 tls.auto_cert"))
+	}
+	if c.Discovery.Enabled && (c.Discovery.Port < 1 || c.Discovery.Port > 65535) {
+		errs = append(errs, fmt.Errorf("discovery.port 超出范围: %d", c.Discovery.Port))
+	}
+	if c.Timeouts.Read <= 0 {
+		errs = append(errs, fmt.Errorf("timeouts.read 必须大于 0"))
+	}
+	if c.Timeouts.Write <= 0 {
+		errs = append(errs, fmt.Errorf("timeouts.write 必须大于 0"))
+	}
+	if c.Limits.SendBuffer < 1 {
+		errs = append(errs, fmt.Errorf("limits.send_buffer 必须大于 0"))
+	}
+	if c.Limits.MaxFrameSize < 1024 {
+		errs = append(errs, fmt.Errorf("limits.max_frame_size 不能小于 1024"))
+	}
+	if c.Limits.MaxClients < 0 {
+		errs = append(errs, fmt.Errorf("limits.max_clients 不能为负数"))
+	}
+	if c.Limits.History < 0 {
+		errs = append(errs, fmt.Errorf("limits.history 不能为负数"))
+	}
+	if c.Storage.DataDir == "" {
+		errs = append(errs, fmt.Errorf("storage.data_dir 不能为空"))
+	}
+	if c.Metrics.Address != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+		errs = append(errs, fmt.Errorf("metrics.path 必须以 / 开头"))
+	}
+	if c.Admin.Address != "" && len(c.Admin.Token) < 16 {
+		errs = append(errs, fmt.Errorf("启用管理接口时 admin.token 至少需要 16 个字符"))
+	}
+	if c.Admin.LogBuffer < 0 {
+		errs = append(errs, fmt.Errorf("admin.log_buffer 不能为负数"))
+	}
+	if c.RateLimit.MessagesPerSecond < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.messages_per_second 不能为负数"))
+	}
+	if c.RateLimit.MessagesPerSecond > 0 && c.RateLimit.Burst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.burst 必须大于 0"))
+	}
+	for _, ip := range c.Bans.IPs {
+		if _, err := ParseIPPrefix(ip); err != nil {
+			errs = append(errs, fmt.Errorf("bans.ips 中的 %q 无效: %w", ip, err))
+		}
+	}
+	botNames := make(map[string]bool)
+	for i, b := range c.Bots {
+		switch b.Kind {
+		case "faq":
+			if len(b.Answers) == 0 {
+				errs = append(errs, fmt.Errorf("bots[%d] 是问答机器人，answers 不能为空", i))
+			}
+		case "remind":
+		default:
+			errs = append(errs, fmt.Errorf("bots[%d].kind 无效: %q", i, b.Kind))
+		}
+		if b.Name == "" {
+			errs = append(errs, fmt.Errorf("bots[%d].name 不能为空", i))
+		} else if botNames[b.Name] {
+			errs = append(errs, fmt.Errorf("bots[%d].name 重复: %q", i, b.Name))
+		}
+		botNames[b.Name] = true
+	}
+	webhookNames := make(map[string]bool)
+	for i, w := range c.Webhooks {
+		if w.Name == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d].name 不能为空", i))
+		} else if webhookNames[w.Name] {
+			errs = append(errs, fmt.Errorf("webhooks[%d].name 重复: %q", i, w.Name))
+		}
+		webhookNames[w.Name] = true
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d].url 无效: %q", i, w.URL))
+		}
+		if !w.Lobby && len(w.Groups) == 0 && len(w.Keywords) == 0 {
+			errs = append(errs, fmt.Errorf("webhooks[%d] 至少需要设置 lobby、groups 或 keywords 之一", i))
+		}
+		if w.Retries < 0 {
+			errs = append(errs, fmt.Errorf("webhooks[%d].retries 不能为负数", i))
+		}
+		if w.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("webhooks[%d].timeout 不能为负数", i))
+		}
+	}
+	if c.Incoming.Address != "" {
+		if !strings.HasPrefix(c.Incoming.Path, "/") || !strings.HasSuffix(c.Incoming.Path, "/") {
+			errs = append(errs, fmt.Errorf("incoming.path 必须以 / 开头和结尾"))
+		}
+		if len(c.Incoming.Hooks) == 0 {
+			errs = append(errs, fmt.Errorf("启用 incoming 时至少需要一个 [[incoming.hooks]]"))
+		}
+	}
+	hookNames := make(map[string]bool)
+	for i, h := range c.Incoming.Hooks {
+		if h.Name == "" || strings.Contains(h.Name, "/") {
+			errs = append(errs, fmt.Errorf("incoming.hooks[%d].name 不能为空或包含 /", i))
+		} else if hookNames[h.Name] {
+			errs = append(errs, fmt.Errorf("incoming.hooks[%d].name 重复: %q", i, h.Name))
+		}
+		hookNames[h.Name] = true
+		if len(h.Token) < 16 {
+			errs = append(errs, fmt.Errorf("incoming.hooks[%d].token 至少需要 16 个字符", i))
+		}
+		if h.Bot == "" {
+			errs = append(errs, fmt.Errorf("incoming.hooks[%d].bot 不能为空", i))
+		} else if botNames[h.Bot] {
+			errs = append(errs, fmt.Errorf("incoming.hooks[%d].bot 与 [[bots]] 中的机器人重名: %q", i, h.Bot))
+		}
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level 无效: %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format 无效: %q", c.Log.Format))
+	}
+	return errors.Join(errs...)
+}
+
+// override 描述一个可由环境变量和命令行参数覆盖的配置项
+type override struct {
+	flag  string
+	env   string
+	usage string
+	set   func(string) error
+}
+
+func (c *Config) overrides() []override {
+	return []override{
+		{"addr", "GOCHAT_LISTEN_ADDRESS", "监听地址", setString(&c.Listen.Address)},
+		{"port", "GOCHAT_LISTEN_PORT", "监听端口", setInt(&c.Listen.Port)},
+		{"tls", "GOCHAT_TLS", "使用 TLS 加密连接 (true/false)", setBool(&c.TLS.Enabled)},
+		{"tls-cert", "GOCHAT_TLS_CERT", "TLS 证书文件", setString(&c.TLS.CertFile)},
+		{"tls-key", "GOCHAT_TLS_KEY", "TLS 私钥文件", setString(&c.TLS.KeyFile)},
+		{"tls-auto-cert", "GOCHAT_TLS_AUTO_CERT", "未配置证书时自动生成自签名证书 (true/false)", setBool(&c.TLS.AutoCert)},
+		{"tls-client-ca", "GOCHAT_TLS_CLIENT_CA", "客户端证书的 CA，设置后启用双向 TLS 认证", setString(&c.TLS.ClientCAFile)},
+		{"ws-addr", "GOCHAT_WS_ADDRESS", "WebSocket 监听地址，为空表示不启用", setString(&c.WebSocket.Address)},
+		{"ws-path", "GOCHAT_WS_PATH", "WebSocket 路径", setString(&c.WebSocket.Path)},
+		{"ws-tls", "GOCHAT_WS_TLS", "WebSocket 使用 TLS (true/false)", setBool(&c.WebSocket.TLS)},
+		{"web", "GOCHAT_WEB", "在 WebSocket 地址上提供网页客户端 (true/false)", setBool(&c.WebSocket.Web)},
+		{"discovery", "GOCHAT_DISCOVERY", "允许局域网内的客户端发现本服务器 (true/false)", setBool(&c.Discovery.Enabled)},
+		{"discovery-name", "GOCHAT_DISCOVERY_NAME", "局域网发现中显示的服务器名称", setString(&c.Discovery.Name)},
+		{"ssh-addr", "GOCHAT_SSH_ADDRESS", "SSH 监听地址，为空表示不启用", setString(&c.SSH.Address)},
+		{"read-timeout", "GOCHAT_READ_TIMEOUT", "读取超时", setDuration(&c.Timeouts.Read)},
+		{"write-timeout", "GOCHAT_WRITE_TIMEOUT", "写入超时", setDuration(&c.Timeouts.Write)},
+		{"send-buffer", "GOCHAT_SEND_BUFFER", "客户端发送缓冲大小", setInt(&c.Limits.SendBuffer)},
+		{"max-frame-size", "GOCHAT_MAX_FRAME_SIZE", "单帧最大字节数", setInt(&c.Limits.MaxFrameSize)},
+		{"max-clients", "GOCHAT_MAX_CLIENTS", "最大连接数 (0 为不限制)", setInt(&c.Limits.MaxClients)},
+		{"history", "GOCHAT_HISTORY", "世界大厅和每个群组保留的最近消息数 (0 为不保留)", setInt(&c.Limits.History)},
+		{"data-dir", "GOCHAT_DATA_DIR", "数据目录", setString(&c.Storage.DataDir)},
+		{"metrics-addr", "GOCHAT_METRICS_ADDRESS", "指标接口监听地址，为空表示不启用", setString(&c.Metrics.Address)},
+		{"admin-addr", "GOCHAT_ADMIN_ADDRESS", "管理接口监听地址，为空表示不启用", setString(&c.Admin.Address)},
+		{"admin-token", "GOCHAT_ADMIN_TOKEN", "管理接口访问令牌", setString(&c.Admin.Token)},
+		{"incoming-addr", "GOCHAT_INCOMING_ADDRESS", "接收外部消息的 Webhook 监听地址，为空表示不启用", setString(&c.Incoming.Address)},
+		{"require-account", "GOCHAT_REQUIRE_ACCOUNT", "只允许已注册的账号登录 (true/false)", setBool(&c.Auth.RequireAccount)},
+		{"log-level", "GOCHAT_LOG_LEVEL", "日志级别", setString(&c.Log.Level)},
+		{"log-format", "GOCHAT_LOG_FORMAT", "日志格式 (text 或 json)", setString(&c.Log.Format)},
+		{"log-privacy", "GOCHAT_LOG_PRIVACY", "隐私模式，不记录消息正文 (true/false)", setBool(&c.Log.Privacy)},
+		{"log-file", "GOCHAT_LOG_FILE", "日志文件路径", setString(&c.Log.File)},
+	}
+}
+
+// Loader 负责按 默认值 < 配置文件 < 环境变量 < 命令行参数 的优先级加载配置，
+// 可以多次调用 Load 以重新读取配置文件
+type Loader struct {
+	Path  string            // 配置文件路径，为空表示不使用配置文件
+	flags map[string]string // 命令行中显式设置的参数
+}
+
+// NewLoader 解析命令行参数并创建 Loader
+func NewLoader(args []string) (*Loader, error) {
+	l := &Loader{flags: make(map[string]string)}
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&l.Path, "config", os.Getenv("GOCHAT_CONFIG"), "配置文件路径 (GOCHAT_CONFIG)")
+	for _, o := range Default().overrides() {
+		name := o.flag
+		fs.Func(name, fmt.Sprintf("%s (%s)", o.usage, o.env), func(v string) error {
+			l.flags[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Load 读取并校验配置
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+	if l.Path != "" {
+		if _, err := toml.DecodeFile(l.Path, cfg); err != nil {
+			return nil, fmt.Errorf("读取配置文件 %s 失败: %w", l.Path, err)
+		}
+	}
+
+	overrides := cfg.overrides()
+	for _, o := range overrides {
+		if v, ok := os.LookupEnv(o.env); ok {
+			if err := o.set(v); err != nil {
+				return nil, fmt.Errorf("环境变量 %s 无效: %w", o.env, err)
+			}
+		}
+	}
+	for _, o := range overrides {
+		if v, ok := l.flags[o.flag]; ok {
+			if err := o.set(v); err != nil {
+				return nil, fmt.Errorf("参数 -%s 无效: %w", o.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置校验失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// ParseIPPrefix 将单个 IP 或 CIDR 网段解析为网段
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func setString(p *string) func(string) error {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p = b
+		return nil
+	}
+}
+
+func setDuration(p *time.Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
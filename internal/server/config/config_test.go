@@ -0,0 +1,227 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string // 为空表示配置合法
+	}{
+		{"默认配置", func(c *Config) {}, ""},
+		{"端口为 0", func(c *Config) { c.Listen.Port = 0 }, "listen.port 超出范围"},
+		{"端口过大", func(c *Config) { c.Listen.Port = 65536 }, "listen.port 超出范围"},
+		{"读取超时为 0", func(c *Config) { c.Timeouts.Read = 0 }, "timeouts.read 必须大于 0"},
+		{"写入超时为负数", func(c *Config) { c.Timeouts.Write = -time.Second }, "timeouts.write 必须大于 0"},
+		{"发送缓冲为 0", func(c *Config) { c.Limits.SendBuffer = 0 }, "limits.send_buffer 必须大于 0"},
+		{"帧过小", func(c *Config) { c.Limits.MaxFrameSize = 100 }, "limits.max_frame_size 不能小于 1024"},
+		{"最大连接数为负数", func(c *Config) { c.Limits.MaxClients = -1 }, "limits.max_clients 不能为负数"},
+		{"数据目录为空", func(c *Config) { c.Storage.DataDir = "" }, "storage.data_dir 不能为空"},
+		{"日志级别无效", func(c *Config) { c.Log.Level = "verbose" }, "log.level 无效"},
+		{"日志格式无效", func(c *Config) { c.Log.Format = "xml" }, "log.format 无效"},
+		{"管理令牌过短", func(c *Config) {
+			c.Admin.Address = "127.0.0.1:9091"
+			c.Admin.Token = "short"
+		}, "admin.token 至少需要 16 个字符"},
+		{"管理令牌足够长", func(c *Config) {
+			c.Admin.Address = "127.0.0.1:9091"
+			c.Admin.Token = "0123456789abcdef"
+		}, ""},
+		{"TLS 没有证书", func(c *Config) { c.TLS.Enabled = true }, "tls.cert_file 或开启 tls.auto_cert"},
+		{"TLS 自动生成证书", func(c *Config) {
+			c.TLS.Enabled = true
+			c.TLS.AutoCert = true
+		}, ""},
+		{"证书和私钥只设置了一个", func(c *Config) {
+			c.TLS.AutoCert = true
+			c.TLS.CertFile = "cert.pem"
+		}, "tls.cert_file 和 tls.key_file 必须同时设置"},
+		{"监听端点网络无效", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "udp", Address: ":8080"}}
+		}, "listeners[0].network 无效"},
+		{"监听端点协议无效", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "tcp", Address: ":8080", Protocol: "xmpp"}}
+		}, "listeners[0].protocol 无效"},
+		{"监听端点地址为空", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "unix"}}
+		}, "listeners[0].address 不能为空"},
+		{"WebSocket 路径不以 / 开头", func(c *Config) {
+			c.WebSocket.Address = ":8081"
+			c.WebSocket.Path = "ws"
+		}, "websocket.path 必须以 / 开头"},
+		{"机器人类型无效", func(c *Config) {
+			c.Bots = []BotConfig{{Kind: "echo", Name: "bot"}}
+		}, "bots[0].kind 无效"},
+		{"机器人重名", func(c *Config) {
+			c.Bots = []BotConfig{{Kind: "remind", Name: "bot"}, {Kind: "remind", Name: "bot"}}
+		}, "bots[1].name 重复"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, 期望合法", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.toml")
+	data := "[listen]\nport = 7000\naddress = \"127.0.0.1\"\n[log]\nlevel = \"warn\"\n[limits]\nhistory = 5\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOCHAT_LISTEN_PORT", "7001")
+	t.Setenv("GOCHAT_LOG_LEVEL", "debug")
+
+	loader, err := NewLoader([]string{"-config", path, "-port", "7002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"命令行覆盖环境变量", cfg.Listen.Port, 7002},
+		{"环境变量覆盖配置文件", cfg.Log.Level, "debug"},
+		{"配置文件覆盖默认值", cfg.Listen.Address, "127.0.0.1"},
+		{"配置文件覆盖默认值", cfg.Limits.History, 5},
+		{"未设置时使用默认值", cfg.Timeouts.Read, 120 * time.Second},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: 得到 %v, 期望 %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoaderInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{"环境变量不是数字", map[string]string{"GOCHAT_LISTEN_PORT": "abc"}, nil, "环境变量 GOCHAT_LISTEN_PORT 无效"},
+		{"参数不是时长", nil, []string{"-read-timeout", "soon"}, "参数 -read-timeout 无效"},
+		{"校验失败", nil, []string{"-log-format", "xml"}, "配置校验失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			loader, err := NewLoader(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseIPPrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"192.168.1.10", "192.168.1.10/32", false},
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"::1", "::1/128", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"example.com", "", true},
+		{"10.0.0.0/33", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIPPrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIPPrefix(%q) 错误 = %v, 期望出错 %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseIPPrefix(%q) = %s, 期望 %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMutualTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"只有 TLS 端点", func(c *Config) {}, ""},
+		{"没有启用 TLS", func(c *Config) { c.TLS.Enabled = false }, "设置 tls.client_ca_file 时必须启用 TLS"},
+		{"明文监听端点", func(c *Config) {
+			c.Listeners = []ListenerConfig{
+				{Network: "tcp", Address: ":8443", TLS: true},
+				{Network: "tcp", Address: ":8080"},
+			}
+		}, "聊天端点 :8080 必须开启 tls"},
+		{"TLS 上的纯文本协议", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "tcp", Address: ":8081", Protocol: "text", TLS: true}}
+		}, "不能使用纯文本协议端点 :8081"},
+		{"TLS 上的 IRC", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "tcp", Address: ":6697", Protocol: "irc", TLS: true}}
+		}, ""},
+		{"只提供管理接口的明文端点", func(c *Config) {
+			c.Admin.Token = "0123456789abcdef"
+			c.Listeners = []ListenerConfig{
+				{Network: "tcp", Address: ":8443", TLS: true},
+				{Network: "unix", Address: "/run/gochat.sock", AdminOnly: true},
+			}
+		}, ""},
+		{"明文 WebSocket", func(c *Config) { c.WebSocket.Address = ":8081" }, "websocket.tls 必须开启"},
+		{"wss", func(c *Config) {
+			c.WebSocket.Address = ":8081"
+			c.WebSocket.TLS = true
+		}, ""},
+		{"SSH 密码登录", func(c *Config) {
+			c.SSH.Address = ":2222"
+			c.SSH.PasswordAuth = true
+		}, "不能开启 ssh.password_auth"},
+		{"SSH 公钥登录", func(c *Config) { c.SSH.Address = ":2222" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.TLS.Enabled = true
+			c.TLS.AutoCert = true
+			c.TLS.ClientCAFile = "ca.pem"
+			tt.modify(c)
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, 期望合法", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
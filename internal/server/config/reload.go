@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Diff 比较两份配置中可热加载的部分，返回形如 "key: 旧值 -> 新值" 的变更描述
+func Diff(old, new *Config) []string {
+	var changes []string
+	add := func(key string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", key, a, b))
+		}
+	}
+	add("rate_limit.messages_per_second", old.RateLimit.MessagesPerSecond, new.RateLimit.MessagesPerSecond)
+	add("rate_limit.burst", old.RateLimit.Burst, new.RateLimit.Burst)
+	listDiff(&changes, "bans.users", old.Bans.Users, new.Bans.Users)
+	listDiff(&changes, "bans.ips", old.Bans.IPs, new.Bans.IPs)
+	add("chat.motd", fmt.Sprintf("%q", old.Chat.MOTD), fmt.Sprintf("%q", new.Chat.MOTD))
+	listDiff(&changes, "chat.word_filters", old.Chat.WordFilters, new.Chat.WordFilters)
+	add("log.level", old.Log.Level, new.Log.Level)
+	add("log.privacy", old.Log.Privacy, new.Log.Privacy)
+	return changes
+}
+
+// ApplyReloadable 返回 running 的副本，其中可热加载的部分取自 loaded，其余部分保持不变
+func ApplyReloadable(running, loaded *Config) *Config {
+	next := *running
+	next.RateLimit = loaded.RateLimit
+	next.Bans = loaded.Bans
+	next.Chat = loaded.Chat
+	next.Log.Level = loaded.Log.Level
+	next.Log.Privacy = loaded.Log.Privacy
+	return &next
+}
+
+// RestartRequired 返回发生了变化但需要重启才能生效的配置段
+func RestartRequired(old, new *Config) []string {
+	var sections []string
+	if !reflect.DeepEqual(old.Listen, new.Listen) {
+		sections = append(sections, "listen")
+	}
+	if !reflect.DeepEqual(old.Listeners, new.Listeners) {
+		sections = append(sections, "listeners")
+	}
+	if !reflect.DeepEqual(old.TLS, new.TLS) {
+		sections = append(sections, "tls")
+	}
+	if !reflect.DeepEqual(old.WebSocket, new.WebSocket) {
+		sections = append(sections, "websocket")
+	}
+	if !reflect.DeepEqual(old.Discovery, new.Discovery) {
+		sections = append(sections, "discovery")
+	}
+	if !reflect.DeepEqual(old.SSH, new.SSH) {
+		sections = append(sections, "ssh")
+	}
+	if !reflect.DeepEqual(old.Timeouts, new.Timeouts) {
+		sections = append(sections, "timeouts")
+	}
+	if !reflect.DeepEqual(old.Limits, new.Limits) {
+		sections = append(sections, "limits")
+	}
+	if !reflect.DeepEqual(old.Storage, new.Storage) {
+		sections = append(sections, "storage")
+	}
+	if !reflect.DeepEqual(old.Metrics, new.Metrics) {
+		sections = append(sections, "metrics")
+	}
+	if !reflect.DeepEqual(old.Admin, new.Admin) {
+		sections = append(sections, "admin")
+	}
+	if !reflect.DeepEqual(old.Auth, new.Auth) {
+		sections = append(sections, "auth")
+	}
+	if !reflect.DeepEqual(old.Bots, new.Bots) {
+		sections = append(sections, "bots")
+	}
+	if !reflect.DeepEqual(old.Webhooks, new.Webhooks) {
+		sections = append(sections, "webhooks")
+	}
+	if !reflect.DeepEqual(old.Incoming, new.Incoming) {
+		sections = append(sections, "incoming")
+	}
+	if old.Log.Format != new.Log.Format || old.Log.File != new.Log.File {
+		sections = append(sections, "log")
+	}
+	return sections
+}
+
+// listDiff 以新增/移除的形式描述列表的变化，避免打印整张列表
+func listDiff(changes *[]string, key string, old, new []string) {
+	var added, removed []string
+	for _, v := range new {
+		if !slices.Contains(old, v) {
+			added = append(added, v)
+		}
+	}
+	for _, v := range old {
+		if !slices.Contains(new, v) {
+			removed = append(removed, v)
+		}
+	}
+	if len(added) > 0 {
+		*changes = append(*changes, fmt.Sprintf("%s: 新增 %v", key, added))
+	}
+	if len(removed) > 0 {
+		*changes = append(*changes, fmt.Sprintf("%s: 移除 %v", key, removed))
+	}
+}
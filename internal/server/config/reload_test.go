@@ -0,0 +1,126 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"没有变化", func(c *Config) {}, nil},
+		{"频率限制", func(c *Config) { c.RateLimit.MessagesPerSecond = 2 }, []string{"rate_limit.messages_per_second: 0 -> 2"}},
+		{"封禁用户", func(c *Config) { c.Bans.Users = []string{"mallory"} }, []string{"bans.users: 新增 [mallory]"}},
+		{"欢迎消息", func(c *Config) { c.Chat.MOTD = "hi" }, []string{`chat.motd: "" -> "hi"`}},
+		{"日志级别和隐私模式", func(c *Config) {
+			c.Log.Level = "debug"
+			c.Log.Privacy = true
+		}, []string{"log.level: info -> debug", "log.privacy: false -> true"}},
+		{"需要重启的配置不在其中", func(c *Config) {
+			c.Listen.Port = 9000
+			c.Log.Format = "json"
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := Default()
+			tt.modify(next)
+			if got := Diff(Default(), next); !slices.Equal(got, tt.want) {
+				t.Fatalf("Diff() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []string
+		want     []string
+	}{
+		{"相同", []string{"a", "b"}, []string{"b", "a"}, nil},
+		{"新增", nil, []string{"a"}, []string{"k: 新增 [a]"}},
+		{"移除", []string{"a", "b"}, []string{"a"}, []string{"k: 移除 [b]"}},
+		{"新增和移除", []string{"a"}, []string{"b"}, []string{"k: 新增 [b]", "k: 移除 [a]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			listDiff(&got, "k", tt.old, tt.new)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("listDiff() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyReloadable(t *testing.T) {
+	running := Default()
+	loaded := Default()
+	loaded.Listen.Port = 9000
+	loaded.Timeouts.Read = time.Second
+	loaded.Log.Format = "json"
+	loaded.Log.Level = "warn"
+	loaded.Chat.MOTD = "hi"
+	loaded.Bans.IPs = []string{"10.0.0.0/8"}
+	loaded.RateLimit.Burst = 3
+
+	next := ApplyReloadable(running, loaded)
+	if next == running {
+		t.Fatal("ApplyReloadable 应返回副本")
+	}
+	if next.Listen.Port != 8080 || next.Timeouts.Read != 120*time.Second || next.Log.Format != "text" {
+		t.Errorf("需要重启的配置被修改: port=%d read=%v format=%s", next.Listen.Port, next.Timeouts.Read, next.Log.Format)
+	}
+	if next.Log.Level != "warn" || next.Chat.MOTD != "hi" || !slices.Equal(next.Bans.IPs, loaded.Bans.IPs) || next.RateLimit.Burst != 3 {
+		t.Errorf("可热加载的配置没有生效: %+v", next)
+	}
+	if running.Log.Level != "info" {
+		t.Errorf("running 被修改: log.level=%s", running.Log.Level)
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"没有变化", func(c *Config) {}, nil},
+		{"可热加载的配置", func(c *Config) {
+			c.Log.Level = "debug"
+			c.Chat.MOTD = "hi"
+			c.Bans.Users = []string{"mallory"}
+		}, nil},
+		{"监听端口", func(c *Config) { c.Listen.Port = 9000 }, []string{"listen"}},
+		{"监听端点", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Network: "tcp", Address: ":8081", Protocol: "text"}}
+		}, []string{"listeners"}},
+		{"TLS", func(c *Config) { c.TLS.ClientCAFile = "ca.pem" }, []string{"tls"}},
+		{"超时和数据目录", func(c *Config) {
+			c.Timeouts.Read = time.Minute
+			c.Storage.DataDir = "/tmp"
+		}, []string{"timeouts", "storage"}},
+		{"机器人", func(c *Config) {
+			c.Bots = []BotConfig{{Kind: "remind", Name: "提醒"}}
+		}, []string{"bots"}},
+		{"外发 Webhook", func(c *Config) {
+			c.Webhooks = []WebhookConfig{{Name: "ci", URL: "https://example.com", Lobby: true}}
+		}, []string{"webhooks"}},
+		{"接收 Webhook", func(c *Config) { c.Incoming.Address = ":9092" }, []string{"incoming"}},
+		{"日志格式和文件", func(c *Config) { c.Log.File = "server.log" }, []string{"log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := Default()
+			tt.modify(next)
+			if got := RestartRequired(Default(), next); !slices.Equal(got, tt.want) {
+				t.Fatalf("RestartRequired() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
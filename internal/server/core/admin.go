@@ -0,0 +1,251 @@
+package core
+
+import (
+	"GoChat/pkg/protocol"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ClientInfo 是在线客户端的只读快照
+type ClientInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Groups      []string  `json:"groups"`
+}
+
+// GroupInfo 是群组的只读快照
+type GroupInfo struct {
+	Name       string   `json:"name"`
+	Persistent bool     `json:"persistent"`
+	Encrypted  bool     `json:"encrypted"`
+	Members    []string `json:"members"`
+}
+
+// Stats 是服务器运行统计
+type Stats struct {
+	StartedAt         time.Time         `json:"started_at"`
+	Uptime            string            `json:"uptime"`
+	Clients           int               `json:"clients"`
+	Groups            int               `json:"groups"`
+	MessagesForwarded map[string]uint64 `json:"messages_forwarded"`
+	BytesReceived     uint64            `json:"bytes_received"`
+	BytesSent         uint64            `json:"bytes_sent"`
+	DroppedSends      map[string]uint64 `json:"dropped_sends"`
+	DecodeErrors      uint64            `json:"decode_errors"`
+}
+
+// KickCommand 请求 Hub 断开指定用户
+type KickCommand struct {
+	Username string
+	Reason   string
+	Result   chan bool // 是否找到并断开了该用户
+}
+
+// DeleteGroupCommand 请求 Hub 解散指定群组
+type DeleteGroupCommand struct {
+	GroupName string
+	Result    chan bool // 群组是否存在
+}
+
+// PersistGroupCommand 请求 Hub 创建持久群组或取消群组的持久状态
+type PersistGroupCommand struct {
+	GroupName  string
+	Persistent bool
+	Result     chan bool // 群组是否为新创建的
+}
+
+// RejectCommand 请求 Hub 拒绝客户端的登录
+type RejectCommand struct {
+	Client *Client
+	Reason string
+}
+
+// ListClients 返回当前所有客户端的快照，按用户名排序
+func (h *Hub) ListClients() []ClientInfo {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.Clients))
+	for _, client := range h.Clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	h.groupMu.RLock()
+	defer h.groupMu.RUnlock()
+
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		info := ClientInfo{
+			ID:          client.ID,
+			Username:    client.Username,
+			RemoteAddr:  client.conn.RemoteAddr().String(),
+			ConnectedAt: client.connectedAt,
+			Groups:      []string{},
+		}
+		for name, group := range h.Groups {
+			group.mu.RLock()
+			if group.Clients[client] {
+				info.Groups = append(info.Groups, name)
+			}
+			group.mu.RUnlock()
+		}
+		slices.Sort(info.Groups)
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b ClientInfo) int {
+		if a.Username != b.Username {
+			return strings.Compare(a.Username, b.Username)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return infos
+}
+
+// ListGroups 返回当前所有群组的快照，按群组名排序
+func (h *Hub) ListGroups() []GroupInfo {
+	h.groupMu.RLock()
+	defer h.groupMu.RUnlock()
+
+	infos := make([]GroupInfo, 0, len(h.Groups))
+	for _, group := range h.Groups {
+		info := GroupInfo{Name: group.Name, Members: []string{}}
+		group.mu.RLock()
+		info.Persistent = group.Persistent
+		info.Encrypted = group.Encrypted
+		for client := range group.Clients {
+			info.Members = append(info.Members, client.Username)
+		}
+		group.mu.RUnlock()
+		slices.Sort(info.Members)
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b GroupInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// KickUser 断开指定用户的所有连接，返回是否找到该用户
+func (h *Hub) KickUser(username, reason string) bool {
+	cmd := &KickCommand{Username: username, Reason: reason, Result: make(chan bool, 1)}
+	h.Kick <- cmd
+	return <-cmd.Result
+}
+
+// DeleteGroup 解散指定群组，返回群组是否存在
+func (h *Hub) DeleteGroup(name string) bool {
+	cmd := &DeleteGroupCommand{GroupName: name, Result: make(chan bool, 1)}
+	h.RemoveGroup <- cmd
+	return <-cmd.Result
+}
+
+// SetGroupPersistent 将群组标记为持久群组，不存在时自动创建；
+// persistent 为 false 时取消标记，空群组会被销毁。返回群组是否为新创建的
+func (h *Hub) SetGroupPersistent(name string, persistent bool) bool {
+	cmd := &PersistGroupCommand{GroupName: name, Persistent: persistent, Result: make(chan bool, 1)}
+	h.Persist <- cmd
+	return <-cmd.Result
+}
+
+// Stats 返回服务器运行统计
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	clients := len(h.Clients)
+	h.mu.RUnlock()
+	h.groupMu.RLock()
+	groups := len(h.Groups)
+	h.groupMu.RUnlock()
+
+	return Stats{
+		StartedAt:         h.startedAt,
+		Uptime:            time.Since(h.startedAt).Round(time.Second).String(),
+		Clients:           clients,
+		Groups:            groups,
+		MessagesForwarded: metricMessagesForwarded.Values(),
+		BytesReceived:     metricBytesReceived.Value(),
+		BytesSent:         metricBytesSent.Value(),
+		DroppedSends:      metricDroppedSends.Values(),
+		DecodeErrors:      metricDecodeErrors.Value(),
+	}
+}
+
+// Announce 以系统身份向所有在线用户广播一条公告
+func (h *Hub) Announce(text string) {
+	h.Forward <- &protocol.Message{
+		Type:        protocol.BroadcastMessage,
+		Sender:      SystemSender,
+		Timestamp:   time.Now(),
+		TextPayload: text,
+	}
+}
+
+// handleKick 在 Hub 协程中处理管理员的踢人请求
+func (h *Hub) handleKick(cmd *KickCommand) {
+	h.mu.RLock()
+	var targets []*Client
+	for _, client := range h.Clients {
+		if client.Username == cmd.Username {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range targets {
+		client.logger().Warn("客户端被管理员断开", "reason", cmd.Reason)
+		h.kick(client, cmd.Reason)
+	}
+	cmd.Result <- len(targets) > 0
+}
+
+// handlePersistGroup 在 Hub 协程中修改群组的持久状态
+func (h *Hub) handlePersistGroup(cmd *PersistGroupCommand) {
+	h.groupMu.Lock()
+	group, ok := h.Groups[cmd.GroupName]
+	if !ok && cmd.Persistent {
+		group = NewGroup(cmd.GroupName)
+		h.Groups[cmd.GroupName] = group
+		metricGroups.Set(float64(len(h.Groups)))
+	}
+	if group != nil {
+		group.mu.Lock()
+		group.Persistent = cmd.Persistent
+		empty := len(group.Clients) == 0
+		group.mu.Unlock()
+		if !cmd.Persistent && empty {
+			delete(h.Groups, cmd.GroupName)
+			metricGroups.Set(float64(len(h.Groups)))
+		}
+	}
+	h.groupMu.Unlock()
+
+	slog.Info("群组持久状态已修改", "group", cmd.GroupName, "persistent", cmd.Persistent)
+	h.broadcastPresence()
+	cmd.Result <- !ok && cmd.Persistent
+}
+
+// handleRemoveGroup 在 Hub 协程中解散群组并通知其成员
+func (h *Hub) handleRemoveGroup(cmd *DeleteGroupCommand) {
+	h.groupMu.Lock()
+	group, ok := h.Groups[cmd.GroupName]
+	if ok {
+		delete(h.Groups, cmd.GroupName)
+		metricGroups.Set(float64(len(h.Groups)))
+	}
+	h.groupMu.Unlock()
+
+	if !ok {
+		cmd.Result <- false
+		return
+	}
+
+	group.mu.RLock()
+	for client := range group.Clients {
+		h.sendSystemMessage(client, "群组 "+group.Name+" 已被管理员解散")
+	}
+	group.mu.RUnlock()
+
+	slog.Info("群组已被管理员解散", "group", group.Name)
+	h.broadcastPresence()
+	cmd.Result <- true
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"GoChat/pkg/protocol"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Bot 是运行在服务器进程中的机器人插件。机器人像普通用户一样登录和加入群组，
+// 在在线列表中标记为机器人，不需要经过网络连接
+type Bot interface {
+	// Name 返回机器人的用户名
+	Name() string
+	// HandleMessage 处理机器人收到的聊天消息和系统通知: 世界大厅、所在群组的消息以及发给机器人的私聊。
+	// 消息在同一个协程中依次处理，耗时的操作应放到单独的协程中
+	HandleMessage(s *BotSession, message protocol.Message)
+	// HandlePresence 处理在线用户和群组的变化
+	HandlePresence(s *BotSession, tree protocol.TreePayload)
+}
+
+// BotStarter 由需要在登录后初始化的机器人实现，例如加入群组或启动定时任务。
+// Start 在单独的协程中调用，ctx 在机器人断开时取消
+type BotStarter interface {
+	Start(ctx context.Context, s *BotSession)
+}
+
+// botInbox 是机器人待发送消息的缓冲大小
+const botInbox = 64
+
+// BotSession 是机器人与 Hub 之间的连接，用于以机器人的身份发送消息
+type BotSession struct {
+	bot  Bot
+	conn *botConn
+
+	mu   sync.Mutex
+	tree protocol.TreePayload // 最近一次收到的在线状态
+}
+
+// RegisterBot 让机器人登录到 Hub，机器人断开 (例如被管理员踢出) 后不会自动重新登录
+func (h *Hub) RegisterBot(bot Bot) (*BotSession, error) {
+	name := bot.Name()
+	if name == "" {
+		return nil, errors.New("机器人的用户名不能为空")
+	}
+	s := &BotSession{bot: bot}
+	s.conn = newBotConn(name, s.deliver)
+	NewClient(h, s.conn).Start()
+	s.Send(protocol.Message{Type: protocol.LoginRequest, Sender: name})
+	return s, nil
+}
+
+// Name 返回机器人的用户名
+func (s *BotSession) Name() string {
+	return s.conn.name
+}
+
+// Connected 返回机器人是否仍然连接在 Hub 上，断开后不会自动重新登录
+func (s *BotSession) Connected() bool {
+	return s.conn.ctx.Err() == nil
+}
+
+// Presence 返回最近一次收到的在线状态
+func (s *BotSession) Presence() protocol.TreePayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree
+}
+
+// Send 以机器人的身份发送一条消息，发送者和时间由服务器填写。机器人断开后消息被丢弃
+func (s *BotSession) Send(message protocol.Message) {
+	select {
+	case s.conn.inbox <- &message:
+	case <-s.conn.done:
+		slog.Debug("机器人已断开，消息被丢弃", "bot", s.conn.name, "type", message.Type)
+	}
+}
+
+// SendBroadcast 向世界大厅发送消息
+func (s *BotSession) SendBroadcast(text string) {
+	s.Send(protocol.Message{Type: protocol.BroadcastMessage, TextPayload: text})
+}
+
+// SendPrivate 向用户发送私聊消息
+func (s *BotSession) SendPrivate(username, text string) {
+	s.Send(protocol.Message{Type: protocol.PrivateMessage, Recipient: username, TextPayload: text})
+}
+
+// SendGroup 向群组发送消息
+func (s *BotSession) SendGroup(group, text string) {
+	s.Send(protocol.Message{Type: protocol.GroupMessage, GroupName: group, TextPayload: text})
+}
+
+// Reply 在收到消息的会话中回复: 私聊回复给发送者，群聊回复到群组，其它消息回复到世界大厅
+func (s *BotSession) Reply(to protocol.Message, text string) {
+	switch to.Type {
+	case protocol.PrivateMessage, protocol.PrivateFileMessage:
+		s.SendPrivate(to.Sender, text)
+	case protocol.GroupMessage, protocol.GroupFileMessage:
+		s.SendGroup(to.GroupName, text)
+	default:
+		s.SendBroadcast(text)
+	}
+}
+
+// JoinGroup 加入群组，不存在时创建
+func (s *BotSession) JoinGroup(group string) {
+	s.Send(protocol.Message{Type: protocol.JoinGroupRequest, GroupName: group})
+}
+
+// LeaveGroup 离开群组
+func (s *BotSession) LeaveGroup(group string) {
+	s.Send(protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: group})
+}
+
+// deliver 处理 Hub 发给机器人的消息，在机器人客户端的写协程中调用
+func (s *BotSession) deliver(message protocol.Message) {
+	switch message.Type {
+	case protocol.LoginResponse:
+		if message.TextPayload != "" {
+			slog.Warn("机器人登录被拒绝", "bot", s.conn.name, "reason", message.TextPayload)
+			return
+		}
+		slog.Info("机器人已登录", "bot", s.conn.name)
+		if starter, ok := s.bot.(BotStarter); ok {
+			go starter.Start(s.conn.ctx, s)
+		}
+	case protocol.TreeUpdate:
+		s.mu.Lock()
+		s.tree = message.TreePayload
+		s.mu.Unlock()
+		s.bot.HandlePresence(s, message.TreePayload)
+	case protocol.BroadcastMessage, protocol.PrivateMessage, protocol.GroupMessage,
+		protocol.PrivateFileMessage, protocol.GroupFileMessage, protocol.SystemMessage:
+		// 机器人自己发出的消息会被转发回来，不交给机器人处理
+		if message.Sender == s.conn.name && message.Type != protocol.SystemMessage {
+			return
+		}
+		s.bot.HandleMessage(s, message)
+	}
+}
+
+// botConn 是机器人使用的内存连接: ReadMessage 返回机器人发送的消息，
+// WriteMessage 将 Hub 的消息交给机器人处理
+type botConn struct {
+	name    string
+	inbox   chan *protocol.Message
+	deliver func(protocol.Message)
+
+	ctx    context.Context // 连接关闭时取消
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+func newBotConn(name string, deliver func(protocol.Message)) *botConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &botConn{
+		name:    name,
+		inbox:   make(chan *protocol.Message, botInbox),
+		deliver: deliver,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    ctx.Done(),
+	}
+}
+
+func (c *botConn) ReadMessage() (*protocol.Message, error) {
+	select {
+	case message := <-c.inbox:
+		return message, nil
+	case <-c.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (c *botConn) WriteMessage(message protocol.Message) error {
+	select {
+	case <-c.done:
+		return net.ErrClosed
+	default:
+	}
+	c.deliver(message)
+	return nil
+}
+
+// 机器人没有网络连接，读写超时不适用
+func (c *botConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *botConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *botConn) RemoteAddr() net.Addr {
+	return botAddr(c.name)
+}
+
+func (c *botConn) Close() error {
+	c.cancel()
+	return nil
+}
+
+// AuthenticatedUser 使机器人以自己的名字登录，不经过账号认证
+func (c *botConn) AuthenticatedUser() (string, bool) {
+	return c.name, true
+}
+
+// botAddr 是机器人连接的地址，显示为 bot:<名称>
+type botAddr string
+
+func (a botAddr) Network() string { return "bot" }
+func (a botAddr) String() string  { return "bot:" + string(a) }
+
+// isBot 返回客户端是否是服务器内置的机器人
+func (c *Client) isBot() bool {
+	_, ok := c.conn.(*botConn)
+	return ok
+}
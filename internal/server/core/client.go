@@ -1,10 +1,10 @@
 package core
 
 import (
+	"GoChat/pkg/e2e"
 	"GoChat/pkg/protocol"
-	"bufio"
-	"fmt"
-	"net"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,23 +12,33 @@ import (
 
 // Client 表示一个连接的客户端
 type Client struct {
-	ID       string                // 客户端唯一标识
-	Username string                // 客户端用户名
-	Send     chan protocol.Message // 用于向客户端发送消息的通道
-	hub      *Hub                  // 指向中心枢纽的指针
-	conn     net.Conn              // TCP 连接
+	ID          string                // 客户端唯一标识
+	Username    string                // 客户端用户名
+	Send        chan protocol.Message // 用于向客户端发送消息的通道
+	hub         *Hub                  // 指向中心枢纽的指针
+	conn        Conn                  // 客户端连接
+	connectedAt time.Time             // 建立连接的时间
+	closed      bool                  // 发送通道是否已关闭，只在 Hub 协程中访问
+	publicKey   string                // 端到端加密公钥，只在 Hub 协程中访问
+	limiter     rateLimiter           // 聊天消息频率限制，只在读协程中访问
 }
 
 // NewClient 创建一个新的 Client 实例
-func NewClient(hub *Hub, conn net.Conn) *Client {
+func NewClient(hub *Hub, conn Conn) *Client {
 	return &Client{
-		ID:   uuid.New().String(), // 生成唯一ID
-		hub:  hub,
-		conn: conn,
-		Send: make(chan protocol.Message, 256), // 带缓冲的通道
+		ID:          uuid.New().String(), // 生成唯一ID
+		hub:         hub,
+		conn:        conn,
+		connectedAt: time.Now(),
+		Send:        make(chan protocol.Message, hub.opts.SendBuffer), // 带缓冲的通道
 	}
 }
 
+// logger 返回附带连接信息的日志记录器
+func (c *Client) logger() *slog.Logger {
+	return slog.With("client_id", c.ID, "username", c.Username, "remote_addr", c.conn.RemoteAddr().String())
+}
+
 // ReadPump 负责从客户端读取数据并智能地分发到Hub的不同通道
 func (c *Client) ReadPump() {
 	defer func() {
@@ -36,14 +46,16 @@ func (c *Client) ReadPump() {
 		c.conn.Close()
 	}()
 
-	reader := bufio.NewReader(c.conn)
 	isRegistered := false
 
 	for {
-		c.conn.SetReadDeadline(time.Now().Add(120 * time.Second))
-		message, err := protocol.DecodeMessage(reader)
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.opts.ReadTimeout))
+		message, err := c.conn.ReadMessage()
 		if err != nil {
-			fmt.Printf("读取客户端 %s (%s) 数据失败: %v\n", c.Username, c.ID, err)
+			if isDecodeError(err) {
+				metricDecodeErrors.Inc()
+			}
+			c.logger().Info("读取客户端数据失败", "error", err)
 			break
 		}
 
@@ -56,21 +68,51 @@ func (c *Client) ReadPump() {
 		switch message.Type {
 
 		case protocol.LoginRequest:
-			if !isRegistered && message.Sender != "" {
-				fmt.Println("Login")
+			if isRegistered {
+				break
+			}
+			// 使用客户端证书或 SSH 公钥认证时，用户名由传输层确定，忽略登录请求中的用户名和密码
+			if name, ok := c.verifiedUsername(); ok {
+				c.logger().Debug("收到登录请求，使用传输层认证的用户名", "login_name", message.Sender, "verified_name", name)
+				if name == "" {
+					c.hub.Reject <- &RejectCommand{Client: c, Reason: "客户端证书中没有用户名 (CN)"}
+					break
+				}
+				c.Username = name
+				c.hub.Register <- c
+				isRegistered = true
+				break
+			}
+			if message.Sender != "" {
+				c.logger().Debug("收到登录请求", "login_name", message.Sender)
+				if auth := c.hub.opts.Auth; auth != nil {
+					if err := auth.Authenticate(message.Sender, message.TextPayload); err != nil {
+						c.logger().Warn("登录认证失败", "login_name", message.Sender, "error", err)
+						c.hub.Reject <- &RejectCommand{Client: c, Reason: err.Error()}
+						break
+					}
+				}
 				c.Username = message.Sender
 				c.hub.Register <- c
 				isRegistered = true
 			}
 
 		case protocol.CreateGroupRequest:
-			fmt.Println("CreateGroup")
+			c.logger().Debug("收到创建群组请求", "group", message.TextPayload)
 			cmd := &GroupCommand{
 				Client:    c,
 				GroupName: message.TextPayload,
 			}
 			c.hub.JoinGroup <- cmd
 
+		case protocol.CreateEncryptedGroupRequest:
+			c.logger().Debug("收到创建加密群组请求", "group", message.TextPayload)
+			c.hub.JoinGroup <- &GroupCommand{
+				Client:    c,
+				GroupName: message.TextPayload,
+				Encrypted: true,
+			}
+
 		case protocol.JoinGroupRequest:
 			cmd := &GroupCommand{
 				Client:    c,
@@ -85,18 +127,77 @@ func (c *Client) ReadPump() {
 			}
 			c.hub.LeaveGroup <- cmd
 
+		case protocol.GroupKeyMessage:
+			// 密钥分发在成员变化时集中发送，不计入频率限制
+			if !isRegistered {
+				metricDroppedSends.Inc(dropNotLoggedIn)
+				break
+			}
+			c.hub.Forward <- message
+
+		case protocol.PublishKeyRequest:
+			if !isRegistered {
+				metricDroppedSends.Inc(dropNotLoggedIn)
+				break
+			}
+			if _, err := e2e.ParsePublicKey(message.TextPayload); err != nil {
+				c.logger().Warn("客户端发布的公钥无效", "error", err)
+				break
+			}
+			c.hub.PublishKey <- &KeyCommand{Client: c, Key: message.TextPayload}
+
+		case protocol.HistoryRequest:
+			if !isRegistered {
+				metricDroppedSends.Inc(dropNotLoggedIn)
+				break
+			}
+			limit, _ := strconv.Atoi(message.TextPayload)
+			c.hub.History <- &HistoryCommand{Client: c, GroupName: message.GroupName, Limit: limit}
+
 		case protocol.BroadcastMessage, protocol.PrivateMessage, protocol.GroupMessage,
 			protocol.PrivateFileMessage, protocol.GroupFileMessage:
-			if isRegistered {
+			if !isRegistered {
+				metricDroppedSends.Inc(dropNotLoggedIn)
+				c.logger().Warn("客户端在未登录时尝试发送聊天消息")
+				break
+			}
+			// 服务器内置的机器人不受频率限制
+			settings := c.hub.Settings()
+			if c.isBot() || c.limiter.allow(settings.RateLimit, settings.RateBurst, message.Timestamp) {
 				c.hub.Forward <- message
 			} else {
-				fmt.Printf("警告: 客户端 %s 在未登录时尝试发送聊天消息。\n", c.ID)
+				metricDroppedSends.Inc(dropRateLimited)
+				c.logger().Warn("客户端发送消息过于频繁，消息被丢弃")
+				c.hub.Forward <- &protocol.Message{
+					Type:        protocol.SystemMessage,
+					Recipient:   c.Username,
+					TextPayload: "发送过于频繁，消息未送达",
+				}
 			}
 
 		default:
-			fmt.Printf("警告: 收到未知的消息类型: '%s'\n", message.Type)
+			c.logger().Warn("收到未知的消息类型", "type", message.Type)
+		}
+	}
+}
+
+// verifiedUsername 返回传输层已经认证的用户名: AuthenticatedConn 提供的用户名，
+// 或经过校验的客户端证书中的 Subject CN (双向 TLS)。其它情况下 ok 为 false
+func (c *Client) verifiedUsername() (name string, ok bool) {
+	if authConn, isAuth := c.conn.(AuthenticatedConn); isAuth {
+		if name, ok := authConn.AuthenticatedUser(); ok {
+			return name, true
 		}
 	}
+	tlsConn, isTLS := c.conn.(TLSConn)
+	if !isTLS {
+		return "", false
+	}
+	state, isTLS := tlsConn.TLSState()
+	if !isTLS || len(state.VerifiedChains) == 0 {
+		return "", false
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName, true
 }
 
 // WritePump 负责将 Hub 的消息发送给客户端
@@ -107,20 +208,22 @@ func (c *Client) WritePump() {
 
 	for message := range c.Send {
 		// 设置写入超时
-		c.conn.SetWriteDeadline(time.Now().Add(60 * time.Second))
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteTimeout))
 
-		frame, err := protocol.EncodeMessage(message)
-		if err != nil {
-			fmt.Printf("编码消息失败: %v\n", err)
-			continue
-		}
-		_, err = c.conn.Write(frame)
-		if err != nil {
-			fmt.Printf("发送消息失败: %v\n", err)
+		if err := c.conn.WriteMessage(message); err != nil {
+			c.logger().Info("发送消息失败", "error", err)
 			return
 		}
 	}
-	fmt.Println("发送通道已关闭，断开客户端连接")
+	c.logger().Debug("发送通道已关闭，断开客户端连接")
+}
+
+// closeSend 关闭发送通道，使 WritePump 退出，只能在 Hub 协程中调用
+func (c *Client) closeSend() {
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
 }
 
 // Start 启动客户端的读写协程
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+// plainConn 是没有 TLS 也没有传输层认证的连接
+type plainConn struct {
+	Conn
+}
+
+// tlsConn 是承载在 TLS 之上的连接
+type tlsConn struct {
+	Conn
+	state tls.ConnectionState
+}
+
+func (c tlsConn) TLSState() (tls.ConnectionState, bool) {
+	return c.state, true
+}
+
+// authConn 是传输层已经认证了用户的连接
+type authConn struct {
+	tlsConn
+	user string
+}
+
+func (c authConn) AuthenticatedUser() (string, bool) {
+	return c.user, c.user != ""
+}
+
+// verifiedState 返回客户端证书已通过校验的 TLS 状态
+func verifiedState(cn string) tls.ConnectionState {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	ca := &x509.Certificate{Subject: pkix.Name{CommonName: "GoChat CA"}}
+	return tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf},
+		VerifiedChains:   [][]*x509.Certificate{{leaf, ca}},
+	}
+}
+
+func TestVerifiedUsername(t *testing.T) {
+	unverified := tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "alice"}}}}
+	tests := []struct {
+		name     string
+		conn     Conn
+		wantName string
+		wantOK   bool
+	}{
+		{"明文连接", plainConn{}, "", false},
+		{"TLS 没有客户端证书", tlsConn{}, "", false},
+		{"客户端证书未经校验", tlsConn{state: unverified}, "", false},
+		{"证书 CN 作为用户名", tlsConn{state: verifiedState("alice")}, "alice", true},
+		{"证书没有 CN", tlsConn{state: verifiedState("")}, "", true},
+		{"传输层认证的用户名优先", authConn{tlsConn{state: verifiedState("alice")}, "bob"}, "bob", true},
+		{"传输层没有认证时使用证书", authConn{tlsConn{state: verifiedState("alice")}, ""}, "alice", true},
+		{"传输层没有认证也没有证书", authConn{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{conn: tt.conn}
+			name, ok := c.verifiedUsername()
+			if name != tt.wantName || ok != tt.wantOK {
+				t.Fatalf("verifiedUsername() = (%q, %v), 期望 (%q, %v)", name, ok, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"GoChat/pkg/protocol"
+	"bufio"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// Conn 是客户端连接的抽象，由各传输方式 (TCP、WebSocket 等) 负责消息的分帧
+type Conn interface {
+	ReadMessage() (*protocol.Message, error)     // 读取一条完整的消息
+	WriteMessage(message protocol.Message) error // 发送一条消息
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	RemoteAddr() net.Addr
+	Close() error
+}
+
+// TLSConn 由承载在 TLS 之上的连接实现，用于读取客户端证书
+type TLSConn interface {
+	TLSState() (tls.ConnectionState, bool)
+}
+
+// AuthenticatedConn 由传输层已经认证了用户的连接实现 (如 SSH 公钥认证)，
+// 登录时直接使用其用户名，不再校验密码
+type AuthenticatedConn interface {
+	AuthenticatedUser() (name string, ok bool)
+}
+
+// streamConn 在字节流连接 (TCP、TLS 等) 上使用长度前缀的 JSON 数据帧
+type streamConn struct {
+	net.Conn
+	reader       *bufio.Reader
+	maxFrameSize int
+}
+
+// NewStreamConn 用长度前缀的数据帧包装字节流连接，maxFrameSize 为单帧的最大字节数
+func NewStreamConn(conn net.Conn, maxFrameSize int) Conn {
+	return &streamConn{
+		Conn:         conn,
+		reader:       bufio.NewReader(countingReader{conn}),
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+func (c *streamConn) ReadMessage() (*protocol.Message, error) {
+	return protocol.DecodeMessageLimit(c.reader, c.maxFrameSize)
+}
+
+func (c *streamConn) WriteMessage(message protocol.Message) error {
+	frame, err := protocol.EncodeMessage(message)
+	if err != nil {
+		return err
+	}
+	n, err := c.Conn.Write(frame)
+	metricBytesSent.Add(uint64(n))
+	return err
+}
+
+func (c *streamConn) TLSState() (tls.ConnectionState, bool) {
+	tlsConn, ok := c.Conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConn.ConnectionState(), true
+}
+
+// CountReceived 和 CountSent 供 core 之外实现的 Conn 统计收发的字节数
+func CountReceived(n int) { metricBytesReceived.Add(uint64(n)) }
+func CountSent(n int)     { metricBytesSent.Add(uint64(n)) }
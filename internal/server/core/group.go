@@ -5,7 +5,12 @@ import "sync"
 type Group struct {
 	Name    string           // 群组名称
 	Clients map[*Client]bool // 成员列表
-	mu      sync.RWMutex
+	// Persistent 为 true 的群组在成员为空时不会被销毁
+	Persistent bool
+	// Encrypted 为 true 的群组启用了端到端加密，服务器拒绝转发其中的明文消息
+	Encrypted bool
+	mu        sync.RWMutex
+	history   *history // 最近的聊天记录，只在 Hub 协程中访问
 }
 
 func NewGroup(name string) *Group {
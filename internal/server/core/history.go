@@ -0,0 +1,100 @@
+package core
+
+import "GoChat/pkg/protocol"
+
+// HistoryCommand 是客户端查询最近聊天记录的请求
+type HistoryCommand struct {
+	Client    *Client
+	GroupName string // 为空时查询世界大厅
+	Limit     int    // 最多返回的条数，0 表示全部
+}
+
+// history 以环形缓冲保存一个会话最近的聊天记录，只在 Hub 协程中访问
+type history struct {
+	messages []protocol.Message
+	next     int // 下一条消息写入的位置
+	full     bool
+}
+
+func newHistory(size int) *history {
+	return &history{messages: make([]protocol.Message, size)}
+}
+
+// add 记录一条消息，缓冲已满时覆盖最早的一条
+func (h *history) add(message protocol.Message) {
+	if len(h.messages) == 0 {
+		return
+	}
+	h.messages[h.next] = message
+	h.next = (h.next + 1) % len(h.messages)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list 按时间顺序返回最近的 limit 条消息，limit 不大于 0 时返回全部
+func (h *history) list(limit int) []protocol.Message {
+	var all []protocol.Message
+	if h.full {
+		all = append(all, h.messages[h.next:]...)
+	}
+	all = append(all, h.messages[:h.next]...)
+	if limit > 0 && len(all) > limit {
+		all = all[len(all)-limit:]
+	}
+	return all
+}
+
+// record 将转发的文字消息记入世界大厅或群组的聊天记录，文件不记录
+func (h *Hub) record(group *Group, message *protocol.Message) {
+	if h.opts.History <= 0 {
+		return
+	}
+	if group == nil {
+		if h.lobby == nil {
+			h.lobby = newHistory(h.opts.History)
+		}
+		h.lobby.add(*message)
+		return
+	}
+	if group.history == nil {
+		group.history = newHistory(h.opts.History)
+	}
+	group.history.add(*message)
+}
+
+// handleHistory 在 Hub 协程中返回最近的聊天记录，群组的记录只对成员开放
+func (h *Hub) handleHistory(cmd *HistoryCommand) {
+	if cmd.Client.closed {
+		return
+	}
+	response := protocol.Message{
+		Type:      protocol.HistoryResponse,
+		Recipient: cmd.Client.Username,
+		GroupName: cmd.GroupName,
+	}
+	var records *history
+	if cmd.GroupName == "" {
+		records = h.lobby
+	} else {
+		h.groupMu.RLock()
+		group, ok := h.Groups[cmd.GroupName]
+		h.groupMu.RUnlock()
+		if !ok {
+			response.TextPayload = "群组不存在"
+		} else {
+			group.mu.RLock()
+			member := group.Clients[cmd.Client]
+			group.mu.RUnlock()
+			if member {
+				records = group.history
+			} else {
+				response.TextPayload = "只有群组成员可以查看聊天记录"
+			}
+		}
+	}
+	if records != nil {
+		response.History = records.list(cmd.Limit)
+	}
+	h.send(cmd.Client, response)
+}
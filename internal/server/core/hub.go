@@ -1,62 +1,159 @@
 package core
 
 import (
+	"GoChat/internal/logging"
 	"GoChat/pkg/protocol"
-	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// SystemSender 是服务器发出的系统消息使用的发送者名称
+const SystemSender = "系统"
+
 type GroupCommand struct {
 	Client    *Client
 	GroupName string
+	Encrypted bool // 群组不存在时，新建的群组是否启用端到端加密
+}
+
+// KeyCommand 是客户端发布端到端加密公钥的请求
+type KeyCommand struct {
+	Client *Client
+	Key    string
 }
 
 type Hub struct {
-	Clients    map[string]*Client
-	Groups     map[string]*Group
-	Register   chan *Client
-	Unregister chan *Client
-	JoinGroup  chan *GroupCommand
-	LeaveGroup chan *GroupCommand
-	Forward    chan *protocol.Message
-	mu         sync.RWMutex
-	groupMu    sync.RWMutex
+	Clients     map[string]*Client
+	Groups      map[string]*Group
+	Register    chan *Client
+	Unregister  chan *Client
+	JoinGroup   chan *GroupCommand
+	LeaveGroup  chan *GroupCommand
+	Forward     chan *protocol.Message
+	Reload      chan *Settings
+	Kick        chan *KickCommand
+	RemoveGroup chan *DeleteGroupCommand
+	Persist     chan *PersistGroupCommand
+	Reject      chan *RejectCommand
+	PublishKey  chan *KeyCommand
+	History     chan *HistoryCommand
+	lobby       *history     // 世界大厅的聊天记录，只在 Hub 协程中访问
+	middleware  []Middleware // 通过 Use 添加的中间件
+	forward     ForwardFunc  // 由中间件和 route 组成的转发流程
+	handled     bool         // 当前消息是否已投递或已按具体原因丢弃，用于统计被中间件丢弃的消息
+	startedAt   time.Time
+	opts        Options
+	settings    atomic.Pointer[Settings]
+	mu          sync.RWMutex
+	groupMu     sync.RWMutex
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		Clients:    make(map[string]*Client),
-		Groups:     make(map[string]*Group),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		JoinGroup:  make(chan *GroupCommand),
-		LeaveGroup: make(chan *GroupCommand),
-		Forward:    make(chan *protocol.Message),
+func NewHub(opts Options, settings Settings) *Hub {
+	h := &Hub{
+		opts:        opts,
+		Clients:     make(map[string]*Client),
+		Groups:      make(map[string]*Group),
+		Register:    make(chan *Client),
+		Unregister:  make(chan *Client),
+		JoinGroup:   make(chan *GroupCommand),
+		LeaveGroup:  make(chan *GroupCommand),
+		Forward:     make(chan *protocol.Message),
+		Reload:      make(chan *Settings),
+		Kick:        make(chan *KickCommand),
+		RemoveGroup: make(chan *DeleteGroupCommand),
+		Persist:     make(chan *PersistGroupCommand),
+		Reject:      make(chan *RejectCommand),
+		PublishKey:  make(chan *KeyCommand),
+		History:     make(chan *HistoryCommand),
+		startedAt:   time.Now(),
 	}
+	h.settings.Store(&settings)
+	h.Use()
+	return h
+}
+
+// Settings 返回当前生效的可热加载设置，返回值不可修改
+func (h *Hub) Settings() *Settings {
+	return h.settings.Load()
+}
+
+// Options 返回 Hub 的运行参数
+func (h *Hub) Options() Options {
+	return h.opts
 }
 
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
-			h.handleRegister(client)
+			h.timed(func() { h.handleRegister(client) })
 		case client := <-h.Unregister:
-			h.handleUnregister(client)
+			h.timed(func() { h.handleUnregister(client) })
 		case cmd := <-h.JoinGroup:
-			h.handleJoinGroup(cmd.Client, cmd.GroupName)
+			h.timed(func() { h.handleJoinGroup(cmd.Client, cmd.GroupName, cmd.Encrypted) })
 		case cmd := <-h.LeaveGroup:
-			h.handleLeaveGroup(cmd.Client, cmd.GroupName)
+			h.timed(func() { h.handleLeaveGroup(cmd.Client, cmd.GroupName) })
 		case message := <-h.Forward:
-			h.handleForwardMessage(message)
+			h.timed(func() { h.handleForwardMessage(message) })
+		case settings := <-h.Reload:
+			h.timed(func() { h.handleReload(settings) })
+		case cmd := <-h.Kick:
+			h.timed(func() { h.handleKick(cmd) })
+		case cmd := <-h.RemoveGroup:
+			h.timed(func() { h.handleRemoveGroup(cmd) })
+		case cmd := <-h.Persist:
+			h.timed(func() { h.handlePersistGroup(cmd) })
+		case cmd := <-h.Reject:
+			h.timed(func() { h.rejectLogin(cmd.Client, cmd.Reason) })
+		case cmd := <-h.PublishKey:
+			h.timed(func() { h.handlePublishKey(cmd) })
+		case cmd := <-h.History:
+			h.timed(func() { h.handleHistory(cmd) })
 		}
 	}
 }
 
+// timed 执行一次事件处理并记录耗时
+func (h *Hub) timed(handle func()) {
+	start := time.Now()
+	handle()
+	metricHubLoopDuration.Observe(time.Since(start).Seconds())
+}
+
 func (h *Hub) handleRegister(client *Client) {
+	if client.closed {
+		return
+	}
+	if reason := h.Settings().bannedReason(client); reason != "" {
+		client.logger().Warn("拒绝被封禁的客户端", "reason", reason)
+		if client.Username != "" {
+			h.rejectLogin(client, reason)
+		} else {
+			h.kick(client, reason)
+		}
+		return
+	}
+
 	h.mu.Lock()
+	if _, ok := h.Clients[client.ID]; !ok && h.opts.MaxClients > 0 && len(h.Clients) >= h.opts.MaxClients {
+		h.mu.Unlock()
+		client.logger().Warn("连接数已达上限，拒绝客户端", "max_clients", h.opts.MaxClients)
+		client.closeSend()
+		client.conn.Close()
+		return
+	}
 	h.Clients[client.ID] = client
+	metricClients.Set(float64(len(h.Clients)))
 	h.mu.Unlock()
-	fmt.Printf("客户端已注册: %s (Username: %s)\n", client.ID, client.Username)
+	client.logger().Info("客户端已注册")
+	if client.Username != "" {
+		h.send(client, protocol.Message{Type: protocol.LoginResponse, Recipient: client.Username, Timestamp: time.Now()})
+		if motd := h.Settings().MOTD; motd != "" {
+			h.sendSystemMessage(client, motd)
+		}
+	}
 	h.broadcastPresence()
 }
 
@@ -65,25 +162,28 @@ func (h *Hub) handleUnregister(client *Client) {
 	h.mu.Lock()
 	if _, ok := h.Clients[client.ID]; ok {
 		delete(h.Clients, client.ID)
-		close(client.Send)
-		fmt.Printf("客户端已注销: %s (Username: %s)\n", client.ID, client.Username)
+		metricClients.Set(float64(len(h.Clients)))
+		client.closeSend()
+		client.logger().Info("客户端已注销")
 	}
 	h.mu.Unlock()
 	h.broadcastPresence()
 }
 
-func (h *Hub) handleJoinGroup(client *Client, groupName string) {
+func (h *Hub) handleJoinGroup(client *Client, groupName string, encrypted bool) {
 	h.groupMu.Lock()
 	group, ok := h.Groups[groupName]
 	if !ok {
 		group = NewGroup(groupName)
+		group.Encrypted = encrypted
 		h.Groups[groupName] = group
-		fmt.Printf("新群组被自动创建: %s\n", groupName)
+		metricGroups.Set(float64(len(h.Groups)))
+		slog.Info("新群组被自动创建", "group", groupName, "encrypted", encrypted)
 	}
 	h.groupMu.Unlock()
 
 	group.AddClient(client)
-	fmt.Printf("客户端 %s 加入了群组 %s\n", client.Username, groupName)
+	client.logger().Info("客户端加入了群组", "group", groupName)
 	h.broadcastPresence()
 }
 
@@ -92,41 +192,146 @@ func (h *Hub) handleLeaveGroup(client *Client, groupName string) {
 	group, ok := h.Groups[groupName]
 	if ok {
 		group.RemoveClient(client)
-		fmt.Printf("客户端 %s 离开了群组 %s\n", client.Username, groupName)
-		if len(group.Clients) == 0 {
+		client.logger().Info("客户端离开了群组", "group", groupName)
+		if len(group.Clients) == 0 && !group.Persistent {
 			delete(h.Groups, groupName)
-			fmt.Printf("群组 %s 因成员为空已被销毁。\n", groupName)
+			metricGroups.Set(float64(len(h.Groups)))
+			slog.Info("群组因成员为空已被销毁", "group", groupName)
 		}
 	}
 	h.groupMu.Unlock()
 	h.broadcastPresence()
 }
 
+// handleReload 应用新的设置，并断开新被封禁的在线用户
+func (h *Hub) handleReload(settings *Settings) {
+	h.settings.Store(settings)
+
+	h.mu.RLock()
+	var banned []*Client
+	for _, client := range h.Clients {
+		if settings.bannedReason(client) != "" {
+			banned = append(banned, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range banned {
+		client.logger().Warn("客户端已被封禁，断开连接")
+		h.kick(client, settings.bannedReason(client))
+	}
+}
+
+// kick 向客户端发送一条通知后将其断开
+func (h *Hub) kick(client *Client, reason string) {
+	h.sendSystemMessage(client, "您已被服务器断开: "+reason)
+	h.handleUnregister(client)
+	client.closeSend()
+}
+
+// rejectLogin 告知客户端登录失败的原因并断开连接
+func (h *Hub) rejectLogin(client *Client, reason string) {
+	if client.closed {
+		return
+	}
+	h.send(client, protocol.Message{
+		Type:        protocol.LoginResponse,
+		Recipient:   client.Username,
+		Timestamp:   time.Now(),
+		TextPayload: reason,
+	})
+	h.handleUnregister(client)
+	client.closeSend()
+}
+
+// sendSystemMessage 向单个客户端发送系统通知
+func (h *Hub) sendSystemMessage(client *Client, text string) {
+	h.send(client, protocol.Message{
+		Type:        protocol.SystemMessage,
+		Sender:      SystemSender,
+		Recipient:   client.Username,
+		Timestamp:   time.Now(),
+		TextPayload: text,
+	})
+}
+
+// send 以非阻塞方式向单个客户端发送消息，通道已满时丢弃
+func (h *Hub) send(client *Client, message protocol.Message) {
+	select {
+	case client.Send <- message:
+	default:
+		metricDroppedSends.Inc(dropBufferFull)
+		client.logger().Warn("客户端的消息通道已满，消息被丢弃", "type", message.Type)
+	}
+}
+
+// handleForwardMessage 让消息经过中间件后投递
 func (h *Hub) handleForwardMessage(message *protocol.Message) {
+	metricMessagesForwarded.Inc(message.Type)
+	h.handled = false
+	h.forward(message)
+	if !h.handled {
+		metricDroppedSends.Inc(dropMiddleware)
+	}
+}
+
+// route 按消息类型投递消息，是转发流程的最后一步
+func (h *Hub) route(message *protocol.Message) {
+	h.handled = true
 	switch message.Type {
 	case protocol.GroupMessage, protocol.GroupFileMessage:
 		h.sendGroupMessage(message)
 	case protocol.PrivateMessage, protocol.PrivateFileMessage:
 		h.sendPrivateMessage(message)
+	case protocol.GroupKeyMessage:
+		h.sendGroupKey(message)
 	case protocol.BroadcastMessage:
 		h.broadcastMessage(message)
+	case protocol.SystemMessage:
+		h.mu.RLock()
+		if client, ok := h.findClientByUsername(message.Recipient); ok {
+			h.sendSystemMessage(client, message.TextPayload)
+		}
+		h.mu.RUnlock()
 	}
 }
 
+// handlePublishKey 记录客户端的公钥，并通过状态更新告知其他用户
+func (h *Hub) handlePublishKey(cmd *KeyCommand) {
+	if cmd.Client.closed || cmd.Client.publicKey == cmd.Key {
+		return
+	}
+	cmd.Client.publicKey = cmd.Key
+	cmd.Client.logger().Info("客户端发布了加密公钥")
+	h.broadcastPresence()
+}
+
 // broadcastPresence 是统一的、唯一的“状态广播”函数
 func (h *Hub) broadcastPresence() {
 	h.mu.RLock()
 	allClients := make([]*Client, 0, len(h.Clients))
 	users := make([]string, 0, len(h.Clients))
+	keys := make(map[string]string)
+	var bots []string
 	for _, client := range h.Clients {
 		allClients = append(allClients, client)
 		users = append(users, client.Username)
+		if client.isBot() {
+			bots = append(bots, client.Username)
+		}
+		if client.publicKey != "" {
+			keys[client.Username] = client.publicKey
+		}
 	}
 	h.mu.RUnlock()
 
 	h.groupMu.RLock()
 	groups := make(map[string][]string)
+	var encryptedGroups []string
 	for _, g := range h.Groups {
+		if g.Encrypted {
+			encryptedGroups = append(encryptedGroups, g.Name)
+		}
 		members := []string{}
 		g.mu.RLock()
 		for c := range g.Clients {
@@ -137,14 +342,15 @@ func (h *Hub) broadcastPresence() {
 	}
 	h.groupMu.RUnlock()
 
-	treeData := protocol.TreePayload{Users: users, Groups: groups}
+	treeData := protocol.TreePayload{Users: users, Groups: groups, Keys: keys, EncryptedGroups: encryptedGroups, Bots: bots}
 	message := protocol.Message{Type: protocol.TreeUpdate, TreePayload: treeData}
 
 	for _, client := range allClients {
 		select {
 		case client.Send <- message:
 		default:
-			fmt.Printf("警告: 客户端 %s 的消息通道已满，状态更新消息被丢弃。\n", client.Username)
+			metricDroppedSends.Inc(dropBufferFull)
+			client.logger().Warn("客户端的消息通道已满，状态更新消息被丢弃")
 		}
 	}
 }
@@ -168,6 +374,7 @@ func (h *Hub) sendGroupMessage(message *protocol.Message) {
 	h.groupMu.RLock()
 	defer h.groupMu.RUnlock()
 
+	// 群组是否存在、能否接收明文已经由 validate 检查，中间件改写了目标群组时这里仍可能找不到
 	if group, ok := h.Groups[message.GroupName]; ok {
 		group.mu.RLock()
 		defer group.mu.RUnlock()
@@ -175,13 +382,15 @@ func (h *Hub) sendGroupMessage(message *protocol.Message) {
 		for client := range group.Clients {
 			select {
 			case client.Send <- *message:
-				fmt.Printf("消息已发送到群组 %s 的客户端 %s: %s\n", message.GroupName, client.Username, message.TextPayload)
+				client.logger().Debug("消息已发送到群组成员", "group", message.GroupName, logging.Body(message.TextPayload))
 			default:
-				fmt.Printf("警告: 群组 %s 的客户端 %s 的消息通道已满，消息被丢弃。\n", message.GroupName, client.Username)
+				metricDroppedSends.Inc(dropBufferFull)
+				client.logger().Warn("群组成员的消息通道已满，消息被丢弃", "group", message.GroupName)
 			}
 		}
 	} else {
-		fmt.Printf("警告: 群组 %s 不存在，无法发送消息。\n", message.GroupName)
+		metricDroppedSends.Inc(dropUnknownGroup)
+		slog.Warn("群组不存在，无法发送消息", "group", message.GroupName, "sender", message.Sender)
 	}
 }
 
@@ -193,18 +402,58 @@ func (h *Hub) sendPrivateMessage(message *protocol.Message) {
 		select {
 		case recipient.Send <- *message:
 		default:
-			fmt.Printf("私聊接收方 %s 的消息通道已满。\n", recipient.Username)
+			metricDroppedSends.Inc(dropBufferFull)
+			recipient.logger().Warn("私聊接收方的消息通道已满，消息被丢弃")
 		}
 	}
 	if sender, ok := h.findClientByUsername(message.Sender); ok {
 		select {
 		case sender.Send <- *message:
 		default:
-			fmt.Printf("私聊发送方 %s 的消息通道已满。\n", sender.Username)
+			metricDroppedSends.Inc(dropBufferFull)
+			sender.logger().Warn("私聊发送方的消息通道已满，消息被丢弃")
 		}
 	}
 }
 
+// sendGroupKey 将加密群组的密钥转发给接收者，只允许群组成员之间分发
+func (h *Hub) sendGroupKey(message *protocol.Message) {
+	h.groupMu.RLock()
+	group, ok := h.Groups[message.GroupName]
+	h.groupMu.RUnlock()
+	if !ok || !group.Encrypted || message.Encrypted == nil {
+		metricDroppedSends.Inc(dropUnknownGroup)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	sender, senderOK := h.findClientByUsername(message.Sender)
+	recipient, recipientOK := h.findClientByUsername(message.Recipient)
+	if !senderOK || !recipientOK {
+		return
+	}
+	group.mu.RLock()
+	members := group.Clients[sender] && group.Clients[recipient]
+	group.mu.RUnlock()
+	if !members {
+		sender.logger().Warn("非群组成员之间的密钥分发被丢弃", "group", message.GroupName, "recipient", message.Recipient)
+		return
+	}
+	h.send(recipient, *message)
+}
+
+// rejectPlaintext 丢弃发往加密群组的明文消息并通知发送者
+func (h *Hub) rejectPlaintext(message *protocol.Message) {
+	metricDroppedSends.Inc(dropPlaintext)
+	slog.Warn("加密群组中的明文消息被拒绝", "group", message.GroupName, "sender", message.Sender)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if sender, ok := h.findClientByUsername(message.Sender); ok {
+		h.sendSystemMessage(sender, "群组 "+message.GroupName+" 已启用端到端加密，消息未加密，未被转发")
+	}
+}
+
 func (h *Hub) findClientByUsername(username string) (*Client, bool) {
 	for _, client := range h.Clients {
 		if client.Username == username {
@@ -221,9 +470,10 @@ func (h *Hub) broadcastMessage(message *protocol.Message) {
 	for _, client := range h.Clients {
 		select {
 		case client.Send <- *message:
-			fmt.Printf("消息已发送到客户端 %s: %s\n", client.ID, message.TextPayload)
+			client.logger().Debug("广播消息已发送到客户端", logging.Body(message.TextPayload))
 		default:
-			fmt.Printf("警告: 客户端 %s 的消息通道已满，消息被丢弃。\n", client.ID)
+			metricDroppedSends.Inc(dropBufferFull)
+			client.logger().Warn("客户端的消息通道已满，广播消息被丢弃")
 		}
 	}
 }
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"GoChat/internal/server/metrics"
+	"GoChat/pkg/protocol"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// 丢弃消息的原因，用作 gochat_dropped_sends_total 的 reason 标签
+const (
+	dropBufferFull   = "buffer_full"   // 接收方发送通道已满
+	dropRateLimited  = "rate_limited"  // 发送方超出频率限制
+	dropUnknownGroup = "unknown_group" // 目标群组不存在
+	dropPlaintext    = "plaintext"     // 发往加密群组的明文消息
+	dropNotLoggedIn  = "not_logged_in" // 发送方尚未登录
+	dropMiddleware   = "middleware"    // 被转发流程中的中间件丢弃
+)
+
+var (
+	metricClients           = metrics.NewGauge("gochat_connected_clients", "当前连接的客户端数")
+	metricGroups            = metrics.NewGauge("gochat_groups", "当前存在的群组数")
+	metricMessagesForwarded = metrics.NewCounterVec("gochat_messages_forwarded_total", "Hub 转发的消息数", "type")
+	metricBytesReceived     = metrics.NewCounter("gochat_received_bytes_total", "从客户端读取的字节数")
+	metricBytesSent         = metrics.NewCounter("gochat_sent_bytes_total", "发送给客户端的字节数")
+	metricDroppedSends      = metrics.NewCounterVec("gochat_dropped_sends_total", "被丢弃的消息数", "reason")
+	metricDecodeErrors      = metrics.NewCounter("gochat_frame_decode_errors_total", "无法解码的数据帧数")
+	metricHubLoopDuration   = metrics.NewHistogram("gochat_hub_loop_duration_seconds", "Hub 处理单个事件的耗时",
+		[]float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1})
+)
+
+// countingReader 统计从连接中读取的字节数
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	metricBytesReceived.Add(uint64(n))
+	return n, err
+}
+
+// isDecodeError 判断读取错误是否由格式错误的数据帧引起，而不是连接断开
+func isDecodeError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.Is(err, protocol.ErrFrameTooLarge) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"GoChat/pkg/protocol"
+	"log/slog"
+	"slices"
+)
+
+// ForwardFunc 处理一条经过 Hub 转发的消息
+type ForwardFunc func(message *protocol.Message)
+
+// Middleware 包装转发流程中的下一步，返回新的处理函数。中间件可以修改、记录或审计消息，
+// 不调用 next 即丢弃消息。中间件在 Hub 协程中执行，不能阻塞，next 也必须在返回前同步调用；
+// 写磁盘或访问网络等耗时操作应复制消息后交给其它协程
+type Middleware func(next ForwardFunc) ForwardFunc
+
+// Use 追加中间件并重建转发流程，必须在 Run 之前调用。消息依次经过:
+//
+//	validate (校验) → filterWords (屏蔽词) → Use 添加的中间件 → persist (聊天记录) → route (投递)
+//
+// 因此添加的中间件只会看到通过校验、将被投递的消息，对消息的修改也会体现在聊天记录中
+func (h *Hub) Use(middleware ...Middleware) {
+	h.middleware = append(h.middleware, middleware...)
+	chain := slices.Concat([]Middleware{h.validate, h.filterWords}, h.middleware, []Middleware{h.persist})
+	forward := ForwardFunc(h.route)
+	for i := len(chain) - 1; i >= 0; i-- {
+		forward = chain[i](forward)
+	}
+	h.forward = forward
+}
+
+// validate 丢弃无法投递的群组消息: 目标群组不存在，或发往加密群组的明文消息
+func (h *Hub) validate(next ForwardFunc) ForwardFunc {
+	return func(message *protocol.Message) {
+		if message.Type == protocol.GroupMessage || message.Type == protocol.GroupFileMessage {
+			h.groupMu.RLock()
+			group, ok := h.Groups[message.GroupName]
+			h.groupMu.RUnlock()
+			switch {
+			case !ok:
+				h.handled = true
+				metricDroppedSends.Inc(dropUnknownGroup)
+				slog.Warn("群组不存在，无法发送消息", "group", message.GroupName, "sender", message.Sender)
+				return
+			case group.Encrypted && message.Encrypted == nil:
+				h.handled = true
+				h.rejectPlaintext(message)
+				return
+			}
+		}
+		next(message)
+	}
+}
+
+// filterWords 将消息中的屏蔽词替换为星号
+func (h *Hub) filterWords(next ForwardFunc) ForwardFunc {
+	return func(message *protocol.Message) {
+		message.TextPayload = h.Settings().filterText(message.TextPayload)
+		next(message)
+	}
+}
+
+// persist 将世界大厅和群组中的文字消息记入聊天记录，文件不记录
+func (h *Hub) persist(next ForwardFunc) ForwardFunc {
+	return func(message *protocol.Message) {
+		switch message.Type {
+		case protocol.BroadcastMessage:
+			h.record(nil, message)
+		case protocol.GroupMessage:
+			h.groupMu.RLock()
+			group, ok := h.Groups[message.GroupName]
+			h.groupMu.RUnlock()
+			if ok {
+				h.record(group, message)
+			}
+		}
+		next(message)
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import "time"
+
+// Options 保存 Hub 及其客户端使用的运行参数
+type Options struct {
+	SendBuffer   int           // 每个客户端发送通道的缓冲大小
+	ReadTimeout  time.Duration // 读取超时
+	WriteTimeout time.Duration // 写入超时
+	MaxFrameSize int           // 单个数据帧的最大字节数
+	MaxClients   int           // 最大连接数，0 表示不限制
+	History      int           // 世界大厅和每个群组在内存中保留的最近消息数，0 表示不保留
+	Auth         Authenticator // 登录认证，为空时任何用户名都可以登录
+}
+
+// Authenticator 校验用户的登录凭据
+type Authenticator interface {
+	Authenticate(username, password string) error
+}
+
+// DefaultOptions 返回默认参数
+func DefaultOptions() Options {
+	return Options{
+		SendBuffer:   256,
+		ReadTimeout:  120 * time.Second,
+		WriteTimeout: 60 * time.Second,
+		MaxFrameSize: 64 << 20,
+		History:      100,
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Settings 是可以在运行时热加载的设置
+type Settings struct {
+	RateLimit   float64        // 每个客户端每秒允许的聊天消息数，0 表示不限制
+	RateBurst   int            // 允许的突发消息数
+	BannedUsers []string       // 被封禁的用户名
+	BannedIPs   []netip.Prefix // 被封禁的 IP 网段
+	MOTD        string         // 登录后发送给用户的欢迎消息
+	WordFilters []string       // 需要被屏蔽的词语
+}
+
+// bannedReason 判断客户端是否被封禁，返回封禁原因，未被封禁时返回空字符串
+func (s *Settings) bannedReason(c *Client) string {
+	if c.Username == SystemSender {
+		return "该用户名为系统保留"
+	}
+	if c.Username != "" && slices.Contains(s.BannedUsers, c.Username) {
+		return "用户名已被封禁"
+	}
+	ip, ok := remoteIP(c.conn.RemoteAddr())
+	if !ok {
+		return ""
+	}
+	for _, prefix := range s.BannedIPs {
+		if prefix.Contains(ip) {
+			return "IP 已被封禁"
+		}
+	}
+	return ""
+}
+
+// filterText 将文本中的屏蔽词替换为等长的星号，不区分大小写
+func (s *Settings) filterText(text string) string {
+	if text == "" || len(s.WordFilters) == 0 {
+		return text
+	}
+	runes := []rune(text)
+	for _, word := range s.WordFilters {
+		w := []rune(word)
+		if len(w) == 0 {
+			continue
+		}
+		for i := 0; i+len(w) <= len(runes); i++ {
+			if strings.EqualFold(string(runes[i:i+len(w)]), word) {
+				for j := range w {
+					runes[i+j] = '*'
+				}
+				i += len(w) - 1
+			}
+		}
+	}
+	return string(runes)
+}
+
+// remoteIP 从连接的远端地址中取出 IP，非 IP 连接返回 false
+func remoteIP(addr net.Addr) (netip.Addr, bool) {
+	if addr == nil {
+		return netip.Addr{}, false
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ap.Addr().Unmap(), true
+}
+
+// rateLimiter 是一个简单的令牌桶，只在客户端自己的读协程中使用
+type rateLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow 判断当前是否允许再发送一条消息
+func (r *rateLimiter) allow(rate float64, burst int, now time.Time) bool {
+	if rate <= 0 {
+		return true
+	}
+	if r.last.IsZero() {
+		r.tokens = float64(burst)
+	} else {
+		r.tokens += now.Sub(r.last).Seconds() * rate
+		if r.tokens > float64(burst) {
+			r.tokens = float64(burst)
+		}
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
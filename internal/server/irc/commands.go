@@ -0,0 +1,567 @@
+package irc
+
+import (
+	"GoChat/pkg/protocol"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// command 是解析后的一行 IRC 命令
+type command struct {
+	name   string
+	params []string
+}
+
+// parseLine 解析 "[:prefix] COMMAND param ... [:trailing]"，客户端发来的前缀被忽略
+func parseLine(line string) (command, bool) {
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	var cmd command
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if cmd.name != "" && strings.HasPrefix(line, ":") {
+			cmd.params = append(cmd.params, line[1:])
+			break
+		}
+		var field string
+		field, line, _ = strings.Cut(line, " ")
+		if cmd.name == "" {
+			cmd.name = strings.ToUpper(field)
+		} else {
+			cmd.params = append(cmd.params, field)
+		}
+	}
+	return cmd, cmd.name != ""
+}
+
+// handle 处理一条命令，需要交给 Hub 的消息放入 pending
+func (c *Conn) handle(cmd command) error {
+	switch cmd.name {
+	case "PING":
+		return c.send(":" + ServerName + " PONG " + ServerName + " :" + strings.Join(cmd.params, " "))
+	case "PONG":
+		return nil
+	case "QUIT":
+		c.send("ERROR :再见")
+		return io.EOF
+	case "CAP":
+		return c.capability(cmd.params)
+	}
+
+	if !c.loginSent {
+		return c.register(cmd)
+	}
+
+	switch cmd.name {
+	case "PASS", "USER":
+		return c.send(c.numeric("462", "已经注册"))
+	case "NICK":
+		if len(cmd.params) > 0 && cmd.params[0] == nickName(c.currentUser()) {
+			return nil
+		}
+		return c.send(c.numeric("432", strings.Join(cmd.params, " "), "不支持修改昵称"))
+	case "JOIN":
+		return c.join(cmd.params)
+	case "PART":
+		return c.part(cmd.params)
+	case "PRIVMSG", "NOTICE":
+		return c.privmsg(cmd.name == "NOTICE", cmd.params)
+	case "NAMES":
+		return c.names(cmd.params)
+	case "WHO":
+		return c.who(cmd.params)
+	case "WHOIS":
+		return c.whois(cmd.params)
+	case "LIST":
+		return c.list()
+	case "TOPIC":
+		return c.topic(cmd.params)
+	case "MODE":
+		return c.mode(cmd.params)
+	case "ISON":
+		return c.ison(cmd.params)
+	case "USERHOST":
+		return c.userhost(cmd.params)
+	case "AWAY":
+		if len(cmd.params) > 0 && cmd.params[0] != "" {
+			return c.send(c.numeric("306", "已标记为离开"))
+		}
+		return c.send(c.numeric("305", "已取消离开标记"))
+	default:
+		return c.send(c.numeric("421", cmd.name, "未知命令"))
+	}
+}
+
+// register 处理注册阶段的命令，收到 NICK 和 USER 后生成登录请求，PASS 为账号密码
+func (c *Conn) register(cmd command) error {
+	switch cmd.name {
+	case "PASS":
+		if len(cmd.params) > 0 {
+			c.pass = cmd.params[0]
+		}
+	case "NICK":
+		if len(cmd.params) == 0 || cmd.params[0] == "" {
+			return c.send(c.numeric("431", "没有提供昵称"))
+		}
+		c.nick = cmd.params[0]
+	case "USER":
+		if len(cmd.params) < 4 {
+			return c.send(c.numeric("461", "USER", "参数不足"))
+		}
+		c.userSent = true
+	default:
+		return c.send(c.numeric("451", "尚未注册"))
+	}
+	if c.nick != "" && c.userSent {
+		c.loginSent = true
+		c.pending = append(c.pending, &protocol.Message{Type: protocol.LoginRequest, Sender: c.nick, TextPayload: c.pass})
+	}
+	return nil
+}
+
+// capability 回复 IRCv3 能力协商：不支持任何扩展能力
+func (c *Conn) capability(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("461", "CAP", "参数不足"))
+	}
+	nick := nickName(c.currentUser())
+	if nick == "" {
+		nick = "*"
+	}
+	switch strings.ToUpper(params[0]) {
+	case "LS", "LIST":
+		return c.send(":" + ServerName + " CAP " + nick + " " + strings.ToUpper(params[0]) + " :")
+	case "REQ":
+		return c.send(":" + ServerName + " CAP " + nick + " NAK :" + strings.Join(params[1:], " "))
+	default:
+		return nil
+	}
+}
+
+func (c *Conn) join(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("461", "JOIN", "参数不足"))
+	}
+	if params[0] == "0" {
+		// JOIN 0 表示离开所有频道，世界大厅除外
+		me := c.currentUser()
+		for _, group := range c.memberOf(me) {
+			c.pending = append(c.pending, &protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: group})
+		}
+		return nil
+	}
+	for _, channel := range strings.Split(params[0], ",") {
+		if strings.EqualFold(channel, LobbyChannel) {
+			continue
+		}
+		group, ok := groupName(channel)
+		if !ok {
+			if err := c.send(c.numeric("403", channel, "无效的频道名")); err != nil {
+				return err
+			}
+			continue
+		}
+		c.pending = append(c.pending, &protocol.Message{Type: protocol.JoinGroupRequest, GroupName: group})
+	}
+	return nil
+}
+
+func (c *Conn) part(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("461", "PART", "参数不足"))
+	}
+	me := c.currentUser()
+	joined := c.memberOf(me)
+	for _, channel := range strings.Split(params[0], ",") {
+		if strings.EqualFold(channel, LobbyChannel) {
+			if err := c.send(c.notice("无法离开世界大厅 " + LobbyChannel)...); err != nil {
+				return err
+			}
+			continue
+		}
+		group, ok := groupName(channel)
+		if !ok || !slices.Contains(joined, group) {
+			if err := c.send(c.numeric("442", channel, "不在该频道中")); err != nil {
+				return err
+			}
+			continue
+		}
+		c.pending = append(c.pending, &protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: group})
+	}
+	return nil
+}
+
+// privmsg 将 PRIVMSG 和 NOTICE 转换为聊天消息。按照 IRC 的约定，NOTICE 出错时不回复
+func (c *Conn) privmsg(isNotice bool, params []string) error {
+	fail := func(line string) error {
+		if isNotice {
+			return nil
+		}
+		return c.send(line)
+	}
+	if len(params) == 0 {
+		return fail(c.numeric("411", "没有指定接收者"))
+	}
+	if len(params) < 2 || params[1] == "" {
+		return fail(c.numeric("412", "没有要发送的内容"))
+	}
+	text, ok := ctcpText(params[1])
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	tree := c.tree
+	c.mu.Unlock()
+	for _, target := range strings.Split(params[0], ",") {
+		switch {
+		case strings.EqualFold(target, LobbyChannel):
+			c.pending = append(c.pending, &protocol.Message{Type: protocol.BroadcastMessage, TextPayload: text})
+		case strings.HasPrefix(target, "#"):
+			group, ok := groupName(target)
+			if _, exists := tree.Groups[group]; !ok || !exists {
+				if err := fail(c.numeric("403", target, "频道不存在")); err != nil {
+					return err
+				}
+				continue
+			}
+			c.pending = append(c.pending, &protocol.Message{Type: protocol.GroupMessage, GroupName: group, TextPayload: text})
+		default:
+			user, ok := resolveNick(tree, target)
+			if !ok {
+				if err := fail(c.numeric("401", target, "用户不在线")); err != nil {
+					return err
+				}
+				continue
+			}
+			c.pending = append(c.pending, &protocol.Message{Type: protocol.PrivateMessage, Recipient: user, TextPayload: text})
+		}
+	}
+	return nil
+}
+
+// ctcpText 处理 CTCP 消息：ACTION (/me) 转换为普通文字，其它 CTCP 请求被忽略
+func ctcpText(text string) (string, bool) {
+	if !strings.HasPrefix(text, "\x01") {
+		return text, true
+	}
+	body := strings.Trim(text, "\x01")
+	if action, ok := strings.CutPrefix(body, "ACTION "); ok {
+		return "* " + action, true
+	}
+	return "", false
+}
+
+func (c *Conn) names(params []string) error {
+	channel := LobbyChannel
+	if len(params) > 0 && params[0] != "" {
+		channel, _, _ = strings.Cut(params[0], ",")
+	}
+	members, _ := c.channelMembers(channel)
+	return c.send(c.namesLines(c.currentUser(), channel, members)...)
+}
+
+func (c *Conn) who(params []string) error {
+	mask := ""
+	if len(params) > 0 {
+		mask = params[0]
+	}
+	var lines []string
+	if members, ok := c.channelMembers(mask); ok {
+		for _, user := range members {
+			lines = append(lines, c.numeric("352", mask, nickName(user), userHost, ServerName, nickName(user), "H", "0 "+user))
+		}
+	} else {
+		c.mu.Lock()
+		user, online := resolveNick(c.tree, mask)
+		c.mu.Unlock()
+		if online {
+			lines = append(lines, c.numeric("352", "*", nickName(user), userHost, ServerName, nickName(user), "H", "0 "+user))
+		}
+	}
+	lines = append(lines, c.numeric("315", mask, "WHO 列表结束"))
+	return c.send(lines...)
+}
+
+func (c *Conn) whois(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("431", "没有提供昵称"))
+	}
+	target := params[len(params)-1]
+	c.mu.Lock()
+	user, online := resolveNick(c.tree, target)
+	c.mu.Unlock()
+	if !online {
+		return c.send(c.numeric("401", target, "用户不在线"), c.numeric("318", target, "WHOIS 结束"))
+	}
+	channels := []string{LobbyChannel}
+	for _, group := range c.memberOf(user) {
+		if channel, ok := channelName(group); ok {
+			channels = append(channels, channel)
+		}
+	}
+	nick := nickName(user)
+	return c.send(
+		c.numeric("311", nick, nick, userHost, "*", user),
+		c.numeric("319", nick, strings.Join(channels, " ")),
+		c.numeric("318", nick, "WHOIS 结束"),
+	)
+}
+
+func (c *Conn) list() error {
+	c.mu.Lock()
+	tree := c.tree
+	c.mu.Unlock()
+	lines := []string{
+		c.numeric("321", "Channel", "Users Name"),
+		c.numeric("322", LobbyChannel, strconv.Itoa(len(onlineUsers(tree))), lobbyTopic),
+	}
+	for _, group := range slices.Sorted(maps.Keys(tree.Groups)) {
+		if channel, ok := channelName(group); ok {
+			lines = append(lines, c.numeric("322", channel, strconv.Itoa(len(tree.Groups[group])), groupTopic(tree, group)))
+		}
+	}
+	lines = append(lines, c.numeric("323", "LIST 结束"))
+	return c.send(lines...)
+}
+
+func (c *Conn) topic(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("461", "TOPIC", "参数不足"))
+	}
+	channel := params[0]
+	if len(params) > 1 {
+		return c.send(c.numeric("482", channel, "不支持修改话题"))
+	}
+	if strings.EqualFold(channel, LobbyChannel) {
+		return c.send(c.numeric("332", LobbyChannel, lobbyTopic))
+	}
+	c.mu.Lock()
+	tree := c.tree
+	c.mu.Unlock()
+	group, ok := groupName(channel)
+	if _, exists := tree.Groups[group]; !ok || !exists {
+		return c.send(c.numeric("403", channel, "频道不存在"))
+	}
+	return c.send(c.numeric("332", channel, groupTopic(tree, group)))
+}
+
+// mode 只回复查询，频道和用户模式都不能修改
+func (c *Conn) mode(params []string) error {
+	if len(params) == 0 {
+		return c.send(c.numeric("461", "MODE", "参数不足"))
+	}
+	target := params[0]
+	if !strings.HasPrefix(target, "#") {
+		return c.send(c.numeric("221", "+i"))
+	}
+	if len(params) > 1 {
+		if strings.Trim(params[1], "+") == "b" {
+			return c.send(c.numeric("368", target, "封禁列表结束"))
+		}
+		return c.send(c.numeric("482", target, "不支持修改频道模式"))
+	}
+	return c.send(c.numeric("324", target, "+nt"))
+}
+
+func (c *Conn) ison(params []string) error {
+	c.mu.Lock()
+	tree := c.tree
+	c.mu.Unlock()
+	var online []string
+	for _, param := range params {
+		for _, nick := range strings.Fields(param) {
+			if user, ok := resolveNick(tree, nick); ok {
+				online = append(online, nickName(user))
+			}
+		}
+	}
+	return c.send(c.numeric("303", strings.Join(online, " ")))
+}
+
+func (c *Conn) userhost(params []string) error {
+	c.mu.Lock()
+	tree := c.tree
+	c.mu.Unlock()
+	var replies []string
+	for _, nick := range params {
+		if user, ok := resolveNick(tree, nick); ok {
+			replies = append(replies, nickName(user)+"=+"+nickName(user)+"@"+userHost)
+		}
+	}
+	return c.send(c.numeric("302", strings.Join(replies, " ")))
+}
+
+// channelMembers 返回频道的成员，世界大厅的成员为所有在线用户
+func (c *Conn) channelMembers(channel string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.EqualFold(channel, LobbyChannel) {
+		return onlineUsers(c.tree), true
+	}
+	group, ok := groupName(channel)
+	if !ok {
+		return nil, false
+	}
+	members, ok := c.tree.Groups[group]
+	return members, ok
+}
+
+// memberOf 返回用户所在的群组
+func (c *Conn) memberOf(user string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var groups []string
+	for _, group := range slices.Sorted(maps.Keys(c.tree.Groups)) {
+		if slices.Contains(c.tree.Groups[group], user) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// namesLines 是 NAMES 的回复，成员较多时分成多行
+func (c *Conn) namesLines(me, channel string, members []string) []string {
+	var lines []string
+	var names []string
+	size := 0
+	for _, user := range slices.Sorted(slices.Values(members)) {
+		nick := nickName(user)
+		if size+len(nick) > maxChunk && len(names) > 0 {
+			lines = append(lines, c.numericFor(me, "353", "=", channel, strings.Join(names, " ")))
+			names, size = nil, 0
+		}
+		names = append(names, nick)
+		size += len(nick) + 1
+	}
+	if len(names) > 0 {
+		lines = append(lines, c.numericFor(me, "353", "=", channel, strings.Join(names, " ")))
+	}
+	return append(lines, c.numericFor(me, "366", channel, "NAMES 列表结束"))
+}
+
+// numeric 生成发给当前用户的数字回复，最后一个参数作为 trailing 参数
+func (c *Conn) numeric(code string, params ...string) string {
+	return c.numericFor(c.currentUser(), code, params...)
+}
+
+func (c *Conn) numericFor(user, code string, params ...string) string {
+	nick := nickName(user)
+	if nick == "" {
+		nick = "*"
+	}
+	line := ":" + ServerName + " " + code + " " + nick
+	for i, param := range params {
+		if i == len(params)-1 {
+			line += " :" + clean(param)
+		} else {
+			line += " " + param
+		}
+	}
+	return line
+}
+
+// onlineUsers 返回已登录的在线用户
+func onlineUsers(tree protocol.TreePayload) []string {
+	return slices.DeleteFunc(slices.Clone(tree.Users), func(u string) bool { return u == "" })
+}
+
+func groupTopic(tree protocol.TreePayload, group string) string {
+	if slices.Contains(tree.EncryptedGroups, group) {
+		return "GoChat 群组 " + group + " (端到端加密，IRC 中无法查看消息)"
+	}
+	return "GoChat 群组 " + group
+}
+
+// nickName 将用户名转换为合法的 IRC 昵称，空格等 IRC 中有特殊含义的字符替换为下划线
+func nickName(user string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == ',' || r == '!' || r == '@' || r == ':' || r == '#' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, user)
+}
+
+// resolveNick 查找 IRC 昵称对应的在线用户
+func resolveNick(tree protocol.TreePayload, nick string) (string, bool) {
+	users := onlineUsers(tree)
+	if slices.Contains(users, nick) {
+		return nick, true
+	}
+	for _, user := range users {
+		if nickName(user) == nick {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// channelName 返回群组对应的频道名。名称中含有 IRC 无法表示的字符，
+// 或与世界大厅同名的群组无法在 IRC 中使用
+func channelName(group string) (string, bool) {
+	if group == "" || "#"+group == LobbyChannel || strings.ContainsFunc(group, func(r rune) bool {
+		return r == ' ' || r == ',' || unicode.IsControl(r)
+	}) {
+		return "", false
+	}
+	return "#" + group, true
+}
+
+// groupName 是 channelName 的逆操作
+func groupName(channel string) (string, bool) {
+	group, ok := strings.CutPrefix(channel, "#")
+	if !ok {
+		return "", false
+	}
+	if _, valid := channelName(group); !valid {
+		return "", false
+	}
+	return group, true
+}
+
+// clean 去掉会破坏 IRC 协议的换行和空字符
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// splitText 将多行或过长的文本拆分为多条 PRIVMSG 的正文
+func splitText(text string) []string {
+	var chunks []string
+	for _, line := range strings.Split(text, "\n") {
+		line = clean(strings.TrimRight(line, "\r"))
+		for len(line) > maxChunk {
+			cut := maxChunk
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			chunks = append(chunks, line)
+		}
+	}
+	return chunks
+}
+
+// userPrefix 返回用户的 IRC 消息前缀 nick!user@host
+func userPrefix(user string) string {
+	nick := nickName(user)
+	return nick + "!" + nick + "@" + userHost
+}
@@ -0,0 +1,205 @@
+package irc
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   command
+		wantOK bool
+	}{
+		{"没有参数", "quit", command{name: "QUIT"}, true},
+		{"普通参数", "NICK alice", command{name: "NICK", params: []string{"alice"}}, true},
+		{"trailing 参数", "PRIVMSG #dev :周五 发布", command{name: "PRIVMSG", params: []string{"#dev", "周五 发布"}}, true},
+		{"USER 命令", "USER alice 0 * :Alice Liddell", command{name: "USER", params: []string{"alice", "0", "*", "Alice Liddell"}}, true},
+		{"忽略前缀", ":alice!a@host PRIVMSG bob :hi", command{name: "PRIVMSG", params: []string{"bob", "hi"}}, true},
+		{"多余的空格", "  JOIN   #a,#b  ", command{name: "JOIN", params: []string{"#a,#b"}}, true},
+		{"空的 trailing 参数", "AWAY :", command{name: "AWAY", params: []string{""}}, true},
+		{"trailing 中的冒号", "PRIVMSG bob ::) 好", command{name: "PRIVMSG", params: []string{"bob", ":) 好"}}, true},
+		{"空行", "", command{}, false},
+		{"只有空格", "   ", command{}, false},
+		{"只有前缀", ":alice", command{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLine(tt.line)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseLine(%q) = (%+v, %v), 期望 (%+v, %v)", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	long := strings.Repeat("中", 200) // 600 字节
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"单行", "hi", []string{"hi"}},
+		{"多行", "a\r\nb\n\nc", []string{"a", "b", "c"}},
+		{"空字符", "a\x00b", []string{"a b"}},
+		{"按字符边界拆分长行", long, []string{strings.Repeat("中", 133), strings.Repeat("中", 67)}},
+		{"空文本", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitText(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitText() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCtcpText(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{"hi", "hi", true},
+		{"\x01ACTION 挥手\x01", "* 挥手", true},
+		{"\x01VERSION\x01", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := ctcpText(tt.text); got != tt.want || ok != tt.wantOK {
+			t.Errorf("ctcpText(%q) = (%q, %v), 期望 (%q, %v)", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestChannelName(t *testing.T) {
+	tests := []struct {
+		group   string
+		channel string
+		ok      bool
+	}{
+		{"dev", "#dev", true},
+		{"研发", "#研发", true},
+		{"", "", false},
+		{"lobby", "", false},
+		{"a b", "", false},
+		{"a,b", "", false},
+		{"a\x07", "", false},
+	}
+	for _, tt := range tests {
+		channel, ok := channelName(tt.group)
+		if channel != tt.channel || ok != tt.ok {
+			t.Errorf("channelName(%q) = (%q, %v), 期望 (%q, %v)", tt.group, channel, ok, tt.channel, tt.ok)
+		}
+		if group, ok := groupName(tt.channel); tt.ok && (group != tt.group || !ok) {
+			t.Errorf("groupName(%q) = (%q, %v), 期望 %q", tt.channel, group, ok, tt.group)
+		}
+	}
+	if _, ok := groupName("dev"); ok {
+		t.Error("groupName 接受了没有 # 的频道名")
+	}
+}
+
+func TestResolveNick(t *testing.T) {
+	tree := protocol.TreePayload{Users: []string{"", "alice", "bob smith", "c:d"}}
+	tests := []struct {
+		nick string
+		want string
+		ok   bool
+	}{
+		{"alice", "alice", true},
+		{"bob_smith", "bob smith", true},
+		{"c_d", "c:d", true},
+		{"carol", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := resolveNick(tree, tt.nick); got != tt.want || ok != tt.ok {
+			t.Errorf("resolveNick(%q) = (%q, %v), 期望 (%q, %v)", tt.nick, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// recordConn 记录写入的 IRC 回复
+type recordConn struct {
+	net.Conn
+	out bytes.Buffer
+}
+
+func (r *recordConn) Write(p []byte) (int, error) {
+	return r.out.Write(p)
+}
+
+func TestHandle(t *testing.T) {
+	tree := protocol.TreePayload{
+		Users:  []string{"alice", "bob smith"},
+		Groups: map[string][]string{"dev": {"alice"}, "ops": {"bob smith"}},
+	}
+	tests := []struct {
+		name      string
+		loggedIn  bool
+		lines     []string
+		want      []*protocol.Message
+		wantErr   error
+		wantReply string // 回复中应包含的内容，为空表示不应回复
+	}{
+		{"注册", false, []string{"PASS secret", "NICK alice", "USER alice 0 * :Alice"},
+			[]*protocol.Message{{Type: protocol.LoginRequest, Sender: "alice", TextPayload: "secret"}}, nil, ""},
+		{"没有密码的注册", false, []string{"USER a 0 * :A", "NICK alice"},
+			[]*protocol.Message{{Type: protocol.LoginRequest, Sender: "alice"}}, nil, ""},
+		{"注册前发送消息", false, []string{"PRIVMSG #lobby :hi"}, nil, nil, " 451 * :尚未注册"},
+		{"USER 参数不足", false, []string{"USER alice"}, nil, nil, " 461 * USER :参数不足"},
+		{"PING", false, []string{"PING :123"}, nil, nil, ":gochat PONG gochat :123\r\n"},
+		{"能力协商", false, []string{"CAP LS 302"}, nil, nil, ":gochat CAP * LS :\r\n"},
+		{"大厅消息", true, []string{"PRIVMSG #lobby :大家好"},
+			[]*protocol.Message{{Type: protocol.BroadcastMessage, TextPayload: "大家好"}}, nil, ""},
+		{"多个目标", true, []string{"PRIVMSG #dev,bob_smith :\x01ACTION 挥手\x01"},
+			[]*protocol.Message{
+				{Type: protocol.GroupMessage, GroupName: "dev", TextPayload: "* 挥手"},
+				{Type: protocol.PrivateMessage, Recipient: "bob smith", TextPayload: "* 挥手"},
+			}, nil, ""},
+		{"不存在的频道", true, []string{"PRIVMSG #qa :hi"}, nil, nil, " 403 alice #qa :频道不存在"},
+		{"NOTICE 出错不回复", true, []string{"NOTICE carol :hi"}, nil, nil, ""},
+		{"加入多个频道", true, []string{"JOIN #lobby,#qa,#a\x07"},
+			[]*protocol.Message{{Type: protocol.JoinGroupRequest, GroupName: "qa"}}, nil, " 403 alice #a\x07 :无效的频道名"},
+		{"离开所有频道", true, []string{"JOIN 0"},
+			[]*protocol.Message{{Type: protocol.LeaveGroupRequest, GroupName: "dev"}}, nil, ""},
+		{"离开未加入的频道", true, []string{"PART #ops"}, nil, nil, " 442 alice #ops :不在该频道中"},
+		{"修改昵称", true, []string{"NICK carol"}, nil, nil, " 432 alice carol :不支持修改昵称"},
+		{"重复注册", true, []string{"USER a 0 * :A"}, nil, nil, " 462 alice :已经注册"},
+		{"未知命令", true, []string{"KNOCK #dev"}, nil, nil, " 421 alice KNOCK :未知命令"},
+		{"退出", true, []string{"QUIT :bye"}, nil, io.EOF, "ERROR :再见"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordConn{}
+			c := NewConn(conn, 512)
+			c.tree = tree
+			if tt.loggedIn {
+				c.loginSent, c.username = true, "alice"
+			}
+			var err error
+			for _, line := range tt.lines {
+				cmd, _ := parseLine(line)
+				if err = c.handle(cmd); err != nil {
+					break
+				}
+			}
+			if err != tt.wantErr {
+				t.Fatalf("handle() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(c.pending, tt.want) {
+				t.Fatalf("消息 = %+v, 期望 %+v", c.pending, tt.want)
+			}
+			if got := conn.out.String(); tt.wantReply == "" && got != "" || !strings.Contains(got, tt.wantReply) {
+				t.Fatalf("回复 = %q, 期望包含 %q", got, tt.wantReply)
+			}
+		})
+	}
+}
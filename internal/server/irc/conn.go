@@ -0,0 +1,321 @@
+// Package irc 将 IRC 客户端适配为 core.Conn：IRC 频道对应群组，
+// 固定的 #lobby 频道对应世界大厅，发给昵称的 PRIVMSG 对应私聊
+package irc
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/linechat"
+	"GoChat/pkg/protocol"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ServerName   = "gochat" // 服务器在 IRC 消息前缀中使用的名称
+	LobbyChannel = "#lobby" // 世界大厅对应的频道
+	userHost     = "gochat" // 用户前缀 nick!user@host 中的 host
+	maxChunk     = 400      // 单条 PRIVMSG 正文的最大字节数，IRC 一行最长 512 字节
+	lobbyTopic   = "GoChat 世界大厅"
+)
+
+// Conn 实现 core.Conn。连接先完成 IRC 注册 (PASS、NICK、USER)，再生成登录请求
+type Conn struct {
+	conn  net.Conn
+	lines linechat.Lines
+
+	// 以下字段只在读协程中访问
+	pass      string
+	nick      string // 注册时请求的昵称
+	userSent  bool
+	loginSent bool
+	pending   []*protocol.Message // 一行命令可能对应多条消息，如 JOIN #a,#b
+	deadline  time.Time           // Hub 设置的读取截止时间
+	timeout   time.Duration       // Hub 的读取超时，登录后用于空闲检测
+
+	mu          sync.Mutex
+	username    string // 登录成功后的用户名
+	tree        protocol.TreePayload
+	joinedLobby bool // 是否已经向客户端发送了加入 #lobby 的消息
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// NewConn 创建 IRC 连接，maxLine 为一行命令的最大字节数
+func NewConn(conn net.Conn, maxLine int) *Conn {
+	return &Conn{conn: conn, lines: linechat.NewStreamLines(conn, maxLine)}
+}
+
+// ReadMessage 读取 IRC 命令，直到得到一条需要交给 Hub 的消息；
+// NAMES、WHO 等只涉及本地状态的命令直接在这里回复
+func (c *Conn) ReadMessage() (*protocol.Message, error) {
+	for len(c.pending) == 0 {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		cmd, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		if err := c.handle(cmd); err != nil {
+			return nil, err
+		}
+	}
+	message := c.pending[0]
+	c.pending = c.pending[1:]
+	return message, nil
+}
+
+// readLine 读取一行命令。注册 (PASS、NICK、USER) 必须在 Hub 设置的截止时间内完成；
+// 登录后每行重新计时，空闲一半的超时时间时向客户端发送 PING，到超时仍没有收到任何数据 (包括 PONG) 则断开
+func (c *Conn) readLine() (string, error) {
+	deadline := c.deadline
+	if c.loginSent && c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+		ping := time.AfterFunc(c.timeout/2, func() { c.send("PING :" + ServerName) })
+		defer ping.Stop()
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return "", err
+	}
+	return c.lines.ReadLine()
+}
+
+// WriteMessage 将 Hub 发来的消息转换为 IRC 消息
+func (c *Conn) WriteMessage(message protocol.Message) error {
+	var lines []string
+	switch message.Type {
+	case protocol.LoginResponse:
+		lines = c.onLogin(message)
+	case protocol.TreeUpdate:
+		lines = c.onTree(message.TreePayload)
+	case protocol.SystemMessage:
+		lines = c.notice(message.TextPayload)
+	case protocol.BroadcastMessage, protocol.GroupMessage, protocol.PrivateMessage,
+		protocol.PrivateFileMessage, protocol.GroupFileMessage:
+		lines = c.onChat(message)
+	}
+	return c.send(lines...)
+}
+
+// onLogin 登录成功后发送欢迎信息，失败时告知原因，连接随后由 Hub 断开
+func (c *Conn) onLogin(message protocol.Message) []string {
+	if message.TextPayload != "" {
+		return []string{
+			c.numeric("464", "登录失败: "+clean(message.TextPayload)),
+			"ERROR :" + clean(message.TextPayload),
+		}
+	}
+	c.mu.Lock()
+	c.username = message.Recipient
+	c.mu.Unlock()
+
+	// 使用客户端证书登录时用户名可能与请求的昵称不同，001 中的昵称为准
+	nick := nickName(message.Recipient)
+	return []string{
+		c.numeric("001", "欢迎来到 GoChat, "+nick),
+		c.numeric("002", "服务器为 "+ServerName),
+		c.numeric("003", "世界大厅为 "+LobbyChannel+"，其它频道对应同名群组"),
+		c.numeric("004", ServerName, "gochat", "i", "nt"),
+		c.numeric("005", "CHANTYPES=#", "PREFIX=()", "CHANMODES=,,,nt", "NETWORK=GoChat", "CASEMAPPING=ascii", "是本服务器支持的参数"),
+		c.numeric("422", "没有 MOTD，欢迎消息会以 NOTICE 发送"),
+	}
+}
+
+// onTree 保存最新的在线列表，并把用户和群组成员的变化转换为 JOIN、PART 和 QUIT
+func (c *Conn) onTree(tree protocol.TreePayload) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.tree
+	c.tree = tree
+	me := c.username
+	if me == "" {
+		return nil
+	}
+	if !c.joinedLobby {
+		// 登录后的第一份列表只用于加入 #lobby，不提示其他用户的状态
+		c.joinedLobby = true
+		return c.joinLines(me, LobbyChannel, lobbyTopic, onlineUsers(tree))
+	}
+
+	var lines []string
+	for _, user := range onlineUsers(tree) {
+		if user != me && !slices.Contains(old.Users, user) {
+			lines = append(lines, ":"+userPrefix(user)+" JOIN "+LobbyChannel)
+		}
+	}
+	for _, user := range onlineUsers(old) {
+		if user != me && !slices.Contains(tree.Users, user) {
+			lines = append(lines, ":"+userPrefix(user)+" QUIT :下线")
+		}
+	}
+
+	groups := make(map[string]bool)
+	for name := range old.Groups {
+		groups[name] = true
+	}
+	for name := range tree.Groups {
+		groups[name] = true
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		channel, ok := channelName(name)
+		if !ok {
+			continue
+		}
+		before, after := old.Groups[name], tree.Groups[name]
+		wasIn, isIn := slices.Contains(before, me), slices.Contains(after, me)
+		switch {
+		case !wasIn && isIn:
+			lines = append(lines, c.joinLines(me, channel, groupTopic(tree, name), after)...)
+		case wasIn && !isIn:
+			lines = append(lines, ":"+userPrefix(me)+" PART "+channel)
+		case isIn:
+			for _, user := range after {
+				if !slices.Contains(before, user) {
+					lines = append(lines, ":"+userPrefix(user)+" JOIN "+channel)
+				}
+			}
+			for _, user := range before {
+				// 下线的用户已经收到 QUIT，不再逐个频道发送 PART
+				if !slices.Contains(after, user) && slices.Contains(tree.Users, user) {
+					lines = append(lines, ":"+userPrefix(user)+" PART "+channel)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// joinLines 是自己加入频道时发送的 JOIN、话题和成员列表
+func (c *Conn) joinLines(me, channel, topic string, members []string) []string {
+	lines := []string{
+		":" + userPrefix(me) + " JOIN " + channel,
+		c.numericFor(me, "332", channel, topic),
+	}
+	return append(lines, c.namesLines(me, channel, members)...)
+}
+
+// onChat 将聊天消息转换为 PRIVMSG。自己发出的消息由 IRC 客户端在本地显示，不再回显
+func (c *Conn) onChat(message protocol.Message) []string {
+	me := c.currentUser()
+	private := message.Type == protocol.PrivateMessage || message.Type == protocol.PrivateFileMessage
+	if message.Sender == me && !(private && message.Recipient == me) {
+		return nil
+	}
+
+	var target string
+	switch {
+	case message.Type == protocol.BroadcastMessage:
+		target = LobbyChannel
+	case private:
+		target = nickName(me)
+	default:
+		channel, ok := channelName(message.GroupName)
+		if !ok {
+			return nil
+		}
+		target = channel
+	}
+
+	var text string
+	switch {
+	case message.Encrypted != nil:
+		text = "(端到端加密消息，无法在 IRC 中查看)"
+	case message.Type == protocol.PrivateFileMessage || message.Type == protocol.GroupFileMessage:
+		text = "发送了文件 " + message.FilePayload.Name + "，IRC 中无法接收文件"
+	default:
+		text = message.TextPayload
+	}
+
+	var lines []string
+	for _, chunk := range splitText(text) {
+		lines = append(lines, ":"+userPrefix(message.Sender)+" PRIVMSG "+target+" :"+chunk)
+	}
+	return lines
+}
+
+// notice 将系统通知转换为来自服务器的 NOTICE
+func (c *Conn) notice(text string) []string {
+	target := nickName(c.currentUser())
+	if target == "" {
+		target = "*"
+	}
+	var lines []string
+	for _, chunk := range splitText(text) {
+		lines = append(lines, ":"+ServerName+" NOTICE "+target+" :"+chunk)
+	}
+	return lines
+}
+
+// send 写出若干行 IRC 消息
+func (c *Conn) send(lines ...string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+func (c *Conn) currentUser() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username
+}
+
+// TLSState 实现 core.TLSConn，使双向 TLS 的客户端证书对 IRC 连接同样有效
+func (c *Conn) TLSState() (tls.ConnectionState, bool) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConn.ConnectionState(), true
+}
+
+// SetReadDeadline 记录 Hub 设置的读取截止时间，由 readLine 应用到底层连接。
+// IRC 客户端长时间不发言是正常的，登录后改为以 PING 检测空闲的连接
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	c.timeout = 0
+	if !t.IsZero() {
+		c.timeout = time.Until(t)
+	}
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() { err = c.conn.Close() })
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+var _ core.TLSConn = (*Conn)(nil)
@@ -0,0 +1,177 @@
+package linechat
+
+import (
+	"GoChat/pkg/protocol"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const help = `命令:
+  /who                  列出在线用户
+  /groups               列出群组及成员
+  /join <群组>          加入群组 (不存在时创建)，之后的文字发送到该群组
+  /leave [群组]         离开群组，默认为当前群组
+  /msg <用户> <内容>    发送私聊
+  /to [用户|#群组]      切换文字的发送目标，不带参数时回到世界大厅
+  /quit                 退出
+其它以 / 开头的内容会被当作命令，发送以 / 开头的文字请写成 //
+`
+
+// parse 解析一行输入，返回需要交给 Hub 的消息；只涉及本地状态的命令返回 nil
+func (c *Conn) parse(line string) (*protocol.Message, error) {
+	if !strings.HasPrefix(line, "/") || strings.HasPrefix(line, "//") {
+		return c.chat(strings.TrimPrefix(line, "/")), nil
+	}
+
+	cmd, args, _ := strings.Cut(line[1:], " ")
+	args = strings.TrimSpace(args)
+	switch strings.ToLower(cmd) {
+	case "help", "h", "?":
+		return nil, c.reply(help)
+
+	case "who", "users":
+		c.mu.Lock()
+		users := slices.DeleteFunc(slices.Clone(c.tree.Users), func(u string) bool { return u == "" })
+		bots := c.tree.Bots
+		c.mu.Unlock()
+		slices.Sort(users)
+		for i, user := range users {
+			if slices.Contains(bots, user) {
+				users[i] += " (机器人)"
+			}
+		}
+		return nil, c.reply(fmt.Sprintf("在线用户 (%d): %s\n", len(users), sanitize(strings.Join(users, ", "))))
+
+	case "groups":
+		return nil, c.reply(c.groupList())
+
+	case "join", "j":
+		if args == "" {
+			return nil, c.reply("用法: /join <群组>\n")
+		}
+		group := strings.TrimPrefix(args, "#")
+		c.switchTarget("#" + group)
+		return &protocol.Message{Type: protocol.JoinGroupRequest, GroupName: group}, nil
+
+	case "leave", "part":
+		group := strings.TrimPrefix(args, "#")
+		if group == "" {
+			if !strings.HasPrefix(c.target, "#") {
+				return nil, c.reply("用法: /leave <群组>\n")
+			}
+			group = c.target[1:]
+		}
+		if c.target == "#"+group {
+			c.switchTarget("")
+		}
+		return &protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: group}, nil
+
+	case "msg", "m":
+		recipient, text, _ := strings.Cut(args, " ")
+		if recipient == "" || strings.TrimSpace(text) == "" {
+			return nil, c.reply("用法: /msg <用户> <内容>\n")
+		}
+		return &protocol.Message{Type: protocol.PrivateMessage, Recipient: recipient, TextPayload: strings.TrimSpace(text)}, nil
+
+	case "to":
+		c.switchTarget(args)
+		if args == "" {
+			return nil, c.reply("文字将发送到世界大厅\n")
+		}
+		return nil, c.reply("文字将发送到 " + args + "\n")
+
+	case "quit", "exit":
+		return nil, io.EOF
+
+	default:
+		return nil, c.reply("未知命令 /" + sanitize(cmd) + "，输入 /help 查看命令\n")
+	}
+}
+
+// chat 将普通文本发送到当前目标
+func (c *Conn) chat(text string) *protocol.Message {
+	switch {
+	case c.target == "":
+		return &protocol.Message{Type: protocol.BroadcastMessage, TextPayload: text}
+	case strings.HasPrefix(c.target, "#"):
+		return &protocol.Message{Type: protocol.GroupMessage, GroupName: c.target[1:], TextPayload: text}
+	default:
+		return &protocol.Message{Type: protocol.PrivateMessage, Recipient: c.target, TextPayload: text}
+	}
+}
+
+func (c *Conn) switchTarget(target string) {
+	c.target = target
+	if target == "" {
+		c.setPrompt("> ")
+	} else {
+		c.setPrompt("[" + target + "] > ")
+	}
+}
+
+func (c *Conn) groupList() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.tree.Groups) == 0 {
+		return "当前没有群组\n"
+	}
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(c.tree.Groups)) {
+		members := slices.Sorted(slices.Values(c.tree.Groups[name]))
+		flag := ""
+		if slices.Contains(c.tree.EncryptedGroups, name) {
+			flag = " (端到端加密)"
+		}
+		fmt.Fprintf(&b, "#%s%s: %s\n", sanitize(name), flag, sanitize(strings.Join(members, ", ")))
+	}
+	return b.String()
+}
+
+// format 将聊天消息格式化为文本行，username 为当前用户
+func format(message protocol.Message, username string) string {
+	prefix := "[" + message.Timestamp.Local().Format("15:04") + "] "
+	sender := sanitize(message.Sender)
+
+	var body string
+	switch {
+	case message.Encrypted != nil:
+		body = "(端到端加密消息，无法在终端中查看)"
+	case message.Type == protocol.PrivateFileMessage || message.Type == protocol.GroupFileMessage:
+		body = fmt.Sprintf("发送了文件 %s (%d 字节)，终端中无法接收文件", sanitize(message.FilePayload.Name), message.FilePayload.Size)
+	default:
+		body = sanitize(message.TextPayload)
+	}
+
+	switch message.Type {
+	case protocol.BroadcastMessage:
+		return prefix + sender + ": " + body + "\n"
+	case protocol.GroupMessage, protocol.GroupFileMessage:
+		return prefix + "#" + sanitize(message.GroupName) + " " + sender + ": " + body + "\n"
+	case protocol.PrivateMessage, protocol.PrivateFileMessage:
+		if message.Sender == username {
+			return prefix + "[私聊 -> " + sanitize(message.Recipient) + "] " + body + "\n"
+		}
+		return prefix + "[私聊] " + sender + ": " + body + "\n"
+	case protocol.SystemMessage:
+		return prefix + "* " + body + "\n"
+	default:
+		return ""
+	}
+}
+
+// sanitize 去掉控制字符，防止其他用户通过转义序列操纵终端；换行替换为缩进后的新行
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(s, "\n", "\n    "))
+}
@@ -0,0 +1,103 @@
+package linechat
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestConn 创建输出写入 out 的文本会话
+func newTestConn(out *bytes.Buffer) *Conn {
+	c := NewConn(NewStreamLines(out, 1024), io.NopCloser(nil), nil)
+	c.username = "alice"
+	c.tree = protocol.TreePayload{
+		Users:           []string{"bob", "alice", "", "echo"},
+		Bots:            []string{"echo"},
+		Groups:          map[string][]string{"ops": {"bob"}, "dev": {"bob", "alice"}},
+		EncryptedGroups: []string{"ops"},
+	}
+	return c
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		line       string
+		want       *protocol.Message
+		wantErr    error
+		wantTarget string
+		wantReply  string // 回复中应包含的内容
+	}{
+		{"大厅消息", "", "大家好", &protocol.Message{Type: protocol.BroadcastMessage, TextPayload: "大家好"}, nil, "", ""},
+		{"群组消息", "#dev", "发布了", &protocol.Message{Type: protocol.GroupMessage, GroupName: "dev", TextPayload: "发布了"}, nil, "#dev", ""},
+		{"私聊目标", "bob", "hi", &protocol.Message{Type: protocol.PrivateMessage, Recipient: "bob", TextPayload: "hi"}, nil, "bob", ""},
+		{"双斜杠转义", "", "//tmp 满了", &protocol.Message{Type: protocol.BroadcastMessage, TextPayload: "/tmp 满了"}, nil, "", ""},
+		{"加入群组", "", "/join #dev", &protocol.Message{Type: protocol.JoinGroupRequest, GroupName: "dev"}, nil, "#dev", ""},
+		{"加入缺少群组", "", "/join", nil, nil, "", "用法: /join"},
+		{"离开当前群组", "#dev", "/leave", &protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: "dev"}, nil, "", ""},
+		{"离开其它群组", "#dev", "/part ops", &protocol.Message{Type: protocol.LeaveGroupRequest, GroupName: "ops"}, nil, "#dev", ""},
+		{"不在群组中离开", "", "/leave", nil, nil, "", "用法: /leave"},
+		{"私聊命令", "", "/msg bob  周五见 ", &protocol.Message{Type: protocol.PrivateMessage, Recipient: "bob", TextPayload: "周五见"}, nil, "", ""},
+		{"私聊缺少内容", "", "/m bob", nil, nil, "", "用法: /msg"},
+		{"切换目标", "", "/to bob", nil, nil, "bob", "文字将发送到 bob"},
+		{"回到大厅", "#dev", "/to", nil, nil, "", "世界大厅"},
+		{"在线用户", "", "/WHO", nil, nil, "", "在线用户 (3): alice, bob, echo (机器人)"},
+		{"群组列表", "", "/groups", nil, nil, "", "#dev: alice, bob\n#ops (端到端加密): bob\n"},
+		{"帮助", "", "/?", nil, nil, "", "命令:"},
+		{"退出", "", "/quit", nil, io.EOF, "", ""},
+		{"未知命令", "", "/\x1b[2Jfoo", nil, nil, "", "未知命令 /[2Jfoo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := newTestConn(&out)
+			c.target = tt.target
+			got, err := c.parse(tt.line)
+			if err != tt.wantErr {
+				t.Fatalf("parse() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parse() = %+v, 期望 %+v", got, tt.want)
+			}
+			if c.target != tt.wantTarget {
+				t.Errorf("目标 = %q, 期望 %q", c.target, tt.wantTarget)
+			}
+			if !strings.Contains(out.String(), tt.wantReply) {
+				t.Errorf("回复 = %q, 期望包含 %q", out.String(), tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		message protocol.Message
+		want    string
+	}{
+		{"大厅消息", protocol.Message{Type: protocol.BroadcastMessage, Sender: "bob", TextPayload: "早"}, "[09:30] bob: 早\n"},
+		{"群组消息", protocol.Message{Type: protocol.GroupMessage, Sender: "bob", GroupName: "dev", TextPayload: "早"}, "[09:30] #dev bob: 早\n"},
+		{"收到私聊", protocol.Message{Type: protocol.PrivateMessage, Sender: "bob", Recipient: "alice", TextPayload: "hi"}, "[09:30] [私聊] bob: hi\n"},
+		{"发出的私聊", protocol.Message{Type: protocol.PrivateMessage, Sender: "alice", Recipient: "bob", TextPayload: "hi"}, "[09:30] [私聊 -> bob] hi\n"},
+		{"系统消息", protocol.Message{Type: protocol.SystemMessage, TextPayload: "服务器将重启"}, "[09:30] * 服务器将重启\n"},
+		{"多行消息", protocol.Message{Type: protocol.BroadcastMessage, Sender: "bob", TextPayload: "a\nb"}, "[09:30] bob: a\n    b\n"},
+		{"控制字符", protocol.Message{Type: protocol.BroadcastMessage, Sender: "bo\x1bb", TextPayload: "\x1b[31m红\a"}, "[09:30] bob: [31m红\n"},
+		{"加密消息", protocol.Message{Type: protocol.PrivateMessage, Sender: "bob", Encrypted: &protocol.EncryptedPayload{}}, "[09:30] [私聊] bob: (端到端加密消息，无法在终端中查看)\n"},
+		{"文件", protocol.Message{Type: protocol.GroupFileMessage, Sender: "bob", GroupName: "dev", FilePayload: protocol.FilePayload{Name: "a.txt", Size: 3}}, "[09:30] #dev bob: 发送了文件 a.txt (3 字节)，终端中无法接收文件\n"},
+		{"不显示的消息", protocol.Message{Type: protocol.LoginResponse}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.message.Timestamp = at
+			if got := format(tt.message, "alice"); got != tt.want {
+				t.Fatalf("format() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
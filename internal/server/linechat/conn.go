@@ -0,0 +1,261 @@
+// Package linechat 将基于行的文本会话 (SSH 终端、nc 等) 适配为 core.Conn：
+// 每行输入是一条聊天消息或斜杠命令，收到的消息格式化为可读的文本行
+package linechat
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/pkg/protocol"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lines 是按行读写的文本会话
+type Lines interface {
+	ReadLine() (string, error)
+	io.Writer
+}
+
+// idleTimeout 是登录后的最短空闲超时。nc 等客户端不会响应保活，
+// 终端用户长时间不输入也是正常的，因此登录后不再使用 Hub 较短的读取超时
+const idleTimeout = 30 * time.Minute
+
+// prompter 由支持输入提示符的会话实现，如 term.Terminal
+type prompter interface {
+	SetPrompt(prompt string)
+}
+
+// Conn 实现 core.Conn。登录请求由 Conn 自己生成：
+// 传输层已经认证了用户时直接使用该用户名，否则先询问用户名和密码
+type Conn struct {
+	lines  Lines
+	closer io.Closer
+	remote net.Addr
+
+	user       string // 传输层认证的用户名，为空表示需要询问
+	loginSent  bool   // 只在读协程中访问
+	target     string // 普通文本行的发送目标: 空为世界大厅，"#群组" 或用户名；只在读协程中访问
+	closeOnce  sync.Once
+	mu         sync.Mutex
+	username   string        // 登录成功后的用户名
+	deadline   time.Time     // Hub 设置的读取截止时间
+	timeout    time.Duration // Hub 的读取超时，登录后用于空闲检测
+	tree       protocol.TreePayload
+	treeLoaded bool
+	writeMu    sync.Mutex
+}
+
+// NewConn 创建文本会话连接，closer 关闭底层连接，
+// 如果它实现了 SetReadDeadline 和 SetWriteDeadline 则用于读写超时
+func NewConn(lines Lines, closer io.Closer, remote net.Addr) *Conn {
+	return &Conn{lines: lines, closer: closer, remote: remote}
+}
+
+// SetAuthenticatedUser 设置传输层已认证的用户名，登录时不再询问用户名和密码
+func (c *Conn) SetAuthenticatedUser(name string) {
+	c.user = name
+}
+
+// AuthenticatedUser 实现 core.AuthenticatedConn
+func (c *Conn) AuthenticatedUser() (string, bool) {
+	return c.user, c.user != ""
+}
+
+// ReadMessage 读取输入行，直到得到一条需要交给 Hub 的消息；
+// /help、/who 等只涉及本地状态的命令直接在这里回复
+func (c *Conn) ReadMessage() (*protocol.Message, error) {
+	if !c.loginSent {
+		c.loginSent = true
+		return c.login()
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		message, err := c.parse(line)
+		if err != nil {
+			return nil, err
+		}
+		if message != nil {
+			return message, nil
+		}
+	}
+}
+
+// login 生成登录请求
+func (c *Conn) login() (*protocol.Message, error) {
+	if c.user != "" {
+		return &protocol.Message{Type: protocol.LoginRequest, Sender: c.user}, nil
+	}
+	var username string
+	for username == "" {
+		line, err := c.ask("用户名: ")
+		if err != nil {
+			return nil, err
+		}
+		username = strings.TrimSpace(line)
+	}
+	password, err := c.ask("密码 (没有账号直接回车): ")
+	if err != nil {
+		return nil, err
+	}
+	c.setPrompt("> ")
+	return &protocol.Message{Type: protocol.LoginRequest, Sender: username, TextPayload: strings.TrimSpace(password)}, nil
+}
+
+// WriteMessage 将 Hub 发来的消息格式化为文本行
+func (c *Conn) WriteMessage(message protocol.Message) error {
+	var text string
+	switch message.Type {
+	case protocol.LoginResponse:
+		text = c.onLogin(message)
+	case protocol.TreeUpdate:
+		text = c.onTree(message.TreePayload)
+	default:
+		text = format(message, c.currentUser())
+	}
+	if text == "" {
+		return nil
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := io.WriteString(c.lines, text)
+	return err
+}
+
+func (c *Conn) onLogin(message protocol.Message) string {
+	if message.TextPayload != "" {
+		return "登录失败: " + sanitize(message.TextPayload) + "\n"
+	}
+	c.mu.Lock()
+	c.username = message.Recipient
+	// 读协程可能正以登录前的截止时间等待输入，登录成功后立即改为空闲超时
+	c.applyReadDeadline()
+	c.mu.Unlock()
+	c.setPrompt("> ")
+	return "欢迎, " + message.Recipient + "! 直接输入文字发送到世界大厅，输入 /help 查看命令。\n"
+}
+
+// onTree 保存最新的在线列表，并提示用户上线和下线
+func (c *Conn) onTree(tree protocol.TreePayload) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, loaded := c.tree, c.treeLoaded
+	c.tree, c.treeLoaded = tree, true
+	if !loaded || c.username == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, user := range tree.Users {
+		if user != "" && user != c.username && !slices.Contains(old.Users, user) {
+			b.WriteString("* " + sanitize(user) + " 上线了\n")
+		}
+	}
+	for _, user := range old.Users {
+		if user != "" && user != c.username && !slices.Contains(tree.Users, user) {
+			b.WriteString("* " + sanitize(user) + " 下线了\n")
+		}
+	}
+	return b.String()
+}
+
+// reply 直接向用户输出一段文本
+func (c *Conn) reply(text string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := io.WriteString(c.lines, text)
+	return err
+}
+
+// ask 提示用户输入并读取一行；不支持提示符的会话把问题单独输出为一行
+func (c *Conn) ask(question string) (string, error) {
+	if p, ok := c.lines.(prompter); ok {
+		p.SetPrompt(question)
+	} else if err := c.reply(strings.TrimSpace(question) + "\n"); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+// readLine 读取一行输入
+func (c *Conn) readLine() (string, error) {
+	c.mu.Lock()
+	err := c.applyReadDeadline()
+	c.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return c.lines.ReadLine()
+}
+
+// applyReadDeadline 设置底层连接的读取截止时间，调用时必须持有 mu。
+// 登录必须在 Hub 设置的截止时间内完成；登录成功后每行重新计时，
+// 空闲超时取 Hub 的读取超时和 idleTimeout 中较长的一个
+func (c *Conn) applyReadDeadline() error {
+	d, ok := c.closer.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return nil
+	}
+	deadline := c.deadline
+	if c.username != "" && c.timeout > 0 {
+		deadline = time.Now().Add(max(c.timeout, idleTimeout))
+	}
+	return d.SetReadDeadline(deadline)
+}
+
+func (c *Conn) currentUser() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username
+}
+
+func (c *Conn) setPrompt(prompt string) {
+	if p, ok := c.lines.(prompter); ok {
+		p.SetPrompt(prompt)
+	}
+}
+
+// SetReadDeadline 记录 Hub 设置的读取截止时间，由 readLine 应用到底层连接。
+// SSH 通道不支持读取超时，断开的会话由 SSH 保活发现
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	c.timeout = 0
+	if !t.IsZero() {
+		c.timeout = time.Until(t)
+	}
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.closer.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() { err = c.closer.Close() })
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+var _ core.AuthenticatedConn = (*Conn)(nil)
@@ -0,0 +1,75 @@
+package linechat
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// deadlineCloser 记录最近一次设置的读取截止时间
+type deadlineCloser struct {
+	io.Closer
+	deadline time.Time
+}
+
+func (d *deadlineCloser) SetReadDeadline(t time.Time) error {
+	d.deadline = t
+	return nil
+}
+
+func TestReadDeadline(t *testing.T) {
+	hubTimeout := 10 * time.Second
+	tests := []struct {
+		name     string
+		timeout  time.Duration // Hub 的读取超时
+		loggedIn bool
+		want     time.Duration // 底层连接的截止时间距现在的时长
+	}{
+		{"登录前使用 Hub 的截止时间", hubTimeout, false, hubTimeout},
+		{"登录后使用空闲超时", hubTimeout, true, idleTimeout},
+		{"Hub 超时更长时使用 Hub 超时", 2 * idleTimeout, true, 2 * idleTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closer := &deadlineCloser{Closer: io.NopCloser(nil)}
+			c := NewConn(NewStreamLines(bytes.NewBufferString("hi\n"), 64), closer, nil)
+			c.SetReadDeadline(time.Now().Add(tt.timeout))
+			if tt.loggedIn {
+				c.WriteMessage(protocol.Message{Type: protocol.LoginResponse, Recipient: "alice"})
+			}
+			if _, err := c.readLine(); err != nil {
+				t.Fatal(err)
+			}
+			if got := time.Until(closer.deadline); got > tt.want || got < tt.want-time.Second {
+				t.Fatalf("截止时间为 %v 之后, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginExtendsPendingRead(t *testing.T) {
+	closer := &deadlineCloser{Closer: io.NopCloser(nil)}
+	c := NewConn(NewStreamLines(&bytes.Buffer{}, 64), closer, nil)
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	c.readLine()
+
+	// 读协程已在等待输入时登录成功，截止时间应立即延长
+	c.WriteMessage(protocol.Message{Type: protocol.LoginResponse, Recipient: "alice"})
+	if got := time.Until(closer.deadline); got < idleTimeout-time.Second {
+		t.Fatalf("登录后截止时间为 %v 之后, 期望 %v", got, idleTimeout)
+	}
+}
+
+func TestLoginFailureKeepsDeadline(t *testing.T) {
+	closer := &deadlineCloser{Closer: io.NopCloser(nil)}
+	c := NewConn(NewStreamLines(bytes.NewBufferString("hi\n"), 64), closer, nil)
+	deadline := time.Now().Add(time.Second)
+	c.SetReadDeadline(deadline)
+	c.WriteMessage(protocol.Message{Type: protocol.LoginResponse, TextPayload: "密码错误"})
+	c.readLine()
+	if !closer.deadline.Equal(deadline) {
+		t.Fatalf("登录失败后截止时间 = %v, 期望 %v", closer.deadline, deadline)
+	}
+}
@@ -0,0 +1,49 @@
+package linechat
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrLineTooLong 表示一行输入超过了允许的长度
+var ErrLineTooLong = errors.New("输入行过长")
+
+// streamLines 在普通字节流 (nc、telnet) 上按行读写
+type streamLines struct {
+	reader  *bufio.Reader
+	writer  io.Writer
+	maxLine int
+}
+
+// NewStreamLines 创建基于字节流的文本会话，maxLine 为一行输入的最大字节数
+func NewStreamLines(rw io.ReadWriter, maxLine int) Lines {
+	return &streamLines{reader: bufio.NewReader(rw), writer: rw, maxLine: maxLine}
+}
+
+// ReadLine 读取一行，去掉行尾的 \r\n 或 \n
+func (s *streamLines) ReadLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > s.maxLine {
+			return "", ErrLineTooLong
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (s *streamLines) Write(p []byte) (int, error) {
+	return s.writer.Write(p)
+}
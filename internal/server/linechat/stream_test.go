@@ -0,0 +1,41 @@
+package linechat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamLinesReadLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		maxLine int
+		want    []string
+		wantErr error // 读完 want 之后的错误
+	}{
+		{"换行结尾", "你好\nhi\n", 64, []string{"你好", "hi"}, io.EOF},
+		{"CRLF 结尾", "/who\r\n/quit\r\n", 64, []string{"/who", "/quit"}, io.EOF},
+		{"最后一行没有换行", "a\nb", 64, []string{"a", "b"}, io.EOF},
+		{"空行", "\n\r\n", 64, []string{"", ""}, io.EOF},
+		{"超过缓冲区大小的行", strings.Repeat("x", 5000) + "\n", 8192, []string{strings.Repeat("x", 5000)}, io.EOF},
+		{"行过长", "short\n" + strings.Repeat("x", 100) + "\n", 64, []string{"short"}, ErrLineTooLong},
+		{"没有换行的过长输入", strings.Repeat("x", 100), 64, nil, ErrLineTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := NewStreamLines(bytes.NewBufferString(tt.input), tt.maxLine)
+			for _, want := range tt.want {
+				got, err := lines.ReadLine()
+				if err != nil || got != want {
+					t.Fatalf("ReadLine() = (%.20q, %v), 期望 %.20q", got, err, want)
+				}
+			}
+			if _, err := lines.ReadLine(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadLine() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Package metrics 实现了一个精简的指标库，以 Prometheus 文本格式输出
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Default 是默认的指标注册表，New* 系列函数创建的指标都会注册到这里
+var Default = NewRegistry()
+
+// collector 是能够以文本格式输出自身的指标
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry 保存一组指标
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry 创建一个空的注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic("metrics: 重复注册指标 " + c.name())
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Write 以 Prometheus 文本格式输出所有指标
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	slices.SortFunc(collectors, func(a, b collector) int { return strings.Compare(a.name(), b.name()) })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler 返回输出默认注册表的 HTTP 处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.Write(w)
+	})
+}
+
+// desc 是所有指标共有的名称与说明
+type desc struct {
+	metricName string
+	help       string
+	kind       string
+}
+
+func (d *desc) name() string { return d.metricName }
+
+func (d *desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, d.help, d.metricName, d.kind)
+}
+
+// Counter 是只增不减的计数器
+type Counter struct {
+	desc
+	value atomic.Uint64
+}
+
+// NewCounter 创建并注册一个计数器
+func NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter"}}
+	Default.register(c)
+	return c
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add 计数加 n
+func (c *Counter) Add(n uint64) { c.value.Add(n) }
+
+// Value 返回当前计数
+func (c *Counter) Value() uint64 { return c.value.Load() }
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w)
+	fmt.Fprintf(w, "%s %d\n", c.metricName, c.value.Load())
+}
+
+// CounterVec 是按一个标签区分的一组计数器
+type CounterVec struct {
+	desc
+	label  string
+	mu     sync.Mutex
+	values map[string]*atomic.Uint64
+}
+
+// NewCounterVec 创建并注册一组计数器
+func NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{desc: desc{name, help, "counter"}, label: label, values: make(map[string]*atomic.Uint64)}
+	Default.register(v)
+	return v
+}
+
+// Inc 将标签值对应的计数加一
+func (v *CounterVec) Inc(labelValue string) {
+	v.mu.Lock()
+	value, ok := v.values[labelValue]
+	if !ok {
+		value = new(atomic.Uint64)
+		v.values[labelValue] = value
+	}
+	v.mu.Unlock()
+	value.Add(1)
+}
+
+// Values 返回各标签值当前的计数
+func (v *CounterVec) Values() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	result := make(map[string]uint64, len(v.values))
+	for label, value := range v.values {
+		result[label] = value.Load()
+	}
+	return result
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.writeHeader(w)
+	values := v.Values()
+	labels := make([]string, 0, len(values))
+	for label := range values {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", v.metricName, v.label, quote(label), values[label])
+	}
+}
+
+// Gauge 是可增可减的数值
+type Gauge struct {
+	desc
+	bits atomic.Uint64
+}
+
+// NewGauge 创建并注册一个 Gauge
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name, help, "gauge"}}
+	Default.register(g)
+	return g
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Value 返回当前值
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.Value()))
+}
+
+// Histogram 统计观测值的分布
+type Histogram struct {
+	desc
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram 创建并注册一个直方图，buckets 为升序排列的上界
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, "histogram"},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	counts := slices.Clone(h.counts)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	h.writeHeader(w)
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%s} %d\n", h.metricName, quote(formatFloat(upper)), counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.metricName, count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.metricName, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.metricName, count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// quote 按照文本格式的要求转义标签值
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	pbkdf2Iterations = 210000
+	pbkdf2KeyLength  = 32
+)
+
+var (
+	// ErrAccountExists 表示账号已存在
+	ErrAccountExists = errors.New("账号已存在")
+	// ErrAccountNotFound 表示账号不存在
+	ErrAccountNotFound = errors.New("账号不存在")
+	// ErrBadCredentials 表示用户名或密码错误
+	ErrBadCredentials = errors.New("用户名或密码错误")
+	// ErrAccountRequired 表示服务器只允许已注册的账号登录
+	ErrAccountRequired = errors.New("该服务器只允许已注册的账号登录")
+)
+
+// account 是保存在磁盘上的账号信息，密码使用 PBKDF2-SHA256 加盐哈希
+type account struct {
+	Salt      []byte    `json:"salt"`
+	Hash      []byte    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Accounts 管理用户账号
+type Accounts struct {
+	path           string
+	requireAccount bool
+	mu             sync.RWMutex
+	accounts       map[string]account
+}
+
+// OpenAccounts 从数据目录加载账号，requireAccount 为 true 时未注册的用户名无法登录
+func OpenAccounts(dataDir string, requireAccount bool) (*Accounts, error) {
+	a := &Accounts{
+		path:           filepath.Join(dataDir, "accounts.json"),
+		requireAccount: requireAccount,
+		accounts:       make(map[string]account),
+	}
+	if err := loadJSON(a.path, &a.accounts); err != nil {
+		return nil, fmt.Errorf("读取账号文件失败: %w", err)
+	}
+	return a, nil
+}
+
+// List 返回所有账号名，按字母顺序排列
+func (a *Accounts) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Sorted(maps.Keys(a.accounts))
+}
+
+// Add 创建新账号
+func (a *Accounts) Add(username, password string) error {
+	if username == "" || password == "" {
+		return errors.New("用户名和密码不能为空")
+	}
+	acc, err := newAccount(password)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.accounts[username]; ok {
+		return ErrAccountExists
+	}
+	a.accounts[username] = acc
+	return a.saveLocked()
+}
+
+// SetPassword 修改已有账号的密码
+func (a *Accounts) SetPassword(username, password string) error {
+	if password == "" {
+		return errors.New("密码不能为空")
+	}
+	acc, err := newAccount(password)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, ok := a.accounts[username]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	acc.CreatedAt = old.CreatedAt
+	a.accounts[username] = acc
+	return a.saveLocked()
+}
+
+// Remove 删除账号
+func (a *Accounts) Remove(username string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.accounts[username]; !ok {
+		return ErrAccountNotFound
+	}
+	delete(a.accounts, username)
+	return a.saveLocked()
+}
+
+// Authenticate 校验登录凭据：已注册的用户名必须提供正确的密码，
+// 未注册的用户名只有在不要求账号时才允许登录
+func (a *Accounts) Authenticate(username, password string) error {
+	a.mu.RLock()
+	acc, ok := a.accounts[username]
+	a.mu.RUnlock()
+
+	if !ok {
+		if a.requireAccount {
+			return ErrAccountRequired
+		}
+		return nil
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, acc.Salt, pbkdf2Iterations, pbkdf2KeyLength)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(hash, acc.Hash) != 1 {
+		return ErrBadCredentials
+	}
+	return nil
+}
+
+func (a *Accounts) saveLocked() error {
+	return saveJSON(a.path, a.accounts)
+}
+
+func newAccount(password string) (account, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return account{}, err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, pbkdf2KeyLength)
+	if err != nil {
+		return account{}, err
+	}
+	return account{Salt: salt, Hash: hash, CreatedAt: time.Now()}, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// BanList 是通过管理接口添加的封禁列表，与配置文件中的封禁合并生效
+type BanList struct {
+	Users []string `json:"users"`
+	IPs   []string `json:"ips"`
+}
+
+// Bans 管理运行时添加的封禁
+type Bans struct {
+	path string
+	mu   sync.RWMutex
+	list BanList
+}
+
+// OpenBans 从数据目录加载封禁列表
+func OpenBans(dataDir string) (*Bans, error) {
+	b := &Bans{path: filepath.Join(dataDir, "bans.json")}
+	if err := loadJSON(b.path, &b.list); err != nil {
+		return nil, fmt.Errorf("读取封禁文件失败: %w", err)
+	}
+	return b, nil
+}
+
+// List 返回当前封禁列表的副本
+func (b *Bans) List() BanList {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return BanList{Users: slices.Clone(b.list.Users), IPs: slices.Clone(b.list.IPs)}
+}
+
+// BanUser 封禁用户名，返回是否为新增
+func (b *Bans) BanUser(username string) (bool, error) {
+	return b.update(&b.list.Users, username, true)
+}
+
+// UnbanUser 解除用户名封禁，返回该用户名之前是否被封禁
+func (b *Bans) UnbanUser(username string) (bool, error) {
+	return b.update(&b.list.Users, username, false)
+}
+
+// BanIP 封禁 IP 或网段，调用方负责校验格式
+func (b *Bans) BanIP(ip string) (bool, error) {
+	return b.update(&b.list.IPs, ip, true)
+}
+
+// UnbanIP 解除 IP 或网段封禁
+func (b *Bans) UnbanIP(ip string) (bool, error) {
+	return b.update(&b.list.IPs, ip, false)
+}
+
+func (b *Bans) update(list *[]string, value string, add bool) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := slices.Index(*list, value)
+	switch {
+	case add && i < 0:
+		*list = append(*list, value)
+	case !add && i >= 0:
+		*list = slices.Delete(*list, i, i+1)
+	default:
+		return false, nil
+	}
+	return true, saveJSON(b.path, b.list)
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Groups 记录持久群组的名称，持久群组在成员为空时不会被销毁，重启后也会被重新创建
+type Groups struct {
+	path  string
+	mu    sync.RWMutex
+	names []string
+}
+
+// OpenGroups 从数据目录加载持久群组列表
+func OpenGroups(dataDir string) (*Groups, error) {
+	g := &Groups{path: filepath.Join(dataDir, "groups.json")}
+	if err := loadJSON(g.path, &g.names); err != nil {
+		return nil, fmt.Errorf("读取群组文件失败: %w", err)
+	}
+	return g, nil
+}
+
+// List 返回所有持久群组
+func (g *Groups) List() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return slices.Clone(g.names)
+}
+
+// Add 记录一个持久群组，返回是否为新增
+func (g *Groups) Add(name string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if slices.Contains(g.names, name) {
+		return false, nil
+	}
+	g.names = append(g.names, name)
+	return true, saveJSON(g.path, g.names)
+}
+
+// Remove 删除一个持久群组记录，返回该群组之前是否存在
+func (g *Groups) Remove(name string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	i := slices.Index(g.names, name)
+	if i < 0 {
+		return false, nil
+	}
+	g.names = slices.Delete(g.names, i, i+1)
+	return true, saveJSON(g.path, g.names)
+}
@@ -0,0 +1,239 @@
+package transport
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/linechat"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// sshUserExtension 在 ssh.Permissions 中保存认证得到的聊天用户名
+const sshUserExtension = "gochat-user"
+
+// sshHandshakeTimeout 是完成 SSH 握手和认证的时限
+const sshHandshakeTimeout = 30 * time.Second
+
+// SSHOptions 是 SSH 前端的认证配置
+type SSHOptions struct {
+	HostKeyFile string // 主机私钥，不存在时自动生成 Ed25519 密钥
+
+	// AuthorizedKeysFile 是 authorized_keys 格式的公钥列表，每个公钥的注释即为聊天用户名。
+	// 每次认证时重新读取，修改后无需重启
+	AuthorizedKeysFile string
+
+	// Password 不为空时允许使用密码登录，用户名为 SSH 登录名
+	Password func(username, password string) error
+}
+
+// SSHServer 接受 SSH 连接，用户认证后进入基于行的聊天界面，与其它客户端共用同一个 Hub
+type SSHServer struct {
+	Network string
+	Address string
+	hub     *core.Hub
+	config  *ssh.ServerConfig
+}
+
+// NewSSHServer 创建 SSH 服务器并加载主机密钥
+func NewSSHServer(network, address string, hub *core.Hub, opts SSHOptions) (*SSHServer, error) {
+	hostKey, err := LoadOrCreateHostKey(opts.HostKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载 SSH 主机密钥失败: %w", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			name, err := authorizedUser(opts.AuthorizedKeysFile, key)
+			if err != nil {
+				slog.Info("SSH 公钥认证失败", "remote_addr", meta.RemoteAddr().String(),
+					"ssh_user", meta.User(), "key", ssh.FingerprintSHA256(key), "error", err)
+				return nil, err
+			}
+			return &ssh.Permissions{Extensions: map[string]string{sshUserExtension: name}}, nil
+		},
+	}
+	if opts.Password != nil {
+		config.PasswordCallback = func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if err := opts.Password(meta.User(), string(password)); err != nil {
+				slog.Info("SSH 密码认证失败", "remote_addr", meta.RemoteAddr().String(), "ssh_user", meta.User(), "error", err)
+				return nil, err
+			}
+			return &ssh.Permissions{Extensions: map[string]string{sshUserExtension: meta.User()}}, nil
+		}
+	}
+	config.AddHostKey(hostKey)
+	slog.Info("SSH 主机密钥已加载", "fingerprint", ssh.FingerprintSHA256(hostKey.PublicKey()))
+
+	return &SSHServer{Network: network, Address: address, hub: hub, config: config}, nil
+}
+
+// Start 启动 SSH 服务器
+func (s *SSHServer) Start() error {
+	listener, err := Listen(s.Network, s.Address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	slog.Info("SSH 服务器已启动", "network", s.Network, "address", listener.Addr().String())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			slog.Error("接受 SSH 连接失败", "error", err)
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle 完成 SSH 握手，为每个 session 通道启动一个聊天会话
+func (s *SSHServer) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(sshHandshakeTimeout))
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		slog.Debug("SSH 握手失败", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	go ssh.DiscardRequests(requests)
+
+	username := sshConn.Permissions.Extensions[sshUserExtension]
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "只支持 session 通道")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			slog.Debug("接受 SSH 通道失败", "error", err)
+			continue
+		}
+		go s.session(sshConn, username, channel, requests)
+	}
+}
+
+// session 处理终端相关的请求，收到 shell 请求后开始聊天
+func (s *SSHServer) session(sshConn *ssh.ServerConn, username string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	terminal := term.NewTerminal(channel, "> ")
+	started := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term          string
+				Columns, Rows uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if ssh.Unmarshal(req.Payload, &pty) == nil {
+				terminal.SetSize(int(pty.Columns), int(pty.Rows))
+			}
+			req.Reply(true, nil)
+		case "window-change":
+			var size struct {
+				Columns, Rows uint32
+				Width, Height uint32
+			}
+			if ssh.Unmarshal(req.Payload, &size) == nil {
+				terminal.SetSize(int(size.Columns), int(size.Rows))
+			}
+		case "shell":
+			req.Reply(!started, nil)
+			if started {
+				continue
+			}
+			started = true
+			conn := linechat.NewConn(terminal, sshSession{channel}, sshConn.RemoteAddr())
+			conn.SetAuthenticatedUser(username)
+			client := core.NewClient(s.hub, conn)
+			s.hub.Register <- client
+			go client.WritePump()
+			go client.ReadPump()
+		default:
+			// 不支持 exec、subsystem 等请求
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+	channel.Close()
+}
+
+// sshSession 在关闭通道前发送退出状态，使 ssh 客户端正常退出
+type sshSession struct {
+	ssh.Channel
+}
+
+func (s sshSession) Close() error {
+	s.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+	return s.Channel.Close()
+}
+
+// authorizedUser 在 authorized_keys 文件中查找公钥，返回其注释中的用户名
+func authorizedUser(path string, key ssh.PublicKey) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取 authorized_keys 失败: %w", err)
+	}
+	marshaled := key.Marshal()
+	// 逐行解析，一行格式错误不影响其它公钥
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		authorized, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+		if err != nil {
+			slog.Warn("跳过 authorized_keys 中无法解析的行", "path", path, "line", i+1, "error", err)
+			continue
+		}
+		if bytes.Equal(authorized.Marshal(), marshaled) {
+			if comment == "" {
+				return "", errors.New("公钥没有注释，无法确定用户名")
+			}
+			return comment, nil
+		}
+	}
+	return "", errors.New("未授权的公钥")
+}
+
+// LoadOrCreateHostKey 读取 PEM 格式的 SSH 主机私钥，文件不存在时生成新的 Ed25519 密钥
+func LoadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	slog.Info("已生成 SSH 主机密钥", "path", path)
+	return ssh.NewSignerFromKey(key)
+}
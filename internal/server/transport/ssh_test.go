@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// authorizedLine 返回 authorized_keys 中的一行
+func authorizedLine(key ssh.PublicKey, comment string) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " " + comment
+}
+
+func TestAuthorizedUser(t *testing.T) {
+	alice, bob, carol, anonymous, stranger := newPublicKey(t), newPublicKey(t), newPublicKey(t), newPublicKey(t), newPublicKey(t)
+	lines := []string{
+		"# GoChat 用户",
+		authorizedLine(alice, "alice"),
+		"",
+		"ssh-ed25519 不是公钥 mallory",
+		"这一行格式错误",
+		authorizedLine(bob, "bob"),
+		`no-port-forwarding,from="10.0.0.0/8" ` + authorizedLine(carol, "carol"),
+		strings.TrimSpace(string(ssh.MarshalAuthorizedKey(anonymous))),
+	}
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     ssh.PublicKey
+		want    string
+		wantErr string
+	}{
+		{"第一个公钥", alice, "alice", ""},
+		{"格式错误的行之后的公钥", bob, "bob", ""},
+		{"带选项的公钥", carol, "carol", ""},
+		{"没有注释", anonymous, "", "公钥没有注释"},
+		{"未授权的公钥", stranger, "", "未授权的公钥"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorizedUser(path, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("authorizedUser() = (%q, %v), 期望错误 %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("authorizedUser() = (%q, %v), 期望 %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizedUserMissingFile(t *testing.T) {
+	_, err := authorizedUser(filepath.Join(t.TempDir(), "authorized_keys"), newPublicKey(t))
+	if err == nil || !strings.Contains(err.Error(), "读取 authorized_keys 失败") {
+		t.Fatalf("authorizedUser() 错误 = %v", err)
+	}
+}
+
+func TestLoadOrCreateHostKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "host_key")
+	created, err := LoadOrCreateHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(created.PublicKey().Marshal()) != string(loaded.PublicKey().Marshal()) {
+		t.Fatal("重新加载的主机密钥与生成的不同")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Fatalf("主机密钥权限过宽: %v %v", info.Mode(), err)
+	}
+}
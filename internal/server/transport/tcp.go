@@ -2,42 +2,103 @@ package transport
 
 import (
 	"GoChat/internal/server/core"
+	"GoChat/internal/server/irc"
+	"GoChat/internal/server/linechat"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"net"
+	"os"
 )
 
 type Server struct {
-	Address string    // 监听地址
-	Port    int       // 监听端口
-	hub     *core.Hub // 指向中心枢纽的指针
+	Network   string      // tcp、tcp4、tcp6 或 unix
+	Address   string      // 监听地址，unix 为套接字文件路径
+	Protocol  string      // gochat (默认，长度前缀的数据帧)、text (纯文本行) 或 irc
+	TLSConfig *tls.Config // 不为空时只接受 TLS 连接
+	hub       *core.Hub   // 指向中心枢纽的指针
 }
 
-func NewServer(address string, port int, hub *core.Hub) *Server {
+func NewServer(network, address string, hub *core.Hub) *Server {
 	return &Server{
+		Network: network,
 		Address: address,
-		Port:    port,
 		hub:     hub,
 	}
 }
 
-// Start 启动 TCP 服务器
+// Start 启动服务器
 func (s *Server) Start() error {
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.Address, s.Port))
+	listener, err := Listen(s.Network, s.Address)
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
 
-	fmt.Printf("服务器已启动，监听地址: %s:%d\n", s.Address, s.Port)
+	if s.TLSConfig != nil {
+		listener = tls.NewListener(listener, s.TLSConfig)
+	}
+	slog.Info("服务器已启动", "network", s.Network, "address", listener.Addr().String(),
+		"protocol", s.protocol(), "tls", s.TLSConfig != nil)
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			fmt.Printf("接受连接失败: %v\n", err)
+			slog.Error("接受连接失败", "error", err)
 			continue
 		}
-		client := core.NewClient(s.hub, conn)
+		client := core.NewClient(s.hub, s.newConn(conn))
 		s.hub.Register <- client
 		go client.Start()
 	}
 }
+
+func (s *Server) protocol() string {
+	if s.Protocol == "" {
+		return "gochat"
+	}
+	return s.Protocol
+}
+
+// newConn 按监听端点的协议包装连接
+func (s *Server) newConn(conn net.Conn) core.Conn {
+	maxFrameSize := s.hub.Options().MaxFrameSize
+	switch s.Protocol {
+	case "text":
+		return linechat.NewConn(linechat.NewStreamLines(conn, maxFrameSize), conn, conn.RemoteAddr())
+	case "irc":
+		return irc.NewConn(conn, maxFrameSize)
+	default:
+		return core.NewStreamConn(conn, maxFrameSize)
+	}
+}
+
+// Listen 在指定网络上监听。对于 Unix 套接字，先删除上次运行遗留的套接字文件，
+// 并只允许同一用户和用户组连接
+func Listen(network, address string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+	if info, err := os.Lstat(address); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if conn, err := net.Dial(network, address); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s 已被其它程序监听", address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, err
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCA 是测试用的客户端证书 CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue 签发以 cn 为 Subject CN 的客户端证书
+func (ca *testCA) issue(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServerTLSConfigClientAuth(t *testing.T) {
+	certPEM, keyPEM, err := generateCertificate([]string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+
+	ca := newTestCA(t, "GoChat CA")
+	other := newTestCA(t, "Other CA")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	tests := []struct {
+		name      string
+		clientCAs *x509.CertPool
+		certs     []tls.Certificate
+		wantCN    string // 服务器校验通过的证书 CN
+		wantErr   bool
+	}{
+		{"未启用双向 TLS", nil, nil, "", false},
+		{"CA 签发的证书", clientCAs, []tls.Certificate{ca.issue(t, "alice")}, "alice", false},
+		{"没有客户端证书", clientCAs, nil, "", true},
+		{"其它 CA 签发的证书", clientCAs, []tls.Certificate{other.issue(t, "alice")}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverSide, clientSide := net.Pipe()
+			defer serverSide.Close()
+			defer clientSide.Close()
+
+			server := tls.Server(serverSide, ServerTLSConfig(serverCert, tt.clientCAs))
+			client := tls.Client(clientSide, &tls.Config{ServerName: "localhost", RootCAs: roots, Certificates: tt.certs})
+			clientErr := make(chan error, 1)
+			go func() {
+				err := client.Handshake()
+				if err == nil {
+					// TLS 1.3 中服务器在客户端完成握手后才校验证书，读取一次以得到服务器的结果
+					client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+					client.Read(make([]byte, 1))
+				}
+				clientErr <- err
+			}()
+
+			err := server.Handshake()
+			<-clientErr
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("握手成功，期望服务器拒绝")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("握手失败: %v", err)
+			}
+			state := server.ConnectionState()
+			var cn string
+			if len(state.VerifiedChains) > 0 {
+				cn = state.VerifiedChains[0][0].Subject.CommonName
+			}
+			if cn != tt.wantCN {
+				t.Fatalf("证书 CN = %q, 期望 %q", cn, tt.wantCN)
+			}
+		})
+	}
+}
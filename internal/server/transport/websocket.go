@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/pkg/protocol"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketHandler 返回接受 WebSocket 连接的 HTTP 处理器。
+// 每个文本帧承载一条 JSON 编码的 protocol.Message，与 TCP 连接共用同一个 Hub。
+// allowedOrigins 为空时只允许与请求的 Host 相同的网页来源
+func WebSocketHandler(hub *core.Hub, allowedOrigins []string) http.Handler {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			return checkOrigin(req, allowedOrigins)
+		},
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = hub.Options().MaxFrameSize
+			client := core.NewClient(hub, newWSConn(ws))
+			hub.Register <- client
+			// 处理器返回后连接即被关闭，因此在当前协程中运行读循环
+			go client.WritePump()
+			client.ReadPump()
+		},
+	}
+}
+
+// checkOrigin 拒绝来自其它网站的浏览器连接，防止跨站 WebSocket 劫持
+func checkOrigin(req *http.Request, allowed []string) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil // 非浏览器客户端
+	}
+	if len(allowed) > 0 {
+		if slices.Contains(allowed, origin) {
+			return nil
+		}
+	} else if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	slog.Warn("拒绝来源不受信任的 WebSocket 连接", "origin", origin, "remote_addr", req.RemoteAddr)
+	return fmt.Errorf("origin %q 不被允许", origin)
+}
+
+// wsConn 将 WebSocket 连接适配为 core.Conn
+type wsConn struct {
+	ws     *websocket.Conn
+	remote net.Addr
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	// websocket.Conn.RemoteAddr 在服务端返回的是 Origin，这里使用 HTTP 请求的对端地址
+	var remote net.Addr = &net.TCPAddr{}
+	if addr, err := netip.ParseAddrPort(ws.Request().RemoteAddr); err == nil {
+		remote = net.TCPAddrFromAddrPort(addr)
+	}
+	return &wsConn{ws: ws, remote: remote}
+}
+
+func (c *wsConn) ReadMessage() (*protocol.Message, error) {
+	var data []byte
+	if err := websocket.Message.Receive(c.ws, &data); err != nil {
+		if errors.Is(err, websocket.ErrFrameTooLarge) {
+			return nil, protocol.ErrFrameTooLarge
+		}
+		return nil, err
+	}
+	core.CountReceived(len(data))
+	var message protocol.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *wsConn) WriteMessage(message protocol.Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if err := websocket.Message.Send(c.ws, string(data)); err != nil {
+		return err
+	}
+	core.CountSent(len(data))
+	return nil
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *wsConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
+func (c *wsConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *wsConn) Close() error                       { return c.ws.Close() }
+
+func (c *wsConn) TLSState() (tls.ConnectionState, bool) {
+	if state := c.ws.Request().TLS; state != nil {
+		return *state, true
+	}
+	return tls.ConnectionState{}, false
+}
@@ -0,0 +1,394 @@
+// Go Chat 网页客户端：每个 WebSocket 文本帧是一条 JSON 编码的 protocol.Message
+"use strict";
+
+const LOBBY = "世界大厅";
+
+const T = {
+  login: "cmd_login",
+  createGroup: "cmd_create_group",
+  joinGroup: "cmd_join_group",
+  leaveGroup: "cmd_leave_group",
+  loginResponse: "data_login",
+  treeUpdate: "data_tree_update",
+  broadcast: "msg_broadcast",
+  private: "msg_private",
+  group: "msg_group",
+  privateFile: "file_private",
+  groupFile: "file_group",
+  system: "msg_system",
+};
+
+const $ = (id) => document.getElementById(id);
+const meta = (name) => document.querySelector(`meta[name="${name}"]`).content;
+
+const state = {
+  ws: null,
+  username: "",
+  loggedIn: false,
+  loginError: "",
+  users: [],
+  groups: {},                 // 群组名 -> 成员列表
+  encryptedGroups: new Set(),
+  bots: new Set(),            // 服务器运行的机器人
+  conversations: new Map(),   // 会话名 -> { kind, messages, unread }
+  current: LOBBY,
+};
+
+// ---------- 连接 ----------
+
+function connect(username, password) {
+  const url = new URL(meta("gochat-ws-path"), location.href);
+  url.protocol = location.protocol === "https:" ? "wss:" : "ws:";
+
+  state.username = username;
+  state.loggedIn = false;
+  state.loginError = "";
+  setStatus("正在连接...");
+
+  const ws = new WebSocket(url);
+  state.ws = ws;
+  ws.onopen = () => send({ type: T.login, sender: username, text_payload: password });
+  ws.onmessage = (event) => handleMessage(JSON.parse(event.data));
+  ws.onclose = () => {
+    if (state.ws !== ws) {
+      return;
+    }
+    state.ws = null;
+    let reason = "已与服务器断开连接";
+    if (state.loginError) {
+      reason = "登录失败: " + state.loginError;
+    } else if (!state.loggedIn) {
+      reason = "无法连接到服务器";
+    }
+    showLogin(reason);
+  };
+}
+
+function send(message) {
+  if (state.ws && state.ws.readyState === WebSocket.OPEN) {
+    state.ws.send(JSON.stringify(message));
+  }
+}
+
+function handleMessage(msg) {
+  switch (msg.type) {
+    case T.loginResponse:
+      if (msg.text_payload) {
+        state.loginError = msg.text_payload;
+        return;
+      }
+      state.username = msg.recipient || state.username;
+      state.loggedIn = true;
+      showChat();
+      break;
+    case T.treeUpdate:
+      updateTree(msg.tree_payload || {});
+      break;
+    case T.broadcast:
+    case T.system:
+      addMessage(LOBBY, "lobby", msg);
+      break;
+    case T.group:
+    case T.groupFile:
+      addMessage(msg.groupname, "group", msg);
+      break;
+    case T.private:
+    case T.privateFile:
+      addMessage(msg.sender === state.username ? msg.recipient : msg.sender, "private", msg);
+      break;
+  }
+}
+
+// ---------- 状态 ----------
+
+function conversation(name, kind) {
+  let conv = state.conversations.get(name);
+  if (!conv) {
+    conv = { kind, messages: [], unread: false };
+    state.conversations.set(name, conv);
+  }
+  return conv;
+}
+
+function addMessage(name, kind, msg) {
+  const conv = conversation(name, kind);
+  conv.messages.push(msg);
+  if (name === state.current) {
+    appendMessage(msg);
+  } else {
+    conv.unread = true;
+  }
+  renderSidebar();
+}
+
+function updateTree(tree) {
+  state.users = (tree.users || []).filter((u) => u && u !== state.username).sort();
+  state.groups = tree.groups || {};
+  state.encryptedGroups = new Set(tree.encrypted_groups || []);
+  state.bots = new Set(tree.bots || []);
+  renderSidebar();
+  renderHeader();
+}
+
+function isMember(group) {
+  return (state.groups[group] || []).includes(state.username);
+}
+
+function openConversation(name, kind) {
+  const conv = conversation(name, kind);
+  conv.unread = false;
+  state.current = name;
+  renderSidebar();
+  renderHeader();
+  renderMessages();
+  $("text").focus();
+}
+
+// ---------- 界面 ----------
+
+function setStatus(text) {
+  $("login-status").textContent = text;
+}
+
+function showLogin(reason) {
+  $("chat-view").hidden = true;
+  $("login-view").hidden = false;
+  setStatus(reason || "");
+}
+
+function showChat() {
+  state.conversations.clear();
+  conversation(LOBBY, "lobby");
+  state.current = LOBBY;
+  $("me").textContent = state.username;
+  $("login-view").hidden = true;
+  $("chat-view").hidden = false;
+  openConversation(LOBBY, "lobby");
+}
+
+function listItem(text, onClick, classes) {
+  const li = document.createElement("li");
+  li.textContent = text;
+  li.title = text;
+  li.onclick = onClick;
+  for (const [name, on] of Object.entries(classes || {})) {
+    li.classList.toggle(name, !!on);
+  }
+  return li;
+}
+
+function renderSidebar() {
+  const convs = $("conversations");
+  convs.replaceChildren();
+  for (const [name, conv] of state.conversations) {
+    convs.append(listItem(name, () => openConversation(name, conv.kind), {
+      active: name === state.current,
+      unread: conv.unread,
+    }));
+  }
+
+  const groups = $("groups");
+  groups.replaceChildren();
+  for (const name of Object.keys(state.groups).sort()) {
+    groups.append(listItem(name, () => openGroup(name), {
+      member: isMember(name),
+      locked: state.encryptedGroups.has(name),
+    }));
+  }
+
+  const users = $("users");
+  users.replaceChildren();
+  for (const name of state.users) {
+    users.append(listItem(name, () => openConversation(name, "private"), {
+      bot: state.bots.has(name),
+    }));
+  }
+}
+
+function renderHeader() {
+  const conv = state.conversations.get(state.current);
+  if (!conv) {
+    return;
+  }
+  const name = state.current;
+  const group = conv.kind === "group";
+  const encrypted = group && state.encryptedGroups.has(name);
+  const canSend = !encrypted && (!group || isMember(name));
+
+  $("title").textContent = conv.kind === "private" ? "与 " + name + " 私聊" : name;
+  $("leave-group").hidden = !group || !isMember(name);
+  $("text").disabled = !canSend;
+  $("text").placeholder = encrypted
+    ? "该群组已启用端到端加密，网页客户端无法收发消息"
+    : canSend ? "输入消息..." : "加入群组后才能发送消息";
+  $("file-button").classList.toggle("disabled", !canSend || conv.kind === "lobby");
+}
+
+function renderMessages() {
+  $("messages").replaceChildren();
+  const conv = state.conversations.get(state.current);
+  for (const msg of conv ? conv.messages : []) {
+    appendMessage(msg);
+  }
+}
+
+function appendMessage(msg) {
+  const list = $("messages");
+  const li = document.createElement("li");
+
+  const time = document.createElement("span");
+  time.className = "time";
+  time.textContent = "[" + new Date(msg.timestamp).toLocaleTimeString() + "] ";
+  li.append(time);
+
+  if (msg.type === T.system || !msg.sender) {
+    li.classList.add("system");
+    li.append(msg.text_payload || "");
+  } else {
+    const sender = document.createElement("span");
+    sender.className = "sender";
+    sender.textContent = msg.sender + ": ";
+    li.append(sender);
+    if (msg.encrypted) {
+      const notice = document.createElement("span");
+      notice.className = "notice";
+      notice.textContent = "(端到端加密消息，网页客户端无法解密)";
+      li.append(notice);
+    } else if (msg.type === T.privateFile || msg.type === T.groupFile) {
+      li.classList.add("file");
+      li.append(fileLink(msg.file_payload));
+    } else {
+      li.append(msg.text_payload || "");
+    }
+  }
+
+  const atBottom = list.scrollHeight - list.scrollTop - list.clientHeight < 40;
+  list.append(li);
+  if (atBottom) {
+    list.scrollTop = list.scrollHeight;
+  }
+}
+
+function fileLink(file) {
+  const a = document.createElement("a");
+  a.href = "#";
+  a.textContent = `${file.name} (${formatSize(file.size)})`;
+  a.onclick = (event) => {
+    event.preventDefault();
+    const bytes = Uint8Array.from(atob(file.data || ""), (c) => c.charCodeAt(0));
+    const url = URL.createObjectURL(new Blob([bytes]));
+    const download = document.createElement("a");
+    download.href = url;
+    download.download = file.name;
+    download.click();
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+  };
+  return a;
+}
+
+function formatSize(size) {
+  if (size < 1024) {
+    return size + " B";
+  }
+  if (size < 1024 * 1024) {
+    return (size / 1024).toFixed(2) + " KB";
+  }
+  return (size / 1024 / 1024).toFixed(2) + " MB";
+}
+
+// ---------- 操作 ----------
+
+function openGroup(name) {
+  if (!isMember(name)) {
+    if (state.encryptedGroups.has(name)) {
+      alert("群组 " + name + " 已启用端到端加密，请使用桌面客户端加入。");
+      return;
+    }
+    if (!confirm("加入群组 " + name + "？")) {
+      return;
+    }
+    send({ type: T.joinGroup, groupname: name });
+  }
+  openConversation(name, "group");
+}
+
+function sendText(text) {
+  const conv = state.conversations.get(state.current);
+  switch (conv.kind) {
+    case "lobby":
+      send({ type: T.broadcast, text_payload: text });
+      break;
+    case "group":
+      send({ type: T.group, groupname: state.current, text_payload: text });
+      break;
+    case "private":
+      send({ type: T.private, recipient: state.current, text_payload: text });
+      break;
+  }
+}
+
+function sendFile(file) {
+  const conv = state.conversations.get(state.current);
+  // base64 编码后约为原大小的 4/3，另外预留消息其它字段的空间
+  const maxSize = Math.floor((Number(meta("gochat-max-frame-size")) - 4096) * 3 / 4);
+  if (file.size > maxSize) {
+    alert(`文件过大，最大只能发送 ${formatSize(maxSize)}`);
+    return;
+  }
+  const reader = new FileReader();
+  reader.onload = () => {
+    const data = reader.result.slice(reader.result.indexOf(",") + 1);
+    const payload = { name: file.name, size: file.size, data };
+    if (conv.kind === "group") {
+      send({ type: T.groupFile, groupname: state.current, file_payload: payload });
+    } else {
+      send({ type: T.privateFile, recipient: state.current, file_payload: payload });
+    }
+  };
+  reader.readAsDataURL(file);
+}
+
+$("login-form").onsubmit = (event) => {
+  event.preventDefault();
+  const username = $("login-username").value.trim();
+  if (username) {
+    connect(username, $("login-password").value);
+  }
+};
+
+$("logout").onclick = () => {
+  if (state.ws) {
+    state.ws.close();
+  }
+};
+
+$("create-group").onclick = () => {
+  const name = (prompt("群组名") || "").trim();
+  if (name) {
+    send({ type: T.createGroup, text_payload: name });
+    openConversation(name, "group");
+  }
+};
+
+$("leave-group").onclick = () => {
+  send({ type: T.leaveGroup, groupname: state.current });
+  state.conversations.delete(state.current);
+  openConversation(LOBBY, "lobby");
+};
+
+$("send-form").onsubmit = (event) => {
+  event.preventDefault();
+  const text = $("text").value;
+  if (text.trim() && !$("text").disabled) {
+    sendText(text);
+    $("text").value = "";
+  }
+};
+
+$("file").onchange = () => {
+  const file = $("file").files[0];
+  $("file").value = "";
+  if (file) {
+    sendFile(file);
+  }
+};
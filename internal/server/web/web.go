@@ -0,0 +1,42 @@
+// Package web 提供内置的浏览器客户端，页面通过 WebSocket 连接服务器
+package web
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+//go:embed static
+var static embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(static, "static/index.html"))
+
+// Options 是网页客户端需要知道的服务器参数
+type Options struct {
+	WebSocketPath string // WebSocket 路径，与页面位于同一地址
+	MaxFrameSize  int    // 单条消息的最大字节数，用于在发送文件前检查大小
+}
+
+// Handler 返回网页客户端的 HTTP 处理器，应注册在 "/" 上
+func Handler(opts Options) http.Handler {
+	var index bytes.Buffer
+	if err := indexTemplate.Execute(&index, opts); err != nil {
+		panic(err)
+	}
+	modTime := time.Now()
+	assets, _ := fs.Sub(static, "static")
+	files := http.FileServerFS(assets)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			http.ServeContent(w, r, "index.html", modTime, bytes.NewReader(index.Bytes()))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/pkg/protocol"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// maxIncomingBody 是接收的请求体的最大字节数
+const maxIncomingBody = 64 << 10
+
+// IncomingHook 描述一个接收外部消息的 Webhook
+type IncomingHook struct {
+	Name  string // 请求路径中的名称
+	Token string // 访问令牌
+	Bot   string // 发言使用的机器人用户名
+	Group string // 目标群组，为空时发到世界大厅
+
+	// Template 是 text/template 模板，数据为请求体中的 JSON 对象，如 "[{{.status}}] {{.pipeline}} {{.url}}"。
+	// 为空时使用请求体的 text 字段，有 title 字段时作为第一行
+	Template string
+}
+
+// Incoming 是接收外部消息的 HTTP 处理器，请求路径即 Webhook 的名称，
+// 挂载在前缀下时需要配合 http.StripPrefix 使用:
+//
+//	POST /hooks/<name>
+//	Authorization: Bearer <token>
+//	{"title": "构建失败", "text": "main 分支 #42"}
+type Incoming struct {
+	hooks map[string]*incomingHook
+}
+
+type incomingHook struct {
+	IncomingHook
+	template *template.Template
+	session  *core.BotSession
+}
+
+// NewIncoming 解析消息模板并让 Webhook 使用的机器人登录到 Hub，机器人启动后加入目标群组
+func NewIncoming(hub *core.Hub, hooks []IncomingHook) (*Incoming, error) {
+	in := &Incoming{hooks: make(map[string]*incomingHook)}
+	posters := make(map[string]*poster)
+	for _, h := range hooks {
+		ih := &incomingHook{IncomingHook: h}
+		if h.Template != "" {
+			tmpl, err := template.New(h.Name).Option("missingkey=zero").Parse(h.Template)
+			if err != nil {
+				return nil, fmt.Errorf("Webhook %s 的消息模板无效: %w", h.Name, err)
+			}
+			ih.template = tmpl
+		}
+		p, ok := posters[h.Bot]
+		if !ok {
+			p = &poster{name: h.Bot}
+			posters[h.Bot] = p
+		}
+		if h.Group != "" && !slices.Contains(p.groups, h.Group) {
+			p.groups = append(p.groups, h.Group)
+		}
+		in.hooks[h.Name] = ih
+	}
+
+	sessions := make(map[string]*core.BotSession)
+	for name, p := range posters {
+		session, err := hub.RegisterBot(p)
+		if err != nil {
+			return nil, err
+		}
+		sessions[name] = session
+	}
+	for _, ih := range in.hooks {
+		ih.session = sessions[ih.Bot]
+	}
+	return in, nil
+}
+
+func (in *Incoming) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path
+	h, ok := in.hooks[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Webhook 不存在")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "只支持 POST 请求")
+		return
+	}
+	if !h.authorized(r) {
+		slog.Warn("Webhook 认证失败", "webhook", name, "remote_addr", r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	var data map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIncomingBody)).Decode(&data); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体不是有效的 JSON 对象")
+		return
+	}
+	text, err := h.format(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.session.Connected() {
+		writeError(w, http.StatusServiceUnavailable, "机器人已断开，需要重启服务器")
+		return
+	}
+
+	if h.Group != "" {
+		h.session.SendGroup(h.Group, text)
+	} else {
+		h.session.SendBroadcast(text)
+	}
+	slog.Info("收到 Webhook 消息", "webhook", name, "bot", h.Bot, "group", h.Group, "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authorized 以常量时间比较 Authorization 头中的令牌。不接受查询参数中的令牌，
+// URL 常被记录在代理日志和 CI 输出中
+func (h *incomingHook) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+// format 按模板生成消息内容
+func (h *incomingHook) format(data map[string]any) (string, error) {
+	var text string
+	if h.template != nil {
+		var b strings.Builder
+		if err := h.template.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("生成消息失败: %w", err)
+		}
+		text = b.String()
+	} else {
+		body, _ := data["text"].(string)
+		title, _ := data["title"].(string)
+		text = body
+		if title != "" {
+			text = strings.TrimSuffix(title+"\n"+body, "\n")
+		}
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("消息内容为空")
+	}
+	return text, nil
+}
+
+// poster 是 Webhook 发言使用的机器人，只发送消息，不处理收到的消息
+type poster struct {
+	name   string
+	groups []string
+}
+
+func (p *poster) Name() string { return p.name }
+
+// Start 加入目标群组，群组不存在时由此创建，外部消息发到群组时也不会因群组不存在而被丢弃
+func (p *poster) Start(_ context.Context, s *core.BotSession) {
+	for _, group := range p.groups {
+		s.JoinGroup(group)
+	}
+}
+
+func (p *poster) HandleMessage(*core.BotSession, protocol.Message)      {}
+func (p *poster) HandlePresence(*core.BotSession, protocol.TreePayload) {}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
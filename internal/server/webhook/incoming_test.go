@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestAuthorized(t *testing.T) {
+	h := &incomingHook{IncomingHook: IncomingHook{Name: "ci", Token: "s3cret"}}
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   bool
+	}{
+		{"Bearer 令牌", "/ci", "Bearer s3cret", true},
+		{"令牌错误", "/ci", "Bearer wrong", false},
+		{"令牌前缀", "/ci", "Bearer s3c", false},
+		{"空令牌", "/ci", "Bearer ", false},
+		{"没有 Authorization", "/ci", "", false},
+		{"其它认证方式", "/ci", "Basic s3cret", false},
+		{"小写的 bearer", "/ci", "bearer s3cret", false},
+		{"查询参数中的令牌", "/ci?token=s3cret", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if got := h.authorized(r); got != tt.want {
+				t.Fatalf("authorized() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncomingFormat(t *testing.T) {
+	tmpl := template.Must(template.New("ci").Option("missingkey=zero").Parse("[{{.status}}] {{.pipeline}}"))
+	tests := []struct {
+		name     string
+		template *template.Template
+		data     map[string]any
+		want     string
+		wantErr  bool
+	}{
+		{"只有正文", nil, map[string]any{"text": "main 分支 #42"}, "main 分支 #42", false},
+		{"标题和正文", nil, map[string]any{"title": "构建失败", "text": "main 分支 #42"}, "构建失败\nmain 分支 #42", false},
+		{"只有标题", nil, map[string]any{"title": "构建失败"}, "构建失败", false},
+		{"正文不是字符串", nil, map[string]any{"text": 42}, "", true},
+		{"空消息", nil, map[string]any{"text": "  "}, "", true},
+		{"模板", tmpl, map[string]any{"status": "失败", "pipeline": "deploy"}, "[失败] deploy", false},
+		{"模板缺少字段", tmpl, map[string]any{"status": "成功"}, "[成功] <no value>", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &incomingHook{template: tt.template}
+			got, err := h.format(tt.data)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("format() = (%q, %v), 期望 %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncomingRejects(t *testing.T) {
+	in := http.StripPrefix("/hooks/", &Incoming{hooks: map[string]*incomingHook{
+		"ci": {IncomingHook: IncomingHook{Name: "ci", Token: "s3cret", Bot: "ci-bot"}},
+	}})
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		want   int
+	}{
+		{"不存在的 Webhook", http.MethodPost, "/cd", "s3cret", `{"text":"hi"}`, http.StatusNotFound},
+		{"GET 请求", http.MethodGet, "/ci", "s3cret", "", http.StatusMethodNotAllowed},
+		{"没有令牌", http.MethodPost, "/ci", "", `{"text":"hi"}`, http.StatusUnauthorized},
+		{"查询参数中的令牌", http.MethodPost, "/ci?token=s3cret", "", `{"text":"hi"}`, http.StatusUnauthorized},
+		{"请求体不是 JSON", http.MethodPost, "/ci", "s3cret", "hi", http.StatusBadRequest},
+		{"请求体不是对象", http.MethodPost, "/ci", "s3cret", `["hi"]`, http.StatusBadRequest},
+		{"请求体过大", http.MethodPost, "/ci", "s3cret", `{"text":"` + strings.Repeat("x", maxIncomingBody) + `"}`, http.StatusBadRequest},
+		{"消息为空", http.MethodPost, "/ci", "s3cret", `{"title":""}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/hooks"+tt.target, strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			in.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("状态码 = %d, 期望 %d: %s", w.Code, tt.want, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), `"error"`) {
+				t.Errorf("响应中没有错误信息: %s", w.Body)
+			}
+		})
+	}
+}
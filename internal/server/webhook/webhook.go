@@ -0,0 +1,244 @@
+// Package webhook 实现与外部 HTTP 服务的双向集成。外发 Webhook 将世界大厅和群组中的消息以 JSON 格式
+// POST 到外部服务，规则在 Hub 的转发流程中作为中间件匹配，请求在每个 Webhook 自己的协程中发送，不会阻塞 Hub；
+// 接收 Webhook (Incoming) 让外部服务通过 HTTP 请求以机器人的身份发言
+package webhook
+
+import (
+	"GoChat/internal/server/core"
+	"GoChat/internal/server/metrics"
+	"GoChat/pkg/protocol"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	queueSize      = 256 // 每个 Webhook 等待发送的消息数，超出时丢弃新消息
+	maxBackoff     = time.Minute
+)
+
+// 请求结果，用作 gochat_webhook_deliveries_total 的 result 标签
+const (
+	resultDelivered = "delivered" // 服务端返回 2xx
+	resultFailed    = "failed"    // 重试后仍然失败
+	resultDropped   = "dropped"   // 等待发送的消息过多而被丢弃
+)
+
+var metricDeliveries = metrics.NewCounterVec("gochat_webhook_deliveries_total", "外发 Webhook 的请求数", "result")
+
+// Rule 描述一个 Webhook 及其匹配的消息
+type Rule struct {
+	Name     string
+	URL      string
+	Secret   string        // 不为空时在 X-GoChat-Signature 头中提供请求体的 HMAC-SHA256 签名
+	Lobby    bool          // 匹配世界大厅的所有消息
+	Groups   []string      // 匹配这些群组的所有消息
+	Keywords []string      // 匹配世界大厅和任意群组中包含这些词的消息，不区分大小写
+	Retries  int           // 失败后的重试次数
+	Timeout  time.Duration // 单次请求的超时，0 表示 10 秒
+}
+
+// Event 是 POST 给 Webhook 的请求体
+type Event struct {
+	Webhook  string    `json:"webhook"`
+	Type     string    `json:"type"` // 协议中的消息类型，如 msg_broadcast、msg_group、file_group
+	Time     time.Time `json:"time"`
+	Sender   string    `json:"sender"`
+	Group    string    `json:"group,omitempty"` // 世界大厅的消息为空
+	Text     string    `json:"text,omitempty"`
+	File     *File     `json:"file,omitempty"`
+	Keywords []string  `json:"keywords,omitempty"` // 消息中出现的关键词
+}
+
+// File 是文件消息的描述，不包含文件内容
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Dispatcher 按规则匹配消息并发送给对应的 Webhook
+type Dispatcher struct {
+	hooks []*hook
+}
+
+// hook 是一个 Webhook 的发送队列
+type hook struct {
+	rule     Rule
+	groups   map[string]bool
+	keywords map[string]string // 转为小写的关键词到配置中的关键词
+	queue    chan Event
+	client   *http.Client
+}
+
+// New 创建 Dispatcher 并为每个 Webhook 启动发送协程
+func New(rules []Rule) *Dispatcher {
+	d := &Dispatcher{}
+	for _, rule := range rules {
+		h := newHook(rule)
+		d.hooks = append(d.hooks, h)
+		go h.run()
+	}
+	return d
+}
+
+func newHook(rule Rule) *hook {
+	h := &hook{
+		rule:     rule,
+		groups:   make(map[string]bool),
+		keywords: make(map[string]string),
+		queue:    make(chan Event, queueSize),
+		client:   &http.Client{Timeout: rule.Timeout},
+	}
+	if h.client.Timeout <= 0 {
+		h.client.Timeout = defaultTimeout
+	}
+	for _, group := range rule.Groups {
+		h.groups[group] = true
+	}
+	for _, keyword := range rule.Keywords {
+		if keyword != "" {
+			h.keywords[strings.ToLower(keyword)] = keyword
+		}
+	}
+	return h
+}
+
+// Middleware 是 Hub 转发流程中的中间件，应在其它中间件之后添加，使 Webhook 收到的是最终投递的内容。
+// Hub 在调用中间件之前已经丢弃了发往不存在的群组和发往加密群组的明文消息，这些消息不会外发；
+// 私聊和端到端加密的消息不会被匹配
+func (d *Dispatcher) Middleware(next core.ForwardFunc) core.ForwardFunc {
+	return func(message *protocol.Message) {
+		if message.Encrypted == nil {
+			switch message.Type {
+			case protocol.BroadcastMessage, protocol.GroupMessage, protocol.GroupFileMessage:
+				for _, h := range d.hooks {
+					h.match(message)
+				}
+			}
+		}
+		next(message)
+	}
+}
+
+// match 在消息符合规则时将其放入发送队列，在 Hub 协程中调用，不能阻塞
+func (h *hook) match(message *protocol.Message) {
+	group := ""
+	if message.Type != protocol.BroadcastMessage {
+		group = message.GroupName
+	}
+	var found []string
+	text := strings.ToLower(message.TextPayload)
+	for lower, keyword := range h.keywords {
+		if strings.Contains(text, lower) {
+			found = append(found, keyword)
+		}
+	}
+	slices.Sort(found)
+	all := (group == "" && h.rule.Lobby) || (group != "" && h.groups[group])
+	if !all && len(found) == 0 {
+		return
+	}
+
+	e := Event{
+		Webhook:  h.rule.Name,
+		Type:     message.Type,
+		Time:     message.Timestamp,
+		Sender:   message.Sender,
+		Group:    group,
+		Text:     message.TextPayload,
+		Keywords: found,
+	}
+	if message.Type == protocol.GroupFileMessage {
+		e.File = &File{Name: message.FilePayload.Name, Size: message.FilePayload.Size}
+	}
+	select {
+	case h.queue <- e:
+	default:
+		metricDeliveries.Inc(resultDropped)
+		slog.Warn("Webhook 待发送的消息过多，消息被丢弃", "webhook", h.rule.Name)
+	}
+}
+
+// run 依次发送队列中的消息，保证同一个 Webhook 收到消息的顺序
+func (h *hook) run() {
+	for e := range h.queue {
+		h.deliver(e)
+	}
+}
+
+// deliver 发送一条消息，失败时按指数退避重试。同一条消息的重试使用相同的 X-GoChat-Delivery，
+// 接收方可以据此去重
+func (h *hook) deliver(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("Webhook 消息编码失败", "webhook", h.rule.Name, "error", err)
+		return
+	}
+	id := rand.Text()
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(id, body)
+		if err == nil {
+			metricDeliveries.Inc(resultDelivered)
+			return
+		}
+		if !retry || attempt >= h.rule.Retries {
+			metricDeliveries.Inc(resultFailed)
+			slog.Warn("Webhook 请求失败", "webhook", h.rule.Name, "attempts", attempt+1, "error", err)
+			return
+		}
+		slog.Debug("Webhook 请求失败，稍后重试", "webhook", h.rule.Name, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// post 发送一次请求。retry 表示失败是否可能是暂时的: 网络错误、超时、429 和 5xx 会重试，其它 4xx 不会
+func (h *hook) post(id string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, h.rule.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoChat-Webhook")
+	req.Header.Set("X-GoChat-Webhook", h.rule.Name)
+	req.Header.Set("X-GoChat-Delivery", id)
+	if h.rule.Secret != "" {
+		req.Header.Set("X-GoChat-Signature", "sha256="+Sign(h.rule.Secret, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode >= 500:
+		return true, fmt.Errorf("服务端返回 %s", resp.Status)
+	default:
+		return false, fmt.Errorf("服务端返回 %s", resp.Status)
+	}
+}
+
+// Sign 返回请求体的 HMAC-SHA256 签名 (十六进制)，接收方用同一个密钥计算后与
+// X-GoChat-Signature 头中 "sha256=" 之后的部分比较
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
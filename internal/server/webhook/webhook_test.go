@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"GoChat/pkg/protocol"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 测试用例 2
+		{"Jefe", "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"key", "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q) = %s, 期望 %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+	if Sign("a", []byte("body")) == Sign("b", []byte("body")) {
+		t.Error("不同密钥的签名相同")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rule := Rule{Name: "ci", Groups: []string{"dev"}, Keywords: []string{"Deploy", "故障", ""}}
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		lobby   bool
+		message protocol.Message
+		want    *Event
+	}{
+		{"群组的所有消息", false,
+			protocol.Message{Type: protocol.GroupMessage, Sender: "bob", GroupName: "dev", TextPayload: "hi"},
+			&Event{Webhook: "ci", Type: protocol.GroupMessage, Time: at, Sender: "bob", Group: "dev", Text: "hi"}},
+		{"其它群组的关键词", false,
+			protocol.Message{Type: protocol.GroupMessage, Sender: "bob", GroupName: "ops", TextPayload: "deploy 出现故障"},
+			&Event{Webhook: "ci", Type: protocol.GroupMessage, Time: at, Sender: "bob", Group: "ops", Text: "deploy 出现故障", Keywords: []string{"Deploy", "故障"}}},
+		{"其它群组没有关键词", false,
+			protocol.Message{Type: protocol.GroupMessage, Sender: "bob", GroupName: "ops", TextPayload: "hi"}, nil},
+		{"大厅的关键词", false,
+			protocol.Message{Type: protocol.BroadcastMessage, Sender: "bob", GroupName: "dev", TextPayload: "DEPLOY"},
+			&Event{Webhook: "ci", Type: protocol.BroadcastMessage, Time: at, Sender: "bob", Text: "DEPLOY", Keywords: []string{"Deploy"}}},
+		{"未订阅大厅", false,
+			protocol.Message{Type: protocol.BroadcastMessage, Sender: "bob", TextPayload: "hi"}, nil},
+		{"订阅大厅", true,
+			protocol.Message{Type: protocol.BroadcastMessage, Sender: "bob", TextPayload: "hi"},
+			&Event{Webhook: "ci", Type: protocol.BroadcastMessage, Time: at, Sender: "bob", Text: "hi"}},
+		{"群组文件", false,
+			protocol.Message{Type: protocol.GroupFileMessage, Sender: "bob", GroupName: "dev", FilePayload: protocol.FilePayload{Name: "a.log", Size: 3, Data: []byte("abc")}},
+			&Event{Webhook: "ci", Type: protocol.GroupFileMessage, Time: at, Sender: "bob", Group: "dev", File: &File{Name: "a.log", Size: 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := rule
+			rule.Lobby = tt.lobby
+			h := newHook(rule)
+			tt.message.Timestamp = at
+			h.match(&tt.message)
+			var got *Event
+			select {
+			case e := <-h.queue:
+				got = &e
+			default:
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("match() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		message protocol.Message
+		matched bool
+	}{
+		{"大厅消息", protocol.Message{Type: protocol.BroadcastMessage, TextPayload: "hi"}, true},
+		{"群组消息", protocol.Message{Type: protocol.GroupMessage, GroupName: "dev", TextPayload: "hi"}, true},
+		{"私聊", protocol.Message{Type: protocol.PrivateMessage, Recipient: "bob", TextPayload: "hi"}, false},
+		{"加密的群组消息", protocol.Message{Type: protocol.GroupMessage, GroupName: "dev", Encrypted: &protocol.EncryptedPayload{}}, false},
+		{"系统消息", protocol.Message{Type: protocol.SystemMessage, TextPayload: "hi"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHook(Rule{Name: "all", Lobby: true, Groups: []string{"dev"}, Keywords: []string{"hi"}})
+			d := &Dispatcher{hooks: []*hook{h}}
+			forwarded := false
+			d.Middleware(func(*protocol.Message) { forwarded = true })(&tt.message)
+			if !forwarded {
+				t.Fatal("消息没有继续转发")
+			}
+			if got := len(h.queue) == 1; got != tt.matched {
+				t.Fatalf("匹配 = %v, 期望 %v", got, tt.matched)
+			}
+		})
+	}
+}
+
+func TestPost(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantErr   bool
+		wantRetry bool
+	}{
+		{"成功", http.StatusNoContent, false, false},
+		{"请求错误不重试", http.StatusBadRequest, true, false},
+		{"限流时重试", http.StatusTooManyRequests, true, true},
+		{"服务端错误时重试", http.StatusBadGateway, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"webhook":"ci"}`)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ := io.ReadAll(r.Body)
+				if string(got) != string(body) {
+					t.Errorf("请求体 = %s", got)
+				}
+				if sig := r.Header.Get("X-GoChat-Signature"); sig != "sha256="+Sign("s3cret", got) {
+					t.Errorf("签名 = %q", sig)
+				}
+				if r.Header.Get("X-GoChat-Delivery") != "id-1" || r.Header.Get("X-GoChat-Webhook") != "ci" {
+					t.Errorf("请求头 = %v", r.Header)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			h := newHook(Rule{Name: "ci", URL: server.URL, Secret: "s3cret"})
+			retry, err := h.post("id-1", body)
+			if (err != nil) != tt.wantErr || retry != tt.wantRetry {
+				t.Fatalf("post() = (%v, %v), 期望错误 %v 重试 %v", retry, err, tt.wantErr, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestPostWithoutSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sig, ok := r.Header["X-Gochat-Signature"]; ok {
+			t.Errorf("没有密钥时发送了签名 %q", sig)
+		}
+	}))
+	defer server.Close()
+	if _, err := newHook(Rule{Name: "ci", URL: server.URL}).post("id-1", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+}
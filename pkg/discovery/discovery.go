@@ -0,0 +1,197 @@
+// Package discovery 实现局域网内的服务器发现：客户端向局域网广播查询，
+// 服务器以单播回复自己的名称、端口和证书指纹
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort 是服务器监听查询的默认 UDP 端口
+const DefaultPort = 8089
+
+// query 是客户端广播的查询内容
+const query = "GOCHAT_DISCOVER_V1"
+
+// Announcement 是服务器对查询的回复
+type Announcement struct {
+	Name        string `json:"name"`                  // 服务器名称
+	Port        int    `json:"port"`                  // 聊天服务端口
+	TLS         bool   `json:"tls"`                   // 是否只接受 TLS 连接
+	Fingerprint string `json:"fingerprint,omitempty"` // TLS 证书的 SHA-256 指纹
+}
+
+// Server 是发现的服务器
+type Server struct {
+	Announcement
+	Address string // 可直接用于连接的地址，IP 取自回复的来源地址
+}
+
+// Responder 在 UDP 端口上回复客户端的查询
+type Responder struct {
+	conn  *net.UDPConn
+	reply []byte
+}
+
+// Listen 在指定 UDP 端口上监听查询
+func Listen(port int, announcement Announcement) (*Responder, error) {
+	reply, err := json.Marshal(announcement)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return &Responder{conn: conn, reply: reply}, nil
+}
+
+// Serve 循环处理查询，直到 Close 被调用。
+// 只回复来自局域网 (私有、链路本地或环回地址) 的查询，避免被用于放大攻击
+func (r *Responder) Serve() error {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := r.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if string(buf[:n]) != query || !isLocal(addr.Addr()) {
+			continue
+		}
+		if _, err := r.conn.WriteToUDPAddrPort(r.reply, addr); err != nil {
+			slog.Debug("回复服务器发现查询失败", "remote_addr", addr.String(), "error", err)
+		}
+	}
+}
+
+// Close 停止监听
+func (r *Responder) Close() error {
+	return r.conn.Close()
+}
+
+func isLocal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast()
+}
+
+// Discover 向局域网广播查询，返回 timeout 内回复的服务器，按名称和地址排序
+func Discover(port int, timeout time.Duration) ([]Server, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	targets := broadcastAddresses(port)
+	send := func() {
+		for _, target := range targets {
+			// 部分网卡不允许广播，忽略单个地址的发送错误
+			conn.WriteToUDPAddrPort([]byte(query), target)
+		}
+	}
+
+	// UDP 可能丢包，在等待期间重发几次查询
+	deadline := time.Now().Add(timeout)
+	resend := time.Now()
+	found := make(map[string]Server)
+	buf := make([]byte, 2048)
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			break
+		}
+		if !now.Before(resend) {
+			send()
+			resend = now.Add(timeout / 3)
+		}
+		if resend.Before(deadline) {
+			conn.SetReadDeadline(resend)
+		} else {
+			conn.SetReadDeadline(deadline)
+		}
+		n, addr, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+		var announcement Announcement
+		if err := json.Unmarshal(buf[:n], &announcement); err != nil || announcement.Port == 0 {
+			continue
+		}
+		address := net.JoinHostPort(addr.Addr().Unmap().String(), strconv.Itoa(announcement.Port))
+		found[address] = Server{Announcement: announcement, Address: address}
+	}
+
+	// 同一台服务器可能同时通过环回地址和局域网地址回复，只保留局域网地址
+	onLAN := make(map[Announcement]bool)
+	for _, server := range found {
+		if !isLoopback(server.Address) {
+			onLAN[server.Announcement] = true
+		}
+	}
+	servers := make([]Server, 0, len(found))
+	for _, server := range found {
+		if !isLoopback(server.Address) || !onLAN[server.Announcement] {
+			servers = append(servers, server)
+		}
+	}
+	slices.SortFunc(servers, func(a, b Server) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return servers, nil
+}
+
+func isLoopback(address string) bool {
+	addr, err := netip.ParseAddrPort(address)
+	return err == nil && addr.Addr().IsLoopback()
+}
+
+// broadcastAddresses 返回受限广播地址、各网卡的子网广播地址以及本机环回地址
+func broadcastAddresses(port int) []netip.AddrPort {
+	targets := []netip.AddrPort{
+		netip.AddrPortFrom(netip.AddrFrom4([4]byte{255, 255, 255, 255}), uint16(port)),
+		netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), uint16(port)),
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return targets
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, mask := ipNet.IP.To4(), ipNet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		if ip == nil || ip.IsLoopback() || len(mask) != net.IPv4len {
+			continue
+		}
+		var broadcast [4]byte
+		for i := range broadcast {
+			broadcast[i] = ip[i] | ^mask[i]
+		}
+		target := netip.AddrPortFrom(netip.AddrFrom4(broadcast), uint16(port))
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,174 @@
+// Package e2e 实现客户端之间的端到端加密。
+//
+// 每个用户持有一对 X25519 密钥，公钥通过服务器发布。双方用各自的私钥和对方的公钥
+// 协商出相同的共享密钥，经 HKDF-SHA256 派生为 AES-256-GCM 会话密钥；发送者、接收者和
+// 双方公钥作为附加数据参与认证，服务器无法读取或篡改消息内容，也无法冒充发送者
+package e2e
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const version = "GoChat-E2E-v1"
+
+var (
+	ErrInvalidKey = errors.New("无效的公钥")
+	ErrNotForMe   = errors.New("消息不是用当前密钥加密的")
+	ErrDecrypt    = errors.New("解密失败，消息可能被篡改或密钥不匹配")
+)
+
+// Identity 是用户的长期密钥对
+type Identity struct {
+	private *ecdh.PrivateKey
+}
+
+type identityFile struct {
+	PrivateKey []byte `json:"private_key"`
+}
+
+// GenerateIdentity 生成新的密钥对
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{private: key}, nil
+}
+
+// LoadOrCreateIdentity 从 path 读取密钥对，文件不存在时生成新的密钥对并保存
+func LoadOrCreateIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		id, err := GenerateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(identityFile{PrivateKey: id.private.Bytes()})
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file identityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析密钥文件 %s 失败: %w", path, err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(file.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("密钥文件 %s 无效: %w", path, err)
+	}
+	return &Identity{private: key}, nil
+}
+
+// PublicKey 返回 base64 编码的公钥，用于发布到服务器
+func (id *Identity) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(id.private.PublicKey().Bytes())
+}
+
+// ParsePublicKey 解析 base64 编码的公钥
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// Seal 加密 sender 发给 recipient 的消息，peerKey 为接收者的公钥
+func (id *Identity) Seal(sender, recipient, peerKey string, plaintext []byte) (*protocol.EncryptedPayload, error) {
+	payload := &protocol.EncryptedPayload{
+		SenderKey:    id.PublicKey(),
+		RecipientKey: peerKey,
+	}
+	aead, err := id.session(peerKey)
+	if err != nil {
+		return nil, err
+	}
+	payload.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(payload.Nonce); err != nil {
+		return nil, err
+	}
+	payload.Ciphertext = aead.Seal(nil, payload.Nonce, plaintext, additionalData(sender, recipient, payload))
+	return payload, nil
+}
+
+// Open 解密 sender 发给 recipient 的消息，当前用户可以是其中任意一方。
+// 返回明文和对方的公钥，调用者应确认该公钥确实属于对方
+func (id *Identity) Open(sender, recipient string, payload *protocol.EncryptedPayload) (plaintext []byte, peerKey string, err error) {
+	switch self := id.PublicKey(); self {
+	case payload.RecipientKey:
+		peerKey = payload.SenderKey
+	case payload.SenderKey:
+		peerKey = payload.RecipientKey
+	default:
+		return nil, "", ErrNotForMe
+	}
+	aead, err := id.session(peerKey)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext, err = aead.Open(nil, payload.Nonce, payload.Ciphertext, additionalData(sender, recipient, payload))
+	if err != nil {
+		return nil, "", ErrDecrypt
+	}
+	return plaintext, peerKey, nil
+}
+
+// session 根据双方密钥派生会话密钥
+func (id *Identity) session(peerKey string) (cipher.AEAD, error) {
+	peer, err := ParsePublicKey(peerKey)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := id.private.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	// 双方按相同顺序拼接公钥，保证派生出相同的密钥
+	a, b := id.private.PublicKey().Bytes(), peer.Bytes()
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	info := string(bytes.Join([][]byte{[]byte(version), a, b}, nil))
+	key, err := hkdf.Key(sha256.New, shared, nil, info, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(sender, recipient string, payload *protocol.EncryptedPayload) []byte {
+	return []byte(version + "\x00" + sender + "\x00" + recipient + "\x00" + payload.SenderKey + "\x00" + payload.RecipientKey)
+}
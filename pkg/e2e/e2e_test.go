@@ -0,0 +1,150 @@
+package e2e
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func mustIdentity(t *testing.T) *Identity {
+	t.Helper()
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSealOpen(t *testing.T) {
+	alice, bob, eve := mustIdentity(t), mustIdentity(t), mustIdentity(t)
+	plaintext := []byte("你好，bob")
+	payload, err := alice.Seal("alice", "bob", bob.PublicKey(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(payload.Ciphertext, plaintext) {
+		t.Fatal("密文中包含明文")
+	}
+
+	tampered := *payload
+	tampered.Ciphertext = bytes.Clone(payload.Ciphertext)
+	tampered.Ciphertext[0] ^= 1
+	swappedKeys := *payload
+	swappedKeys.SenderKey = eve.PublicKey()
+
+	tests := []struct {
+		name              string
+		opener            *Identity
+		sender, recipient string
+		payload           *protocol.EncryptedPayload
+		wantPeer          string
+		wantErr           error
+	}{
+		{"接收者解密", bob, "alice", "bob", payload, alice.PublicKey(), nil},
+		{"发送者解密自己的副本", alice, "alice", "bob", payload, bob.PublicKey(), nil},
+		{"第三方无法解密", eve, "alice", "bob", payload, "", ErrNotForMe},
+		{"密文被篡改", bob, "alice", "bob", &tampered, "", ErrDecrypt},
+		{"冒充发送者", bob, "mallory", "bob", payload, "", ErrDecrypt},
+		{"改为发给其他人", bob, "alice", "carol", payload, "", ErrDecrypt},
+		{"替换发送者公钥", bob, "alice", "bob", &swappedKeys, "", ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, peer, err := tt.opener.Open(tt.sender, tt.recipient, tt.payload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got, plaintext) || peer != tt.wantPeer {
+				t.Fatalf("Open() = (%q, %s), 期望 (%q, %s)", got, peer, plaintext, tt.wantPeer)
+			}
+		})
+	}
+}
+
+func TestSealInvalidKey(t *testing.T) {
+	alice := mustIdentity(t)
+	for _, key := range []string{"", "not base64!", "AAAA"} {
+		if _, err := alice.Seal("alice", "bob", key, []byte("hi")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Seal(peerKey=%q) 错误 = %v, 期望 %v", key, err, ErrInvalidKey)
+		}
+	}
+}
+
+func TestSealUsesFreshNonce(t *testing.T) {
+	alice, bob := mustIdentity(t), mustIdentity(t)
+	a, err := alice.Seal("alice", "bob", bob.PublicKey(), []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := alice.Seal("alice", "bob", bob.PublicKey(), []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.Nonce, b.Nonce) || bytes.Equal(a.Ciphertext, b.Ciphertext) {
+		t.Fatal("两次加密使用了相同的随机数")
+	}
+}
+
+func TestLoadOrCreateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice", "identity.json")
+	created, err := LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("密钥文件权限为 %o, 期望 600", perm)
+	}
+	loaded, err := LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PublicKey() != created.PublicKey() {
+		t.Fatal("重新加载的密钥与生成的密钥不同")
+	}
+
+	for name, data := range map[string]string{
+		"不是 JSON": "garbage",
+		"私钥长度错误":  `{"private_key":"AAAA"}`,
+	} {
+		bad := filepath.Join(t.TempDir(), "identity.json")
+		if err := os.WriteFile(bad, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadOrCreateIdentity(bad); err == nil {
+			t.Errorf("%s: 期望加载失败", name)
+		}
+	}
+}
+
+func TestSafetyNumber(t *testing.T) {
+	alice, bob, eve := mustIdentity(t), mustIdentity(t), mustIdentity(t)
+	number := SafetyNumber("alice", alice.PublicKey(), "bob", bob.PublicKey())
+	if !regexp.MustCompile(`^\d{5}( \d{5}){11}$`).MatchString(number) {
+		t.Fatalf("安全码格式错误: %q", number)
+	}
+	tests := []struct {
+		name  string
+		other string
+		same  bool
+	}{
+		{"双方计算的结果相同", SafetyNumber("bob", bob.PublicKey(), "alice", alice.PublicKey()), true},
+		{"公钥被替换", SafetyNumber("alice", alice.PublicKey(), "bob", eve.PublicKey()), false},
+		{"用户名不同", SafetyNumber("alice", alice.PublicKey(), "bobby", bob.PublicKey()), false},
+	}
+	for _, tt := range tests {
+		if (tt.other == number) != tt.same {
+			t.Errorf("%s: %q 与 %q 比较结果不符", tt.name, tt.other, number)
+		}
+	}
+}
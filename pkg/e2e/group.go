@@ -0,0 +1,68 @@
+package e2e
+
+import (
+	"GoChat/pkg/protocol"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// GroupKey 是加密群组的共享密钥，由群组中的一名成员生成后通过私聊加密分发给其他成员，
+// 成员变化时重新生成，离开的成员无法解密之后的消息
+type GroupKey struct {
+	ID  string `json:"id"`  // 密钥编号，随消息一起发送，便于接收者选择密钥
+	Key []byte `json:"key"` // AES-256 密钥
+}
+
+// NewGroupKey 生成新的群组密钥
+func NewGroupKey() (*GroupKey, error) {
+	id := make([]byte, 8)
+	key := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &GroupKey{ID: hex.EncodeToString(id), Key: key}, nil
+}
+
+// SealGroup 用群组密钥加密 sender 发到 group 的消息
+func SealGroup(key *GroupKey, group, sender string, plaintext []byte) (*protocol.EncryptedPayload, error) {
+	aead, err := groupAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	payload := &protocol.EncryptedPayload{KeyID: key.ID, Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(payload.Nonce); err != nil {
+		return nil, err
+	}
+	payload.Ciphertext = aead.Seal(nil, payload.Nonce, plaintext, groupAdditionalData(group, sender, key.ID))
+	return payload, nil
+}
+
+// OpenGroup 用群组密钥解密消息
+func OpenGroup(key *GroupKey, group, sender string, payload *protocol.EncryptedPayload) ([]byte, error) {
+	aead, err := groupAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, payload.Nonce, payload.Ciphertext, groupAdditionalData(group, sender, payload.KeyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func groupAEAD(key *GroupKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func groupAdditionalData(group, sender, keyID string) []byte {
+	return []byte(version + "-group\x00" + group + "\x00" + sender + "\x00" + keyID)
+}
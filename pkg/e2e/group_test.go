@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"GoChat/pkg/protocol"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealOpenGroup(t *testing.T) {
+	key, err := NewGroupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewGroupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.Key) != 32 || len(key.ID) != 16 || key.ID == other.ID {
+		t.Fatalf("群组密钥格式错误: id=%q len=%d", key.ID, len(key.Key))
+	}
+
+	plaintext := []byte("周五发布")
+	payload, err := SealGroup(key, "dev", "alice", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.KeyID != key.ID {
+		t.Fatalf("KeyID = %q, 期望 %q", payload.KeyID, key.ID)
+	}
+	tampered := *payload
+	tampered.Ciphertext = bytes.Clone(payload.Ciphertext)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+	relabeled := *payload
+	relabeled.KeyID = other.ID
+
+	tests := []struct {
+		name    string
+		key     *GroupKey
+		group   string
+		sender  string
+		payload *protocol.EncryptedPayload
+		wantErr error
+	}{
+		{"成员解密", key, "dev", "alice", payload, nil},
+		{"旧成员使用其它密钥", other, "dev", "alice", payload, ErrDecrypt},
+		{"转发到其它群组", key, "ops", "alice", payload, ErrDecrypt},
+		{"冒充发送者", key, "dev", "mallory", payload, ErrDecrypt},
+		{"密文被篡改", key, "dev", "alice", &tampered, ErrDecrypt},
+		{"修改密钥编号", key, "dev", "alice", &relabeled, ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenGroup(tt.key, tt.group, tt.sender, tt.payload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenGroup() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, plaintext) {
+				t.Fatalf("OpenGroup() = %q, 期望 %q", got, plaintext)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package e2e
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// SafetyNumber 根据双方的用户名和公钥计算 60 位安全码，双方计算的结果相同。
+// 两人当面或通过其他可信渠道核对安全码一致，即可确认没有被中间人替换公钥
+func SafetyNumber(userA, keyA, userB, keyB string) string {
+	a, b := partyDigits(userA, keyA), partyDigits(userB, keyB)
+	if a > b {
+		a, b = b, a
+	}
+	digits := a + b
+
+	groups := make([]string, 0, len(digits)/5)
+	for i := 0; i < len(digits); i += 5 {
+		groups = append(groups, digits[i:i+5])
+	}
+	return strings.Join(groups, " ")
+}
+
+// partyDigits 将一方的身份转换为 30 位数字
+func partyDigits(user, key string) string {
+	sum := sha256.Sum256([]byte(version + "-safety\x00" + user + "\x00" + key))
+	var sb strings.Builder
+	for i := 0; i < 6; i++ {
+		// 每 5 个字节取模得到 5 位数字
+		chunk := binary.BigEndian.Uint64(append([]byte{0, 0, 0}, sum[i*5:i*5+5]...))
+		fmt.Fprintf(&sb, "%05d", chunk%100000)
+	}
+	return sb.String()
+}
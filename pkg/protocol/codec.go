@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"io"
 )
 
@@ -12,6 +13,9 @@ const (
 	HeaderLength = 4
 )
 
+// ErrFrameTooLarge 表示数据帧长度超过了允许的上限
+var ErrFrameTooLarge = errors.New("数据帧过大")
+
 // Message 将一个Message对象编码成数据帧
 func EncodeMessage(msg Message) ([]byte, error) {
 	payload, err := json.Marshal(msg)
@@ -23,8 +27,13 @@ func EncodeMessage(msg Message) ([]byte, error) {
 
 // DecodeMessage 从数据帧中解码出一个Message对象
 func DecodeMessage(reader *bufio.Reader) (*Message, error) {
+	return DecodeMessageLimit(reader, 0)
+}
+
+// DecodeMessageLimit 与 DecodeMessage 相同，但拒绝长度超过 maxSize 的数据帧，maxSize 为 0 表示不限制
+func DecodeMessageLimit(reader *bufio.Reader, maxSize int) (*Message, error) {
 	var msg Message
-	payload, err := decodeFrame(reader)
+	payload, err := decodeFrame(reader, maxSize)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +51,7 @@ func encodeFrame(payload []byte) ([]byte, error) {
 }
 
 // decodeFrame 解包消息，返回内容和剩余数据
-func decodeFrame(reader *bufio.Reader) ([]byte, error) {
+func decodeFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
 	header := make([]byte, HeaderLength)
 	_, err := io.ReadFull(reader, header)
 	if err != nil {
@@ -50,6 +59,9 @@ func decodeFrame(reader *bufio.Reader) ([]byte, error) {
 	}
 
 	length := binary.BigEndian.Uint32(header)
+	if maxSize > 0 && uint64(length) > uint64(maxSize) {
+		return nil, ErrFrameTooLarge
+	}
 
 	payload := make([]byte, length)
 	_, err = io.ReadFull(reader, payload)
@@ -0,0 +1,18 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// CertFingerprint 返回证书 (DER 编码) 的 SHA-256 指纹，格式如 AB:CD:...，
+// 服务器日志和客户端界面使用同一格式，便于人工比对
+func CertFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
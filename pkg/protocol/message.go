@@ -7,23 +7,44 @@ import (
 
 // 定义消息类型常量
 const (
-	LoginRequest       = "cmd_login"
-	CreateGroupRequest = "cmd_create_group"
-	JoinGroupRequest   = "cmd_join_group"
-	LeaveGroupRequest  = "cmd_leave_group"
+	LoginRequest                = "cmd_login"
+	CreateGroupRequest          = "cmd_create_group"
+	CreateEncryptedGroupRequest = "cmd_create_encrypted_group" // 创建端到端加密群组，TextPayload 为群组名
+	JoinGroupRequest            = "cmd_join_group"
+	LeaveGroupRequest           = "cmd_leave_group"
+	PublishKeyRequest           = "cmd_publish_key" // 发布端到端加密公钥，TextPayload 为 base64 编码的公钥
+	HistoryRequest              = "cmd_history"     // 查询最近的聊天记录，GroupName 为群组 (为空时为世界大厅)，TextPayload 为最多返回的条数
 
 	// --- 数据/通知类型 ---
+	LoginResponse      = "data_login"       // 登录结果，Recipient 为最终用户名，失败时 TextPayload 为原因
 	TreeUpdate         = "data_tree_update" // 树状列表更新
 	BroadcastMessage   = "msg_broadcast"    // 广播消息
 	PrivateMessage     = "msg_private"      // 私聊消息
 	GroupMessage       = "msg_group"        // 群聊消息
 	PrivateFileMessage = "file_private"     // 私聊文件
 	GroupFileMessage   = "file_group"       // 群聊文件
+	SystemMessage      = "msg_system"       // 服务器发给单个用户的系统通知
+	GroupKeyMessage    = "msg_group_key"    // 加密群组的密钥分发，GroupName 为群组，只发给 Recipient
+	HistoryResponse    = "data_history"     // 聊天记录，GroupName 与请求相同，消息按时间顺序放在 History 中，失败时 TextPayload 为原因
 )
 
 type TreePayload struct {
-	Users  []string            `json:"users"`  // 在线用户列表
-	Groups map[string][]string `json:"groups"` // 群组列表，键为群组名，值为成员列表
+	Users  []string            `json:"users"`          // 在线用户列表
+	Groups map[string][]string `json:"groups"`         // 群组列表，键为群组名，值为成员列表
+	Keys   map[string]string   `json:"keys,omitempty"` // 用户发布的端到端加密公钥，键为用户名
+
+	EncryptedGroups []string `json:"encrypted_groups,omitempty"` // 启用端到端加密的群组
+	Bots            []string `json:"bots,omitempty"`             // 在线用户中由服务器运行的机器人
+}
+
+// EncryptedPayload 是端到端加密的消息内容，服务器只负责转发。
+// 明文是包含 TextPayload 和 FilePayload 的 JSON，加密后原字段留空
+type EncryptedPayload struct {
+	SenderKey    string `json:"sender_key,omitempty"`    // 发送者公钥 (私聊)
+	RecipientKey string `json:"recipient_key,omitempty"` // 接收者公钥 (私聊)
+	KeyID        string `json:"key_id,omitempty"`        // 群组密钥编号 (群聊)
+	Nonce        []byte `json:"nonce"`                   // AEAD 随机数
+	Ciphertext   []byte `json:"ciphertext"`              // 密文
 }
 
 type FilePayload struct {
@@ -42,6 +63,9 @@ type Message struct {
 	TextPayload string      `json:"text_payload,omitempty"` // 文本内容
 	FilePayload FilePayload `json:"file_payload"`           // 文件内容
 	TreePayload TreePayload `json:"tree_payload,omitempty"` // 树状结构数据
+
+	Encrypted *EncryptedPayload `json:"encrypted,omitempty"` // 端到端加密的内容
+	History   []Message         `json:"history,omitempty"`   // 聊天记录 (HistoryResponse)
 }
 
 // Serialize 将 Message 序列化为 JSON 字符串
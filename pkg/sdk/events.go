@@ -0,0 +1,186 @@
+package sdk
+
+import (
+	"GoChat/pkg/protocol"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Message 是收到的一条聊天消息或系统通知
+type Message struct {
+	Time      time.Time
+	Sender    string
+	Recipient string // 私聊的接收者
+	Group     string // 群聊所在的群组
+	Text      string
+	File      *File // 文件消息的内容，文字消息为空
+	Encrypted bool  // 消息经过端到端加密
+}
+
+// File 是收到的文件
+type File struct {
+	Name string
+	Size int64
+	Data []byte
+}
+
+// handlers 是注册的回调，只在持有 Client.mu 时访问
+type handlers struct {
+	broadcast  []func(Message)
+	private    []func(Message)
+	group      []func(Message)
+	system     []func(Message)
+	presence   []func(Presence)
+	connect    []func()
+	disconnect []func(error)
+}
+
+// OnBroadcast 注册世界大厅消息的回调
+func (c *Client) OnBroadcast(handle func(Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.broadcast = append(c.handlers.broadcast, handle)
+}
+
+// OnPrivateMessage 注册私聊消息 (包括私聊文件) 的回调
+func (c *Client) OnPrivateMessage(handle func(Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.private = append(c.handlers.private, handle)
+}
+
+// OnGroupMessage 注册群聊消息 (包括群聊文件) 的回调
+func (c *Client) OnGroupMessage(handle func(Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.group = append(c.handlers.group, handle)
+}
+
+// OnSystemMessage 注册系统通知的回调，例如欢迎消息或消息被拒绝的原因
+func (c *Client) OnSystemMessage(handle func(Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.system = append(c.handlers.system, handle)
+}
+
+// OnPresence 注册在线用户或群组变化的回调，登录成功后会立即收到一次
+func (c *Client) OnPresence(handle func(Presence)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.presence = append(c.handlers.presence, handle)
+}
+
+// OnConnect 注册每次登录成功 (包括重连) 后的回调
+func (c *Client) OnConnect(handle func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.connect = append(c.handlers.connect, handle)
+}
+
+// OnDisconnect 注册已登录的连接断开时的回调，参数为断开的原因
+func (c *Client) OnDisconnect(handle func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers.disconnect = append(c.handlers.disconnect, handle)
+}
+
+// emit 将一次回调放入队列，由回调协程按顺序执行
+func (c *Client) emit(call func(h *handlers)) {
+	c.events.push(func() {
+		c.mu.Lock()
+		h := c.handlers
+		c.mu.Unlock()
+		call(&h)
+	})
+}
+
+// dispatch 将聊天消息交给对应的回调，自己发出后被服务器转发回来的消息不触发回调
+func (c *Client) dispatch(msg protocol.Message) {
+	if msg.Type != protocol.SystemMessage && msg.Sender == c.Username() {
+		return
+	}
+	m := newMessage(msg)
+	switch msg.Type {
+	case protocol.BroadcastMessage:
+		c.emit(func(h *handlers) { call(h.broadcast, m) })
+	case protocol.PrivateMessage, protocol.PrivateFileMessage:
+		c.emit(func(h *handlers) { call(h.private, m) })
+	case protocol.GroupMessage, protocol.GroupFileMessage:
+		c.emit(func(h *handlers) { call(h.group, m) })
+	case protocol.SystemMessage:
+		c.emit(func(h *handlers) { call(h.system, m) })
+	}
+}
+
+func call(list []func(Message), m Message) {
+	for _, handle := range list {
+		handle(m)
+	}
+}
+
+// newMessage 将协议消息转换为 Message，并解码文件内容
+func newMessage(msg protocol.Message) Message {
+	m := Message{
+		Time:      msg.Timestamp,
+		Sender:    msg.Sender,
+		Recipient: msg.Recipient,
+		Group:     msg.GroupName,
+		Text:      msg.TextPayload,
+		Encrypted: msg.Encrypted != nil,
+	}
+	if msg.Type == protocol.PrivateFileMessage || msg.Type == protocol.GroupFileMessage {
+		data, err := base64.StdEncoding.DecodeString(string(msg.FilePayload.Data))
+		if err != nil {
+			slog.Warn("文件 Base64 解码失败", "file", msg.FilePayload.Name, "sender", msg.Sender, "error", err)
+		}
+		m.File = &File{Name: msg.FilePayload.Name, Size: msg.FilePayload.Size, Data: data}
+	}
+	return m
+}
+
+// queue 是一个无界的回调队列。回调在单独的协程中执行，
+// 即使回调中等待服务器的响应，读取服务器消息的协程也不会被阻塞
+type queue struct {
+	mu     sync.Mutex
+	items  []func()
+	signal chan struct{}
+}
+
+func newQueue() *queue {
+	return &queue{signal: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(f func()) {
+	q.mu.Lock()
+	q.items = append(q.items, f)
+	q.mu.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run 依次执行队列中的回调，直到 ctx 被取消
+func (q *queue) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.signal:
+		}
+		for {
+			q.mu.Lock()
+			if len(q.items) == 0 {
+				q.mu.Unlock()
+				break
+			}
+			f := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			f()
+		}
+	}
+}
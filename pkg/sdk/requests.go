@@ -0,0 +1,184 @@
+package sdk
+
+import (
+	"GoChat/pkg/protocol"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// pollInterval 是等待群组密钥时检查的间隔，群组密钥不经过消息通道，无法直接得到通知
+const pollInterval = 100 * time.Millisecond
+
+// SendBroadcast 向世界大厅发送消息
+func (c *Client) SendBroadcast(text string) error {
+	return c.send(protocol.Message{Type: protocol.BroadcastMessage, TextPayload: text})
+}
+
+// SendPrivate 向用户发送私聊消息，双方都发布了公钥时自动加密
+func (c *Client) SendPrivate(username, text string) error {
+	return c.send(protocol.Message{Type: protocol.PrivateMessage, Recipient: username, TextPayload: text})
+}
+
+// SendGroup 向群组发送消息，需要先加入该群组才能收到群组中的回复
+func (c *Client) SendGroup(group, text string) error {
+	return c.send(protocol.Message{Type: protocol.GroupMessage, GroupName: group, TextPayload: text})
+}
+
+// SendPrivateFile 以 name 为文件名向用户发送文件
+func (c *Client) SendPrivateFile(username, name string, data []byte) error {
+	return c.send(protocol.Message{Type: protocol.PrivateFileMessage, Recipient: username, FilePayload: filePayload(name, data)})
+}
+
+// SendGroupFile 以 name 为文件名向群组发送文件
+func (c *Client) SendGroupFile(group, name string, data []byte) error {
+	return c.send(protocol.Message{Type: protocol.GroupFileMessage, GroupName: group, FilePayload: filePayload(name, data)})
+}
+
+func filePayload(name string, data []byte) protocol.FilePayload {
+	return protocol.FilePayload{
+		Name: name,
+		Size: int64(len(data)),
+		Data: []byte(base64.StdEncoding.EncodeToString(data)),
+	}
+}
+
+// send 以当前用户的身份发送消息，未连接时返回 ErrNotConnected
+func (c *Client) send(msg protocol.Message) error {
+	conn, username, err := c.current()
+	if err != nil {
+		return err
+	}
+	msg.Sender = username
+	conn.Send(msg)
+	return nil
+}
+
+// Join 加入群组 (不存在时由服务器创建)，等待服务器确认后返回。
+// 加密群组会等到收到群组密钥，之后发送的消息才能被加密。加入的群组在重连后自动重新加入
+func (c *Client) Join(ctx context.Context, group string) error {
+	if group == "" {
+		return errors.New("群组名不能为空")
+	}
+	conn, username, err := c.current()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.groups[group] = true
+	c.mu.Unlock()
+	conn.Send(protocol.Message{Type: protocol.JoinGroupRequest, Sender: username, GroupName: group})
+
+	return c.waitFor(ctx, func() (bool, error) {
+		conn, username, err := c.current()
+		if err != nil {
+			return false, err
+		}
+		if !c.Presence().isMember(group, username) {
+			return false, nil
+		}
+		return !conn.IsEncryptedGroup(group) || conn.GroupKeyReady(group), nil
+	})
+}
+
+// Leave 离开群组，等待服务器确认后返回
+func (c *Client) Leave(ctx context.Context, group string) error {
+	c.mu.Lock()
+	delete(c.groups, group)
+	c.mu.Unlock()
+	conn, username, err := c.current()
+	if err != nil {
+		return err
+	}
+	conn.Send(protocol.Message{Type: protocol.LeaveGroupRequest, Sender: username, GroupName: group})
+
+	return c.waitFor(ctx, func() (bool, error) {
+		_, username, err := c.current()
+		if err != nil {
+			return false, err
+		}
+		return !c.Presence().isMember(group, username), nil
+	})
+}
+
+// waitFor 在连接或在线状态变化时检查 done，直到 done 返回 true、返回错误或 ctx 结束
+func (c *Client) waitFor(ctx context.Context, done func() (bool, error)) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		c.mu.Lock()
+		changed := c.changed
+		c.mu.Unlock()
+		if finished, err := done(); err != nil || finished {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// historyResult 是一次聊天记录查询的结果
+type historyResult struct {
+	messages []Message
+	err      error
+}
+
+// History 查询世界大厅 (group 为空) 或群组最近的 limit 条聊天记录，limit 为 0 时返回服务器保留的全部记录。
+// 服务器只保留文字消息，群组的记录只有成员可以查询
+func (c *Client) History(ctx context.Context, group string, limit int) ([]Message, error) {
+	c.mu.Lock()
+	conn, username := c.conn, c.username
+	if conn == nil {
+		c.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	// 服务器按请求的顺序回复，同一会话的多个请求依次对应
+	result := make(chan historyResult, 1)
+	c.history[group] = append(c.history[group], result)
+	c.mu.Unlock()
+
+	conn.Send(protocol.Message{
+		Type:        protocol.HistoryRequest,
+		Sender:      username,
+		GroupName:   group,
+		TextPayload: strconv.Itoa(limit),
+	})
+	select {
+	case r := <-result:
+		return r.messages, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolveHistory 将服务器返回的聊天记录交给最早的等待者
+func (c *Client) resolveHistory(msg protocol.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := c.history[msg.GroupName]
+	if len(waiters) == 0 {
+		return
+	}
+	c.history[msg.GroupName] = waiters[1:]
+	if len(waiters) == 1 {
+		delete(c.history, msg.GroupName)
+	}
+
+	var r historyResult
+	if msg.TextPayload != "" {
+		r.err = fmt.Errorf("查询聊天记录失败: %s", msg.TextPayload)
+	} else {
+		r.messages = make([]Message, 0, len(msg.History))
+		for _, m := range msg.History {
+			r.messages = append(r.messages, newMessage(m))
+		}
+	}
+	waiters[0] <- r
+}
@@ -0,0 +1,322 @@
+// Package sdk 是用于编写机器人和集成程序的客户端库。它基于客户端程序共用的连接逻辑，
+// 提供按消息类型注册的回调、加入群组和查询聊天记录等请求，并在连接断开后自动重连:
+//
+//	c := sdk.New(sdk.Options{Address: "127.0.0.1:8080", Username: "bot"})
+//	c.OnConnect(func() {
+//		if err := c.Join(context.Background(), "dev"); err != nil {
+//			log.Print(err)
+//		}
+//	})
+//	c.OnGroupMessage(func(m sdk.Message) {
+//		if m.Text == "ping" {
+//			c.SendGroup(m.Group, "pong")
+//		}
+//	})
+//	log.Fatal(c.Run(context.Background()))
+//
+// 回调在同一个协程中依次执行，可以在回调中调用 Client 的任何方法。
+package sdk
+
+import (
+	"GoChat/internal/client"
+	"GoChat/pkg/protocol"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected 表示当前没有登录到服务器，连接断开期间调用请求方法会返回该错误
+var ErrNotConnected = errors.New("未连接到服务器")
+
+// errClosed 表示服务器正常关闭了连接
+var errClosed = errors.New("服务器关闭了连接")
+
+// LoginError 表示服务器拒绝了登录，Run 遇到该错误时不再重连
+type LoginError struct {
+	Reason string
+}
+
+func (e *LoginError) Error() string {
+	return "登录失败: " + e.Reason
+}
+
+// Options 是连接服务器的参数
+type Options struct {
+	Address     string           // 服务器地址，如 127.0.0.1:8080
+	Username    string           // 用户名，使用客户端证书登录时可以为空，由服务器根据证书确定
+	Password    string           // 已注册账号的密码
+	TLS         bool             // 使用 TLS 加密连接
+	RootCAs     *x509.CertPool   // 校验服务器证书时额外信任的 CA
+	Certificate *tls.Certificate // 服务器启用双向 TLS 时出示的客户端证书
+	PinFile     string           // 记录服务器证书指纹的文件，非空时对自签名证书启用首次信任
+	KeyDir      string           // 端到端加密密钥的保存目录，为空时不使用端到端加密
+
+	MinBackoff time.Duration // 重连的初始等待时间，默认 1 秒
+	MaxBackoff time.Duration // 重连的最长等待时间，默认 30 秒
+}
+
+// Client 是一个会自动重连的聊天客户端，使用 New 创建
+type Client struct {
+	opts   Options
+	events *queue
+
+	mu       sync.Mutex
+	handlers handlers
+	conn     *client.Client // 当前已登录的连接，断开期间为空
+	username string
+	presence Presence
+	groups   map[string]bool                 // 通过 Join 加入的群组，重连后自动重新加入
+	history  map[string][]chan historyResult // 等待聊天记录的请求，键为群组名，世界大厅为空字符串
+	changed  chan struct{}                   // 连接或在线状态变化时关闭并替换，用于唤醒等待的请求
+}
+
+// New 创建客户端，注册回调后调用 Run 连接服务器
+func New(opts Options) *Client {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(30*time.Second, opts.MinBackoff)
+	}
+	return &Client{
+		opts:    opts,
+		events:  newQueue(),
+		groups:  make(map[string]bool),
+		history: make(map[string][]chan historyResult),
+		changed: make(chan struct{}),
+	}
+}
+
+// Run 连接并登录服务器，连接断开后按指数退避自动重连，直到 ctx 被取消。
+// 服务器拒绝登录或 TLS 证书与之前记录的不一致时返回错误，不再重连
+func (c *Client) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.events.run(ctx)
+
+	backoff := c.opts.MinBackoff
+	for {
+		loggedIn, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var loginErr *LoginError
+		var changed *client.CertificateChangedError
+		if errors.As(err, &loginErr) || errors.As(err, &changed) {
+			return err
+		}
+		if loggedIn {
+			backoff = c.opts.MinBackoff
+			c.emit(func(h *handlers) {
+				for _, handle := range h.disconnect {
+					handle(err)
+				}
+			})
+		}
+		if loggedIn {
+			slog.Warn("与服务器的连接断开，稍后重连", "address", c.opts.Address, "retry_in", backoff, "error", err)
+		} else {
+			slog.Warn("连接服务器失败，稍后重试", "address", c.opts.Address, "retry_in", backoff, "error", err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+// session 建立一次连接并处理服务器消息，直到连接断开。loggedIn 表示本次连接是否登录成功
+func (c *Client) session(ctx context.Context) (loggedIn bool, err error) {
+	conn, err := c.newConn()
+	if err != nil {
+		return false, err
+	}
+	if err := conn.Connect(c.opts.Address, c.opts.TLS); err != nil {
+		return false, err
+	}
+	stop := context.AfterFunc(ctx, conn.Close)
+	defer stop()
+	defer c.disconnected()
+
+	conn.SetUsername(c.opts.Username)
+	conn.Start()
+	conn.Send(protocol.Message{Type: protocol.LoginRequest, Sender: c.opts.Username, TextPayload: c.opts.Password})
+
+	username := ""
+	for msg := range conn.GetIncomingMessages() {
+		switch msg.Type {
+		case protocol.LoginResponse:
+			if msg.TextPayload != "" {
+				conn.Close()
+				return false, &LoginError{Reason: msg.TextPayload}
+			}
+			username = msg.Recipient
+			conn.SetUsername(username)
+		case protocol.TreeUpdate:
+			// 服务器在登录成功之后广播在线状态，收到时登录过程已经完成；登录前的状态更新忽略
+			switch {
+			case username == "":
+			case !loggedIn:
+				loggedIn = true
+				c.connected(conn, username, msg.TreePayload)
+			default:
+				c.updatePresence(msg.TreePayload)
+			}
+		case protocol.HistoryResponse:
+			c.resolveHistory(msg)
+		default:
+			if username != "" {
+				c.dispatch(msg)
+			}
+		}
+	}
+	if err := conn.Err(); err != nil {
+		return loggedIn, err
+	}
+	return loggedIn, errClosed
+}
+
+// newConn 按照参数创建底层客户端
+func (c *Client) newConn() (*client.Client, error) {
+	conn := client.NewClient()
+	if c.opts.RootCAs != nil {
+		conn.SetRootCAs(c.opts.RootCAs)
+	}
+	if c.opts.Certificate != nil {
+		conn.SetCertificate(*c.opts.Certificate)
+	}
+	if c.opts.PinFile != "" {
+		pins, err := client.OpenPinStore(c.opts.PinFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取证书指纹文件失败: %w", err)
+		}
+		conn.SetPinStore(pins)
+	}
+	if c.opts.KeyDir != "" {
+		conn.EnableE2E(c.opts.KeyDir)
+	}
+	return conn, nil
+}
+
+// connected 记录登录成功的连接，重新加入之前的群组并触发 OnConnect 回调
+func (c *Client) connected(conn *client.Client, username string, tree protocol.TreePayload) {
+	c.mu.Lock()
+	c.conn = conn
+	c.username = username
+	c.presence = newPresence(tree)
+	for group := range c.groups {
+		if !c.presence.isMember(group, username) {
+			conn.Send(protocol.Message{Type: protocol.JoinGroupRequest, Sender: username, GroupName: group})
+		}
+	}
+	presence := c.presence
+	c.notifyLocked()
+	c.mu.Unlock()
+
+	slog.Info("已登录到服务器", "address", c.opts.Address, "username", username)
+	c.emit(func(h *handlers) {
+		for _, handle := range h.connect {
+			handle()
+		}
+		for _, handle := range h.presence {
+			handle(presence)
+		}
+	})
+}
+
+// disconnected 清除连接状态，并让等待中的请求返回 ErrNotConnected
+func (c *Client) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = nil
+	for group, waiters := range c.history {
+		for _, w := range waiters {
+			w <- historyResult{err: ErrNotConnected}
+		}
+		delete(c.history, group)
+	}
+	c.notifyLocked()
+}
+
+// updatePresence 记录新的在线状态并触发 OnPresence 回调
+func (c *Client) updatePresence(tree protocol.TreePayload) {
+	presence := newPresence(tree)
+	c.mu.Lock()
+	c.presence = presence
+	c.notifyLocked()
+	c.mu.Unlock()
+	c.emit(func(h *handlers) {
+		for _, handle := range h.presence {
+			handle(presence)
+		}
+	})
+}
+
+// notifyLocked 唤醒所有等待状态变化的请求，调用者需持有锁
+func (c *Client) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Username 返回服务器确认的用户名，尚未登录时返回空字符串
+func (c *Client) Username() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username
+}
+
+// Connected 返回当前是否已登录到服务器
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// Presence 返回最近一次收到的在线用户和群组
+func (c *Client) Presence() Presence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.presence
+}
+
+// current 返回当前连接和用户名，未连接时返回 ErrNotConnected
+func (c *Client) current() (*client.Client, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, "", ErrNotConnected
+	}
+	return c.conn, c.username, nil
+}
+
+// Presence 是服务器广播的在线状态
+type Presence struct {
+	Users           []string            // 在线用户
+	Groups          map[string][]string // 群组及其成员
+	EncryptedGroups []string            // 启用端到端加密的群组
+	Bots            []string            // 在线用户中由服务器运行的机器人
+}
+
+// newPresence 转换服务器的状态更新，尚未登录的连接没有用户名，不计入在线用户
+func newPresence(tree protocol.TreePayload) Presence {
+	users := slices.DeleteFunc(slices.Clone(tree.Users), func(u string) bool { return u == "" })
+	return Presence{Users: users, Groups: tree.Groups, EncryptedGroups: tree.EncryptedGroups, Bots: tree.Bots}
+}
+
+// Online 返回用户是否在线
+func (p Presence) Online(username string) bool {
+	return slices.Contains(p.Users, username)
+}
+
+// isMember 返回用户是否是群组成员
+func (p Presence) isMember(group, username string) bool {
+	return slices.Contains(p.Groups[group], username)
+}